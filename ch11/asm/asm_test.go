@@ -0,0 +1,246 @@
+package asm_test
+
+import (
+	"strings"
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/classfile"
+	"jvmgo/ch11/vm"
+)
+
+func newVM(t *testing.T, classes ...*asm.ClassBuilder) *vm.VM {
+	t.Helper()
+	return asmtest.NewVM(t, vm.Options{}, classes...)
+}
+
+func invoke(t *testing.T, jvm *vm.VM, className, methodName, descriptor string, args ...interface{}) interface{} {
+	t.Helper()
+	result, err := jvm.InvokeStatic(className, methodName, descriptor, args...)
+	if err != nil {
+		t.Fatalf("%s.%s%s: %v", className, methodName, descriptor, err)
+	}
+	return result
+}
+
+func TestParseAssembledClass(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Foo", "java/lang/Object", "java/lang/Runnable")
+	cb.SetSourceFile("Foo.java")
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC, "count", "J")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "add", "(JI)J")
+	mb.VarInsn(asm.LLOAD, 0)
+	mb.VarInsn(asm.ILOAD, 2)
+	mb.Insn(asm.I2L)
+	mb.Insn(asm.LADD)
+	mb.Insn(asm.LRETURN)
+
+	cf, err := classfile.Parse(cb.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.ClassName() != "Foo" || cf.SuperClassName() != "java/lang/Object" {
+		t.Errorf("class = %s extends %s", cf.ClassName(), cf.SuperClassName())
+	}
+	if names := cf.InterfaceNames(); len(names) != 1 || names[0] != "java/lang/Runnable" {
+		t.Errorf("interfaces = %v", names)
+	}
+	if name := cf.SourceFileAttribute().FileName(); name != "Foo.java" {
+		t.Errorf("source file = %q", name)
+	}
+	if len(cf.Fields()) != 1 || cf.Fields()[0].Descriptor() != "J" {
+		t.Errorf("fields = %v", cf.Fields())
+	}
+	methods := cf.Methods()
+	if len(methods) != 1 || methods[0].Name() != "add" {
+		t.Fatalf("methods = %v", methods)
+	}
+	code := methods[0].CodeAttribute()
+	// long占两个位置：lload iload i2l之后栈深度是4
+	if code.MaxStack() != 4 || code.MaxLocals() != 3 {
+		t.Errorf("max_stack = %d, max_locals = %d, want 4, 3", code.MaxStack(), code.MaxLocals())
+	}
+	want := []byte{asm.LLOAD, 0, asm.ILOAD, 2, asm.I2L, asm.LADD, asm.LRETURN}
+	if string(code.Code()) != string(want) {
+		t.Errorf("code = % x, want % x", code.Code(), want)
+	}
+}
+
+func TestRunArithmetic(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Calc", "java/lang/Object")
+	add := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "add", "(II)I")
+	add.VarInsn(asm.ILOAD, 0)
+	add.VarInsn(asm.ILOAD, 1)
+	add.Insn(asm.IADD)
+	add.Insn(asm.IRETURN)
+
+	mul := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "mul", "(JJ)J")
+	mul.VarInsn(asm.LLOAD, 0)
+	mul.VarInsn(asm.LLOAD, 2)
+	mul.Insn(asm.LMUL)
+	mul.Insn(asm.LRETURN)
+
+	div := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "div", "(DD)D")
+	div.VarInsn(asm.DLOAD, 0)
+	div.VarInsn(asm.DLOAD, 2)
+	div.Insn(asm.DDIV)
+	div.Insn(asm.DRETURN)
+
+	jvm := newVM(t, cb)
+	if got := invoke(t, jvm, "Calc", "add", "(II)I", 2, 40); got != int32(42) {
+		t.Errorf("add = %v", got)
+	}
+	if got := invoke(t, jvm, "Calc", "mul", "(JJ)J", int64(1)<<20, int64(1)<<20); got != int64(1)<<40 {
+		t.Errorf("mul = %v", got)
+	}
+	if got := invoke(t, jvm, "Calc", "div", "(DD)D", 1.0, 4.0); got != 0.25 {
+		t.Errorf("div = %v", got)
+	}
+}
+
+func TestRunLoopAndBranches(t *testing.T) {
+	// static int sum(int n) { int s = 0; for (int i = 1; i <= n; i++) s += i; return s; }
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Loop", "java/lang/Object")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "sum", "(I)I")
+	cond, body := mb.NewLabel(), mb.NewLabel()
+	mb.Insn(asm.ICONST_0)
+	mb.VarInsn(asm.ISTORE, 1)
+	mb.Insn(asm.ICONST_1)
+	mb.VarInsn(asm.ISTORE, 2)
+	mb.JumpInsn(asm.GOTO, cond)
+	mb.Mark(body)
+	mb.VarInsn(asm.ILOAD, 1)
+	mb.VarInsn(asm.ILOAD, 2)
+	mb.Insn(asm.IADD)
+	mb.VarInsn(asm.ISTORE, 1)
+	mb.IincInsn(2, 1)
+	mb.Mark(cond)
+	mb.VarInsn(asm.ILOAD, 2)
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.JumpInsn(asm.IF_ICMPLE, body)
+	mb.VarInsn(asm.ILOAD, 1)
+	mb.Insn(asm.IRETURN)
+
+	jvm := newVM(t, cb)
+	if got := invoke(t, jvm, "Loop", "sum", "(I)I", 100); got != int32(5050) {
+		t.Errorf("sum(100) = %v", got)
+	}
+	if got := invoke(t, jvm, "Loop", "sum", "(I)I", 0); got != int32(0) {
+		t.Errorf("sum(0) = %v", got)
+	}
+}
+
+func TestRunSwitches(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Switch", "java/lang/Object")
+
+	table := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "table", "(I)I")
+	dflt, one, two := table.NewLabel(), table.NewLabel(), table.NewLabel()
+	table.VarInsn(asm.ILOAD, 0)
+	table.TableSwitchInsn(1, 2, dflt, one, two)
+	table.Mark(one)
+	table.IntInsn(asm.BIPUSH, 10)
+	table.Insn(asm.IRETURN)
+	table.Mark(two)
+	table.IntInsn(asm.SIPUSH, 2000)
+	table.Insn(asm.IRETURN)
+	table.Mark(dflt)
+	table.Insn(asm.ICONST_M1)
+	table.Insn(asm.IRETURN)
+
+	lookup := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "lookup", "(I)I")
+	ldflt, neg, big := lookup.NewLabel(), lookup.NewLabel(), lookup.NewLabel()
+	lookup.VarInsn(asm.ILOAD, 0)
+	lookup.LookupSwitchInsn(ldflt, []int32{100000, -7}, []*asm.Label{big, neg})
+	lookup.Mark(neg)
+	lookup.Insn(asm.ICONST_1)
+	lookup.Insn(asm.IRETURN)
+	lookup.Mark(big)
+	lookup.Ldc(int32(123456))
+	lookup.Insn(asm.IRETURN)
+	lookup.Mark(ldflt)
+	lookup.Insn(asm.ICONST_0)
+	lookup.Insn(asm.IRETURN)
+
+	jvm := newVM(t, cb)
+	for arg, want := range map[int]int32{0: -1, 1: 10, 2: 2000, 3: -1} {
+		if got := invoke(t, jvm, "Switch", "table", "(I)I", arg); got != want {
+			t.Errorf("table(%d) = %v, want %d", arg, got, want)
+		}
+	}
+	for arg, want := range map[int]int32{-7: 1, 100000: 123456, 5: 0} {
+		if got := invoke(t, jvm, "Switch", "lookup", "(I)I", arg); got != want {
+			t.Errorf("lookup(%d) = %v, want %d", arg, got, want)
+		}
+	}
+}
+
+func TestRunStaticFieldsAndCalls(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Counter", "java/lang/Object")
+	cb.AddField(asm.ACC_STATIC, "count", "I")
+	clinit := cb.AddMethod(asm.ACC_STATIC, "<clinit>", "()V")
+	clinit.IntInsn(asm.BIPUSH, 5)
+	clinit.FieldInsn(asm.PUTSTATIC, "Counter", "count", "I")
+	clinit.Insn(asm.RETURN)
+
+	inc := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "inc", "()I")
+	inc.FieldInsn(asm.GETSTATIC, "Counter", "count", "I")
+	inc.Insn(asm.ICONST_1)
+	inc.Insn(asm.IADD)
+	inc.Insn(asm.DUP)
+	inc.FieldInsn(asm.PUTSTATIC, "Counter", "count", "I")
+	inc.Insn(asm.IRETURN)
+
+	twice := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "twice", "()I")
+	twice.MethodInsn(asm.INVOKESTATIC, "Counter", "inc", "()I")
+	twice.Insn(asm.POP)
+	twice.MethodInsn(asm.INVOKESTATIC, "Counter", "inc", "()I")
+	twice.Insn(asm.IRETURN)
+
+	jvm := newVM(t, cb)
+	if got := invoke(t, jvm, "Counter", "twice", "()I"); got != int32(7) {
+		t.Errorf("twice = %v, want 7", got)
+	}
+}
+
+func TestZeroValueLabel(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Zero", "java/lang/Object")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "f", "()I")
+	var end asm.Label
+	mb.JumpInsn(asm.GOTO, &end)
+	mb.Insn(asm.NOP)
+	mb.Mark(&end)
+	mb.Insn(asm.ICONST_2)
+	mb.Insn(asm.IRETURN)
+
+	jvm := newVM(t, cb)
+	if got := invoke(t, jvm, "Zero", "f", "()I"); got != int32(2) {
+		t.Errorf("f = %v, want 2", got)
+	}
+}
+
+func TestUnmarkedLabelPanics(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Bad", "java/lang/Object")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "f", "()V")
+	var label asm.Label
+	mb.JumpInsn(asm.GOTO, &label)
+	defer func() {
+		r := recover()
+		if msg, _ := r.(string); !strings.Contains(msg, "label not marked") {
+			t.Errorf("recover() = %v, want label not marked", r)
+		}
+	}()
+	cb.Bytes()
+}
+
+func TestMarkTwicePanics(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Bad", "java/lang/Object")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "f", "()V")
+	label := mb.NewLabel()
+	mb.Mark(label)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("marking a label twice did not panic")
+		}
+	}()
+	mb.Mark(label)
+}
@@ -0,0 +1,104 @@
+/*
+asmtest 用asm生成不依赖JDK的最小类库，测试可以在内存中创建虚拟机并执行用asm生成的类
+
+	jvm := asmtest.NewVM(t, vm.Options{}, cb)
+	result, err := jvm.InvokeStatic("Foo", "add", "(II)I", 1, 2)
+*/
+package asmtest
+
+import (
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/classfile"
+	"jvmgo/ch11/classpath"
+	"jvmgo/ch11/vm"
+)
+
+/*
+BootClasses 返回执行简单代码需要的类：
+java/lang/Object(wait/notify是本地方法)、java/lang/Class、java/lang/String(只有value字段)以及数组实现的两个接口
+*/
+func BootClasses() []*asm.ClassBuilder {
+	object := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "java/lang/Object", "")
+	object.AddMethod(asm.ACC_PUBLIC, "<init>", "()V").Insn(asm.RETURN)
+	object.AddMethod(asm.ACC_PUBLIC|asm.ACC_FINAL|asm.ACC_NATIVE, "wait", "(J)V")
+	object.AddMethod(asm.ACC_PUBLIC|asm.ACC_FINAL|asm.ACC_NATIVE, "notify", "()V")
+	object.AddMethod(asm.ACC_PUBLIC|asm.ACC_FINAL|asm.ACC_NATIVE, "notifyAll", "()V")
+
+	class := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_FINAL|asm.ACC_SUPER, "java/lang/Class", "java/lang/Object")
+	str := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_FINAL|asm.ACC_SUPER, "java/lang/String", "java/lang/Object")
+	str.AddField(asm.ACC_PRIVATE|asm.ACC_FINAL, "value", "[C")
+	cloneable := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_INTERFACE|asm.ACC_ABSTRACT, "java/lang/Cloneable", "java/lang/Object")
+	serializable := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_INTERFACE|asm.ACC_ABSTRACT, "java/io/Serializable", "java/lang/Object")
+	return []*asm.ClassBuilder{object, class, str, cloneable, serializable}
+}
+
+// Exception 异常类，虚拟机抛出异常时调用()V或者(Ljava/lang/String;)V构造函数，消息和Throwable一样保存在detailMessage中
+func Exception(name string) *asm.ClassBuilder {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, name, "java/lang/Object")
+	cb.AddField(asm.ACC_PRIVATE, "detailMessage", "Ljava/lang/String;")
+	init := cb.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.RETURN)
+	init = cb.AddMethod(asm.ACC_PUBLIC, "<init>", "(Ljava/lang/String;)V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.Insn(asm.ALOAD_1)
+	init.FieldInsn(asm.PUTFIELD, name, "detailMessage", "Ljava/lang/String;")
+	init.Insn(asm.RETURN)
+	return cb
+}
+
+/*
+Thread 最小的java/lang/Thread，start()调用本地方法start0()，run()什么也不做
+子类覆盖run()就可以在新线程中执行代码，yield、sleep和holdsLock是静态本地方法
+*/
+func Thread() *asm.ClassBuilder {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "java/lang/Thread", "java/lang/Object")
+	cb.AddField(asm.ACC_PRIVATE, "priority", "I")
+	cb.AddField(asm.ACC_PRIVATE, "daemon", "Z")
+	cb.AddField(asm.ACC_PRIVATE, "threadStatus", "I")
+	init := cb.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.RETURN)
+	start := cb.AddMethod(asm.ACC_PUBLIC, "start", "()V")
+	start.Insn(asm.ALOAD_0)
+	start.MethodInsn(asm.INVOKESPECIAL, "java/lang/Thread", "start0", "()V")
+	start.Insn(asm.RETURN)
+	cb.AddMethod(asm.ACC_PUBLIC, "run", "()V").Insn(asm.RETURN)
+	cb.AddMethod(asm.ACC_PRIVATE|asm.ACC_NATIVE, "start0", "()V")
+	cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "yield", "()V")
+	cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "sleep", "(J)V")
+	cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "holdsLock", "(Ljava/lang/Object;)Z")
+	return cb
+}
+
+// Classpath 包含BootClasses()和classes的内存中的classpath，同名时classes中的类代替BootClasses()中的
+func Classpath(t testing.TB, classes ...*asm.ClassBuilder) *classpath.Classpath {
+	t.Helper()
+	entry := classpath.NewMemoryEntry()
+	for _, cb := range append(BootClasses(), classes...) {
+		data := cb.Bytes()
+		cf, err := classfile.Parse(data)
+		if err != nil {
+			t.Fatalf("asmtest: %v", err)
+		}
+		entry.AddClass(cf.ClassName(), data)
+	}
+	return classpath.New(entry)
+}
+
+// NewVM 用Classpath(t, classes...)创建虚拟机，忽略options.Classpath
+func NewVM(t testing.TB, options vm.Options, classes ...*asm.ClassBuilder) *vm.VM {
+	t.Helper()
+	options.Classpath = Classpath(t, classes...)
+	jvm, err := vm.New(options)
+	if err != nil {
+		t.Fatalf("asmtest: vm.New: %v", err)
+	}
+	return jvm
+}
@@ -0,0 +1,41 @@
+package asm
+
+import "encoding/binary"
+
+// byteWriter 把数据按大端序追加到data后面
+type byteWriter struct {
+	data []byte
+}
+
+func (self *byteWriter) writeUint8(val uint8) {
+	self.data = append(self.data, val)
+}
+
+func (self *byteWriter) writeUint16(val uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *byteWriter) writeUint32(val uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *byteWriter) writeUint64(val uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *byteWriter) writeBytes(bytes []byte) {
+	self.data = append(self.data, bytes...)
+}
+
+// writeAttribute 写属性名的索引、属性的长度和属性的内容
+func (self *byteWriter) writeAttribute(nameIndex uint16, info []byte) {
+	self.writeUint16(nameIndex)
+	self.writeUint32(uint32(len(info)))
+	self.writeBytes(info)
+}
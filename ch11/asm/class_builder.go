@@ -0,0 +1,153 @@
+package asm
+
+/*
+asm 在Go代码中生成class文件，不需要javac就能构造用来测试解释器的类
+
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Foo", "java/lang/Object")
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "add", "(II)I")
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.VarInsn(asm.ILOAD, 1)
+	mb.Insn(asm.IADD)
+	mb.Insn(asm.IRETURN)
+	data := cb.Bytes()
+
+max_stack和max_locals根据生成的指令自动计算
+*/
+
+// 访问标志，名字和JVM规范中的一致
+const (
+	ACC_PUBLIC       = 0x0001
+	ACC_PRIVATE      = 0x0002
+	ACC_PROTECTED    = 0x0004
+	ACC_STATIC       = 0x0008
+	ACC_FINAL        = 0x0010
+	ACC_SUPER        = 0x0020
+	ACC_SYNCHRONIZED = 0x0020
+	ACC_VOLATILE     = 0x0040
+	ACC_TRANSIENT    = 0x0080
+	ACC_NATIVE       = 0x0100
+	ACC_INTERFACE    = 0x0200
+	ACC_ABSTRACT     = 0x0400
+)
+
+type ClassBuilder struct {
+	cp           *constantPool
+	minorVersion uint16
+	majorVersion uint16
+	accessFlags  uint16
+	thisClass    uint16
+	superClass   uint16
+	interfaces   []uint16
+	fields       []*FieldBuilder
+	methods      []*MethodBuilder
+	sourceFile   string
+}
+
+type FieldBuilder struct {
+	cp              *constantPool
+	accessFlags     uint16
+	nameIndex       uint16
+	descriptorIndex uint16
+	constValueIndex uint16
+}
+
+// NewClass 创建类，superName为空表示没有超类(只有java/lang/Object可以这样)
+func NewClass(accessFlags uint16, name, superName string, interfaces ...string) *ClassBuilder {
+	cp := newConstantPool()
+	self := &ClassBuilder{
+		cp:           cp,
+		majorVersion: 52, // Java 8
+		accessFlags:  accessFlags,
+		thisClass:    cp.class(name),
+	}
+	if superName != "" {
+		self.superClass = cp.class(superName)
+	}
+	for _, interfaceName := range interfaces {
+		self.interfaces = append(self.interfaces, cp.class(interfaceName))
+	}
+	return self
+}
+
+func (self *ClassBuilder) SetVersion(majorVersion, minorVersion uint16) {
+	self.majorVersion = majorVersion
+	self.minorVersion = minorVersion
+}
+
+func (self *ClassBuilder) SetSourceFile(sourceFile string) {
+	self.sourceFile = sourceFile
+}
+
+func (self *ClassBuilder) AddField(accessFlags uint16, name, descriptor string) *FieldBuilder {
+	field := &FieldBuilder{
+		cp:              self.cp,
+		accessFlags:     accessFlags,
+		nameIndex:       self.cp.utf8(name),
+		descriptorIndex: self.cp.utf8(descriptor),
+	}
+	self.fields = append(self.fields, field)
+	return field
+}
+
+// SetConstantValue 给static final字段添加ConstantValue属性，val可以是int32、float32、int64、float64或者string
+func (self *FieldBuilder) SetConstantValue(val interface{}) {
+	self.constValueIndex = self.cp.constant(val)
+}
+
+// AddMethod 添加方法，本地方法和抽象方法没有Code属性
+func (self *ClassBuilder) AddMethod(accessFlags uint16, name, descriptor string) *MethodBuilder {
+	method := newMethodBuilder(self.cp, accessFlags, name, descriptor)
+	self.methods = append(self.methods, method)
+	return method
+}
+
+// Bytes 生成class文件，方法的字节码有错误(比如标签没有标记或者操作数栈溢出)时panic
+func (self *ClassBuilder) Bytes() []byte {
+	// 属性名等常量在生成属性时才添加到常量池，所以要先生成字段和方法，最后再写常量池
+	body := &byteWriter{}
+	body.writeUint16(self.accessFlags)
+	body.writeUint16(self.thisClass)
+	body.writeUint16(self.superClass)
+	body.writeUint16(uint16(len(self.interfaces)))
+	for _, index := range self.interfaces {
+		body.writeUint16(index)
+	}
+	body.writeUint16(uint16(len(self.fields)))
+	for _, field := range self.fields {
+		field.write(body)
+	}
+	body.writeUint16(uint16(len(self.methods)))
+	for _, method := range self.methods {
+		method.write(body)
+	}
+	if self.sourceFile != "" {
+		body.writeUint16(1)
+		info := &byteWriter{}
+		info.writeUint16(self.cp.utf8(self.sourceFile))
+		body.writeAttribute(self.cp.utf8("SourceFile"), info.data)
+	} else {
+		body.writeUint16(0)
+	}
+
+	writer := &byteWriter{}
+	writer.writeUint32(0xCAFEBABE)
+	writer.writeUint16(self.minorVersion)
+	writer.writeUint16(self.majorVersion)
+	self.cp.write(writer)
+	writer.writeBytes(body.data)
+	return writer.data
+}
+
+func (self *FieldBuilder) write(writer *byteWriter) {
+	writer.writeUint16(self.accessFlags)
+	writer.writeUint16(self.nameIndex)
+	writer.writeUint16(self.descriptorIndex)
+	if self.constValueIndex == 0 {
+		writer.writeUint16(0)
+		return
+	}
+	writer.writeUint16(1)
+	info := &byteWriter{}
+	info.writeUint16(self.constValueIndex)
+	writer.writeAttribute(self.cp.utf8("ConstantValue"), info.data)
+}
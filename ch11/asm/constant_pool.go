@@ -0,0 +1,149 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+)
+
+// 常量的tag，和classfile包中的一样
+const (
+	constantUtf8               = 1
+	constantInteger            = 3
+	constantFloat              = 4
+	constantLong               = 5
+	constantDouble             = 6
+	constantClass              = 7
+	constantString             = 8
+	constantFieldref           = 9
+	constantMethodref          = 10
+	constantInterfaceMethodref = 11
+	constantNameAndType        = 12
+)
+
+/*
+constantPool 在生成类的过程中收集用到的常量，相同的常量只添加一次
+常量添加时就已经编码好了，最后直接写到class文件中
+*/
+type constantPool struct {
+	writer  byteWriter
+	count   uint16            // 下一个常量的索引，0不使用
+	indexes map[string]uint16 // 常量的key到索引的映射
+}
+
+func newConstantPool() *constantPool {
+	return &constantPool{count: 1, indexes: map[string]uint16{}}
+}
+
+// add 添加常量并返回它的索引，long和double占两个位置
+func (self *constantPool) add(key string, tag uint8, write func(writer *byteWriter)) uint16 {
+	if index, ok := self.indexes[key]; ok {
+		return index
+	}
+	index := self.count
+	self.writer.writeUint8(tag)
+	write(&self.writer)
+	self.count++
+	if tag == constantLong || tag == constantDouble {
+		self.count++
+	}
+	if self.count == 0 {
+		panic("asm: too many constants")
+	}
+	self.indexes[key] = index
+	return index
+}
+
+func (self *constantPool) utf8(str string) uint16 {
+	return self.add("Utf8:"+str, constantUtf8, func(writer *byteWriter) {
+		bytes := []byte(str)
+		if len(bytes) > math.MaxUint16 {
+			panic("asm: string too long: " + str[:32] + "...")
+		}
+		writer.writeUint16(uint16(len(bytes)))
+		writer.writeBytes(bytes)
+	})
+}
+
+func (self *constantPool) integer(val int32) uint16 {
+	return self.add(fmt.Sprintf("Integer:%d", val), constantInteger, func(writer *byteWriter) {
+		writer.writeUint32(uint32(val))
+	})
+}
+
+// 浮点数用二进制表示作为key，这样NaN和-0.0也能正确去重
+func (self *constantPool) float(val float32) uint16 {
+	bits := math.Float32bits(val)
+	return self.add(fmt.Sprintf("Float:%x", bits), constantFloat, func(writer *byteWriter) {
+		writer.writeUint32(bits)
+	})
+}
+
+func (self *constantPool) long(val int64) uint16 {
+	return self.add(fmt.Sprintf("Long:%d", val), constantLong, func(writer *byteWriter) {
+		writer.writeUint64(uint64(val))
+	})
+}
+
+func (self *constantPool) double(val float64) uint16 {
+	bits := math.Float64bits(val)
+	return self.add(fmt.Sprintf("Double:%x", bits), constantDouble, func(writer *byteWriter) {
+		writer.writeUint64(bits)
+	})
+}
+
+func (self *constantPool) class(name string) uint16 {
+	nameIndex := self.utf8(name)
+	return self.add("Class:"+name, constantClass, func(writer *byteWriter) {
+		writer.writeUint16(nameIndex)
+	})
+}
+
+func (self *constantPool) string(str string) uint16 {
+	stringIndex := self.utf8(str)
+	return self.add("String:"+str, constantString, func(writer *byteWriter) {
+		writer.writeUint16(stringIndex)
+	})
+}
+
+func (self *constantPool) nameAndType(name, descriptor string) uint16 {
+	nameIndex := self.utf8(name)
+	descriptorIndex := self.utf8(descriptor)
+	return self.add("NameAndType:"+name+":"+descriptor, constantNameAndType, func(writer *byteWriter) {
+		writer.writeUint16(nameIndex)
+		writer.writeUint16(descriptorIndex)
+	})
+}
+
+// memberref 添加字段符号引用、方法符号引用或者接口方法符号引用
+func (self *constantPool) memberref(tag uint8, owner, name, descriptor string) uint16 {
+	classIndex := self.class(owner)
+	nameAndTypeIndex := self.nameAndType(name, descriptor)
+	key := fmt.Sprintf("%d:%s.%s:%s", tag, owner, name, descriptor)
+	return self.add(key, tag, func(writer *byteWriter) {
+		writer.writeUint16(classIndex)
+		writer.writeUint16(nameAndTypeIndex)
+	})
+}
+
+// constant 添加ldc指令或者ConstantValue属性使用的常量
+func (self *constantPool) constant(val interface{}) uint16 {
+	switch x := val.(type) {
+	case int32:
+		return self.integer(x)
+	case float32:
+		return self.float(x)
+	case int64:
+		return self.long(x)
+	case float64:
+		return self.double(x)
+	case string:
+		return self.string(x)
+	default:
+		panic(fmt.Sprintf("asm: unsupported constant: %v(%T)", val, val))
+	}
+}
+
+func (self *constantPool) write(writer *byteWriter) {
+	writer.writeUint16(self.count)
+	writer.writeBytes(self.writer.data)
+}
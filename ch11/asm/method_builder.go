@@ -0,0 +1,477 @@
+package asm
+
+import "fmt"
+
+// newarray指令的atype操作数
+const (
+	T_BOOLEAN = 4
+	T_CHAR    = 5
+	T_FLOAT   = 6
+	T_DOUBLE  = 7
+	T_BYTE    = 8
+	T_SHORT   = 9
+	T_INT     = 10
+	T_LONG    = 11
+)
+
+/*
+Label 表示字节码中的位置，可以先作为跳转目标使用，之后再用Mark()标记
+零值的Label也可以使用，和NewLabel()返回的一样是没有标记的
+*/
+type Label struct {
+	marked    bool
+	insnIndex int // 标签后面第一条指令的下标
+}
+
+type instruction struct {
+	opcode   uint8
+	wide     bool
+	operands []byte   // 除跳转偏移之外的操作数
+	delta    int      // 指令执行后操作数栈深度的变化
+	targets  []*Label // 跳转目标，switch指令的第一个是default
+	keys     []int32  // lookupswitch的match，tableswitch的low和high
+	pc       int
+}
+
+type exceptionHandler struct {
+	start, end, handler *Label
+	catchType           uint16
+}
+
+// 调试信息，分别生成LineNumberTable和LocalVariableTable属性
+type lineNumber struct {
+	start *Label
+	line  uint16
+}
+
+type localVariable struct {
+	start, end      *Label
+	nameIndex       uint16
+	descriptorIndex uint16
+	index           uint16
+}
+
+type MethodBuilder struct {
+	cp              *constantPool
+	accessFlags     uint16
+	name            string
+	descriptor      string
+	nameIndex       uint16
+	descriptorIndex uint16
+	insns           []*instruction
+	handlers        []*exceptionHandler
+	lineNumbers     []*lineNumber
+	localVariables  []*localVariable
+	maxLocals       int
+}
+
+func newMethodBuilder(cp *constantPool, accessFlags uint16, name, descriptor string) *MethodBuilder {
+	argSlots, _ := methodSlots(descriptor)
+	if accessFlags&ACC_STATIC == 0 {
+		argSlots++ // this
+	}
+	return &MethodBuilder{
+		cp:              cp,
+		accessFlags:     accessFlags,
+		name:            name,
+		descriptor:      descriptor,
+		nameIndex:       cp.utf8(name),
+		descriptorIndex: cp.utf8(descriptor),
+		maxLocals:       argSlots,
+	}
+}
+
+func (self *MethodBuilder) NewLabel() *Label {
+	return &Label{}
+}
+
+// Mark 把标签标记在下一条指令的位置
+func (self *MethodBuilder) Mark(label *Label) {
+	if label.marked {
+		panic("asm: label already marked")
+	}
+	label.marked = true
+	label.insnIndex = len(self.insns)
+}
+
+func (self *MethodBuilder) emit(insn *instruction) {
+	self.insns = append(self.insns, insn)
+}
+
+// 记录用到的局部变量，用来计算max_locals
+func (self *MethodBuilder) useLocal(index uint, size int) {
+	if int(index)+size > self.maxLocals {
+		self.maxLocals = int(index) + size
+	}
+}
+
+// Insn 生成没有操作数的指令，比如iadd和return
+func (self *MethodBuilder) Insn(opcode uint8) {
+	delta, ok := stackDelta(opcode)
+	if !ok || hasOperands(opcode) {
+		panic(fmt.Sprintf("asm: opcode 0x%02x has operands", opcode))
+	}
+	switch {
+	case opcode >= ILOAD_0 && opcode <= ALOAD_3:
+		n := opcode - ILOAD_0
+		self.useLocal(uint(n%4), localSize(ILOAD+n/4))
+	case opcode >= ISTORE_0 && opcode <= ASTORE_3:
+		n := opcode - ISTORE_0
+		self.useLocal(uint(n%4), localSize(ISTORE+n/4))
+	}
+	self.emit(&instruction{opcode: opcode, delta: delta})
+}
+
+// IntInsn 生成bipush、sipush和newarray指令
+func (self *MethodBuilder) IntInsn(opcode uint8, operand int) {
+	insn := &instruction{opcode: opcode}
+	switch opcode {
+	case BIPUSH:
+		insn.operands = []byte{byte(int8(operand))}
+		insn.delta = 1
+	case SIPUSH:
+		insn.operands = []byte{byte(operand >> 8), byte(operand)}
+		insn.delta = 1
+	case NEWARRAY:
+		insn.operands = []byte{byte(operand)}
+	default:
+		panic(fmt.Sprintf("asm: not an int instruction: 0x%02x", opcode))
+	}
+	self.emit(insn)
+}
+
+// VarInsn 生成访问局部变量的指令，索引大于255时自动加上wide前缀
+func (self *MethodBuilder) VarInsn(opcode uint8, index uint) {
+	if !(opcode >= ILOAD && opcode <= ALOAD) && !(opcode >= ISTORE && opcode <= ASTORE) {
+		panic(fmt.Sprintf("asm: not a local variable instruction: 0x%02x", opcode))
+	}
+	insn := &instruction{opcode: opcode}
+	insn.delta, _ = stackDelta(opcode)
+	if index > 0xff {
+		insn.wide = true
+		insn.operands = []byte{byte(index >> 8), byte(index)}
+	} else {
+		insn.operands = []byte{byte(index)}
+	}
+	self.useLocal(index, localSize(opcode))
+	self.emit(insn)
+}
+
+// IincInsn 生成iinc指令，索引或者增量超出一个字节时自动加上wide前缀
+func (self *MethodBuilder) IincInsn(index uint, increment int) {
+	insn := &instruction{opcode: IINC}
+	if index > 0xff || increment < -128 || increment > 127 {
+		insn.wide = true
+		insn.operands = []byte{byte(index >> 8), byte(index), byte(increment >> 8), byte(increment)}
+	} else {
+		insn.operands = []byte{byte(index), byte(int8(increment))}
+	}
+	self.useLocal(index, 1)
+	self.emit(insn)
+}
+
+// JumpInsn 生成条件跳转、goto和goto_w指令(不支持jsr)
+func (self *MethodBuilder) JumpInsn(opcode uint8, label *Label) {
+	if !(opcode >= IFEQ && opcode <= GOTO) && opcode != IFNULL && opcode != IFNONNULL && opcode != GOTO_W {
+		panic(fmt.Sprintf("asm: not a jump instruction: 0x%02x", opcode))
+	}
+	delta, _ := stackDelta(opcode)
+	self.emit(&instruction{opcode: opcode, delta: delta, targets: []*Label{label}})
+}
+
+// Ldc 把常量推入操作数栈，val可以是int32、float32、int64、float64或者string
+func (self *MethodBuilder) Ldc(val interface{}) {
+	index := self.cp.constant(val)
+	switch val.(type) {
+	case int64, float64:
+		self.emit(&instruction{opcode: LDC2_W, delta: 2, operands: u2(index)})
+	default:
+		if index > 0xff {
+			self.emit(&instruction{opcode: LDC_W, delta: 1, operands: u2(index)})
+		} else {
+			self.emit(&instruction{opcode: LDC, delta: 1, operands: []byte{byte(index)}})
+		}
+	}
+}
+
+// TypeInsn 生成new、anewarray、checkcast和instanceof指令
+func (self *MethodBuilder) TypeInsn(opcode uint8, className string) {
+	switch opcode {
+	case NEW, ANEWARRAY, CHECKCAST, INSTANCEOF:
+		delta, _ := stackDelta(opcode)
+		self.emit(&instruction{opcode: opcode, delta: delta, operands: u2(self.cp.class(className))})
+	default:
+		panic(fmt.Sprintf("asm: not a type instruction: 0x%02x", opcode))
+	}
+}
+
+// FieldInsn 生成getstatic、putstatic、getfield和putfield指令
+func (self *MethodBuilder) FieldInsn(opcode uint8, owner, name, descriptor string) {
+	size := typeSize(descriptor)
+	var delta int
+	switch opcode {
+	case GETSTATIC:
+		delta = size
+	case PUTSTATIC:
+		delta = -size
+	case GETFIELD:
+		delta = size - 1
+	case PUTFIELD:
+		delta = -size - 1
+	default:
+		panic(fmt.Sprintf("asm: not a field instruction: 0x%02x", opcode))
+	}
+	index := self.cp.memberref(constantFieldref, owner, name, descriptor)
+	self.emit(&instruction{opcode: opcode, delta: delta, operands: u2(index)})
+}
+
+// MethodInsn 生成invokevirtual、invokespecial、invokestatic和invokeinterface指令
+func (self *MethodBuilder) MethodInsn(opcode uint8, owner, name, descriptor string) {
+	argSlots, returnSlots := methodSlots(descriptor)
+	if opcode != INVOKESTATIC {
+		argSlots++ // this
+	}
+	insn := &instruction{opcode: opcode, delta: returnSlots - argSlots}
+	switch opcode {
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC:
+		insn.operands = u2(self.cp.memberref(constantMethodref, owner, name, descriptor))
+	case INVOKEINTERFACE:
+		index := self.cp.memberref(constantInterfaceMethodref, owner, name, descriptor)
+		insn.operands = append(u2(index), byte(argSlots), 0)
+	default:
+		panic(fmt.Sprintf("asm: not a method instruction: 0x%02x", opcode))
+	}
+	self.emit(insn)
+}
+
+// MultiANewArrayInsn 生成multianewarray指令，descriptor是数组类的描述符
+func (self *MethodBuilder) MultiANewArrayInsn(descriptor string, dimensions uint8) {
+	operands := append(u2(self.cp.class(descriptor)), dimensions)
+	self.emit(&instruction{opcode: MULTIANEWARRAY, delta: 1 - int(dimensions), operands: operands})
+}
+
+// TableSwitchInsn 生成tableswitch指令，labels依次是low到high的跳转目标
+func (self *MethodBuilder) TableSwitchInsn(low, high int32, dflt *Label, labels ...*Label) {
+	if int64(high)-int64(low)+1 != int64(len(labels)) {
+		panic("asm: tableswitch needs high-low+1 labels")
+	}
+	self.emit(&instruction{
+		opcode:  TABLESWITCH,
+		delta:   -1,
+		targets: append([]*Label{dflt}, labels...),
+		keys:    []int32{low, high},
+	})
+}
+
+// LookupSwitchInsn 生成lookupswitch指令，keys会按照JVM规范的要求排序
+func (self *MethodBuilder) LookupSwitchInsn(dflt *Label, keys []int32, labels []*Label) {
+	if len(keys) != len(labels) {
+		panic("asm: lookupswitch needs a label for each key")
+	}
+	sortedKeys := append([]int32{}, keys...)
+	sortedLabels := append([]*Label{}, labels...)
+	for i := 1; i < len(sortedKeys); i++ { // 插入排序
+		for j := i; j > 0 && sortedKeys[j-1] > sortedKeys[j]; j-- {
+			sortedKeys[j-1], sortedKeys[j] = sortedKeys[j], sortedKeys[j-1]
+			sortedLabels[j-1], sortedLabels[j] = sortedLabels[j], sortedLabels[j-1]
+		}
+	}
+	self.emit(&instruction{
+		opcode:  LOOKUPSWITCH,
+		delta:   -1,
+		targets: append([]*Label{dflt}, sortedLabels...),
+		keys:    sortedKeys,
+	})
+}
+
+// TryCatch 添加异常处理项，catchType为空表示捕获所有异常(finally)
+func (self *MethodBuilder) TryCatch(start, end, handler *Label, catchType string) {
+	h := &exceptionHandler{start: start, end: end, handler: handler}
+	if catchType != "" {
+		h.catchType = self.cp.class(catchType)
+	}
+	self.handlers = append(self.handlers, h)
+}
+
+// LineNumber 从start开始的指令属于源文件的第line行
+func (self *MethodBuilder) LineNumber(line int, start *Label) {
+	self.lineNumbers = append(self.lineNumbers, &lineNumber{start: start, line: uint16(line)})
+}
+
+// LocalVariable 局部变量index在[start, end)范围内的名字和类型
+func (self *MethodBuilder) LocalVariable(name, descriptor string, start, end *Label, index uint) {
+	self.localVariables = append(self.localVariables, &localVariable{
+		start:           start,
+		end:             end,
+		nameIndex:       self.cp.utf8(name),
+		descriptorIndex: self.cp.utf8(descriptor),
+		index:           uint16(index),
+	})
+}
+
+func u2(val uint16) []byte {
+	return []byte{byte(val >> 8), byte(val)}
+}
+
+func (self *MethodBuilder) write(writer *byteWriter) {
+	writer.writeUint16(self.accessFlags)
+	writer.writeUint16(self.nameIndex)
+	writer.writeUint16(self.descriptorIndex)
+	if self.accessFlags&(ACC_NATIVE|ACC_ABSTRACT) != 0 {
+		writer.writeUint16(0)
+		return
+	}
+	writer.writeUint16(1)
+	writer.writeAttribute(self.cp.utf8("Code"), self.codeAttribute())
+}
+
+// codeAttribute 生成Code属性的内容，格式见classfile包的CodeAttribute
+func (self *MethodBuilder) codeAttribute() []byte {
+	code := self.assemble()
+	maxStack := self.computeMaxStack()
+
+	writer := &byteWriter{}
+	writer.writeUint16(uint16(maxStack))
+	writer.writeUint16(uint16(self.maxLocals))
+	writer.writeUint32(uint32(len(code)))
+	writer.writeBytes(code)
+	writer.writeUint16(uint16(len(self.handlers)))
+	for _, h := range self.handlers {
+		writer.writeUint16(uint16(self.labelPC(h.start)))
+		writer.writeUint16(uint16(self.labelPC(h.end)))
+		writer.writeUint16(uint16(self.labelPC(h.handler)))
+		writer.writeUint16(h.catchType)
+	}
+	self.writeDebugAttributes(writer)
+	return writer.data
+}
+
+func (self *MethodBuilder) writeDebugAttributes(writer *byteWriter) {
+	count := 0
+	if len(self.lineNumbers) > 0 {
+		count++
+	}
+	if len(self.localVariables) > 0 {
+		count++
+	}
+	writer.writeUint16(uint16(count))
+	if len(self.lineNumbers) > 0 {
+		info := &byteWriter{}
+		info.writeUint16(uint16(len(self.lineNumbers)))
+		for _, ln := range self.lineNumbers {
+			info.writeUint16(uint16(self.labelPC(ln.start)))
+			info.writeUint16(ln.line)
+		}
+		writer.writeAttribute(self.cp.utf8("LineNumberTable"), info.data)
+	}
+	if len(self.localVariables) > 0 {
+		info := &byteWriter{}
+		info.writeUint16(uint16(len(self.localVariables)))
+		for _, lv := range self.localVariables {
+			startPC := self.labelPC(lv.start)
+			info.writeUint16(uint16(startPC))
+			info.writeUint16(uint16(self.labelPC(lv.end) - startPC))
+			info.writeUint16(lv.nameIndex)
+			info.writeUint16(lv.descriptorIndex)
+			info.writeUint16(lv.index)
+		}
+		writer.writeAttribute(self.cp.utf8("LocalVariableTable"), info.data)
+	}
+}
+
+func (self *MethodBuilder) labelPC(label *Label) int {
+	if !label.marked {
+		panic(fmt.Sprintf("asm: label not marked in %s%s", self.name, self.descriptor))
+	}
+	if label.insnIndex == len(self.insns) {
+		return self.codeLength()
+	}
+	return self.insns[label.insnIndex].pc
+}
+
+func (self *MethodBuilder) codeLength() int {
+	if len(self.insns) == 0 {
+		return 0
+	}
+	last := self.insns[len(self.insns)-1]
+	return last.pc + last.size()
+}
+
+// size 返回指令的长度，switch指令的长度和它的pc有关(操作数按4字节对齐)
+func (self *instruction) size() int {
+	switch self.opcode {
+	case TABLESWITCH:
+		return 1 + self.padding() + 12 + 4*(len(self.targets)-1)
+	case LOOKUPSWITCH:
+		return 1 + self.padding() + 8 + 8*len(self.keys)
+	case GOTO_W:
+		return 5
+	}
+	if self.targets != nil {
+		return 3
+	}
+	if self.wide {
+		return 2 + len(self.operands)
+	}
+	return 1 + len(self.operands)
+}
+
+func (self *instruction) padding() int {
+	return (4 - (self.pc+1)%4) % 4
+}
+
+// assemble 先计算每条指令的pc，然后生成字节码
+func (self *MethodBuilder) assemble() []byte {
+	pc := 0
+	for _, insn := range self.insns {
+		insn.pc = pc
+		pc += insn.size()
+	}
+	if pc > 0xffff {
+		panic(fmt.Sprintf("asm: code too large in %s%s", self.name, self.descriptor))
+	}
+
+	writer := &byteWriter{}
+	for _, insn := range self.insns {
+		if insn.wide {
+			writer.writeUint8(WIDE)
+		}
+		writer.writeUint8(insn.opcode)
+		switch insn.opcode {
+		case TABLESWITCH, LOOKUPSWITCH:
+			writer.writeBytes(make([]byte, insn.padding()))
+			writer.writeUint32(uint32(self.offset(insn, insn.targets[0])))
+			if insn.opcode == TABLESWITCH {
+				writer.writeUint32(uint32(insn.keys[0]))
+				writer.writeUint32(uint32(insn.keys[1]))
+				for _, label := range insn.targets[1:] {
+					writer.writeUint32(uint32(self.offset(insn, label)))
+				}
+			} else {
+				writer.writeUint32(uint32(len(insn.keys)))
+				for i, key := range insn.keys {
+					writer.writeUint32(uint32(key))
+					writer.writeUint32(uint32(self.offset(insn, insn.targets[i+1])))
+				}
+			}
+		case GOTO_W:
+			writer.writeUint32(uint32(self.offset(insn, insn.targets[0])))
+		default:
+			if insn.targets != nil {
+				offset := self.offset(insn, insn.targets[0])
+				if offset < -32768 || offset > 32767 {
+					panic(fmt.Sprintf("asm: jump offset too large at pc %d, use GOTO_W", insn.pc))
+				}
+				writer.writeUint16(uint16(offset))
+			} else {
+				writer.writeBytes(insn.operands)
+			}
+		}
+	}
+	return writer.data
+}
+
+// 跳转偏移相对于跳转指令本身的pc
+func (self *MethodBuilder) offset(insn *instruction, label *Label) int32 {
+	return int32(self.labelPC(label) - insn.pc)
+}
@@ -0,0 +1,168 @@
+package asm
+
+import "fmt"
+
+const unknownDelta = 127
+
+// 指令执行后操作数栈深度(以slot为单位)的变化，字段和方法指令的变化取决于描述符
+var stackDeltas [256]int8
+
+func init() {
+	for i := range stackDeltas {
+		stackDeltas[i] = unknownDelta
+	}
+	set := func(delta int8, opcodes ...uint8) {
+		for _, opcode := range opcodes {
+			stackDeltas[opcode] = delta
+		}
+	}
+	set(0, NOP, IINC, SWAP, INEG, LNEG, FNEG, DNEG, I2F, L2D, F2I, D2L, I2B, I2C, I2S,
+		GOTO, GOTO_W, RETURN, NEWARRAY, ANEWARRAY, ARRAYLENGTH, CHECKCAST, INSTANCEOF,
+		LALOAD, DALOAD)
+	set(1, ACONST_NULL, ICONST_M1, ICONST_0, ICONST_1, ICONST_2, ICONST_3, ICONST_4, ICONST_5,
+		FCONST_0, FCONST_1, FCONST_2, BIPUSH, SIPUSH, LDC, LDC_W,
+		ILOAD, FLOAD, ALOAD, ILOAD_0, ILOAD_1, ILOAD_2, ILOAD_3, FLOAD_0, FLOAD_1, FLOAD_2, FLOAD_3,
+		ALOAD_0, ALOAD_1, ALOAD_2, ALOAD_3, DUP, DUP_X1, DUP_X2, I2L, I2D, F2L, F2D, NEW)
+	set(2, LCONST_0, LCONST_1, DCONST_0, DCONST_1, LDC2_W, LLOAD, DLOAD,
+		LLOAD_0, LLOAD_1, LLOAD_2, LLOAD_3, DLOAD_0, DLOAD_1, DLOAD_2, DLOAD_3, DUP2, DUP2_X1, DUP2_X2)
+	set(-1, IALOAD, FALOAD, AALOAD, BALOAD, CALOAD, SALOAD,
+		ISTORE, FSTORE, ASTORE, ISTORE_0, ISTORE_1, ISTORE_2, ISTORE_3, FSTORE_0, FSTORE_1, FSTORE_2, FSTORE_3,
+		ASTORE_0, ASTORE_1, ASTORE_2, ASTORE_3, POP,
+		IADD, ISUB, IMUL, IDIV, IREM, FADD, FSUB, FMUL, FDIV, FREM,
+		ISHL, LSHL, ISHR, LSHR, IUSHR, LUSHR, IAND, IOR, IXOR, L2I, L2F, D2I, D2F, FCMPL, FCMPG,
+		IFEQ, IFNE, IFLT, IFGE, IFGT, IFLE, IFNULL, IFNONNULL, TABLESWITCH, LOOKUPSWITCH,
+		IRETURN, FRETURN, ARETURN, ATHROW, MONITORENTER, MONITOREXIT)
+	set(-2, LSTORE, DSTORE, LSTORE_0, LSTORE_1, LSTORE_2, LSTORE_3, DSTORE_0, DSTORE_1, DSTORE_2, DSTORE_3,
+		POP2, LADD, LSUB, LMUL, LDIV, LREM, DADD, DSUB, DMUL, DDIV, DREM, LAND, LOR, LXOR,
+		IF_ICMPEQ, IF_ICMPNE, IF_ICMPLT, IF_ICMPGE, IF_ICMPGT, IF_ICMPLE, IF_ACMPEQ, IF_ACMPNE,
+		LRETURN, DRETURN)
+	set(-3, IASTORE, FASTORE, AASTORE, BASTORE, CASTORE, SASTORE, LCMP, DCMPL, DCMPG)
+	set(-4, LASTORE, DASTORE)
+}
+
+func stackDelta(opcode uint8) (int, bool) {
+	delta := stackDeltas[opcode]
+	return int(delta), delta != unknownDelta
+}
+
+// hasOperands 判断指令后面是否有操作数
+func hasOperands(opcode uint8) bool {
+	switch {
+	case opcode >= BIPUSH && opcode <= ALOAD,
+		opcode >= ISTORE && opcode <= ASTORE,
+		opcode >= IFEQ && opcode <= LOOKUPSWITCH,
+		opcode >= GETSTATIC && opcode <= ANEWARRAY,
+		opcode >= WIDE && opcode <= JSR_W:
+		return true
+	}
+	return opcode == IINC || opcode == CHECKCAST || opcode == INSTANCEOF
+}
+
+// 执行后不会继续执行下一条指令
+func isTerminal(opcode uint8) bool {
+	switch opcode {
+	case GOTO, GOTO_W, TABLESWITCH, LOOKUPSWITCH, ATHROW,
+		IRETURN, LRETURN, FRETURN, DRETURN, ARETURN, RETURN:
+		return true
+	}
+	return false
+}
+
+// long和double类型的局部变量占两个slot
+func localSize(opcode uint8) int {
+	switch opcode {
+	case LLOAD, DLOAD, LSTORE, DSTORE:
+		return 2
+	}
+	return 1
+}
+
+func typeSize(descriptor string) int {
+	switch descriptor[0] {
+	case 'J', 'D':
+		return 2
+	case 'V':
+		return 0
+	}
+	return 1
+}
+
+// methodSlots 计算方法参数和返回值占用的slot数
+func methodSlots(descriptor string) (argSlots, returnSlots int) {
+	if descriptor == "" || descriptor[0] != '(' {
+		panic("asm: bad method descriptor: " + descriptor)
+	}
+	i := 1
+	for i < len(descriptor) && descriptor[i] != ')' {
+		argSlots += typeSize(descriptor[i:])
+		for descriptor[i] == '[' {
+			i++
+		}
+		if descriptor[i] == 'L' {
+			for descriptor[i] != ';' {
+				i++
+			}
+		}
+		i++
+	}
+	if i+1 >= len(descriptor) {
+		panic("asm: bad method descriptor: " + descriptor)
+	}
+	return argSlots, typeSize(descriptor[i+1:])
+}
+
+/*
+computeMaxStack 从方法入口和每个异常处理器开始沿着控制流计算每条指令执行前的栈深度
+异常处理器开始执行时栈中只有异常对象，不同路径到达同一条指令时栈深度必须一致
+*/
+func (self *MethodBuilder) computeMaxStack() int {
+	depths := make([]int, len(self.insns)+1)
+	for i := range depths {
+		depths[i] = -1
+	}
+	maxStack := 0
+	var worklist []int
+	visit := func(index, depth int) {
+		if depths[index] == -1 {
+			depths[index] = depth
+			worklist = append(worklist, index)
+		} else if depths[index] != depth {
+			panic(fmt.Sprintf("asm: inconsistent stack depth (%d, %d) at instruction %d in %s%s",
+				depths[index], depth, index, self.name, self.descriptor))
+		}
+		if depth > maxStack {
+			maxStack = depth
+		}
+	}
+
+	if len(self.insns) > 0 {
+		visit(0, 0)
+	}
+	for _, h := range self.handlers {
+		self.labelPC(h.handler) // 检查标签已经标记
+		visit(h.handler.insnIndex, 1)
+	}
+	for len(worklist) > 0 {
+		index := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		if index == len(self.insns) {
+			panic(fmt.Sprintf("asm: execution falls off the end of %s%s", self.name, self.descriptor))
+		}
+		insn := self.insns[index]
+		depth := depths[index] + insn.delta
+		if depth < 0 {
+			panic(fmt.Sprintf("asm: stack underflow at pc %d in %s%s", insn.pc, self.name, self.descriptor))
+		}
+		if !isTerminal(insn.opcode) {
+			visit(index+1, depth)
+		}
+		for _, label := range insn.targets {
+			self.labelPC(label)
+			visit(label.insnIndex, depth)
+		}
+		if depth > maxStack {
+			maxStack = depth
+		}
+	}
+	return maxStack
+}
@@ -1,6 +1,7 @@
 package classfile
 
 type BootstrapMethodsAttribute struct {
+	attrHeader
 	bootstrapMethods []*BootstrapMethod
 }
 
@@ -15,6 +16,14 @@ func (self *BootstrapMethodsAttribute) readInfo(reader *ClassReader) {
 	}
 }
 
+func (self *BootstrapMethodsAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.bootstrapMethods)))
+	for _, bootstrapMethod := range self.bootstrapMethods {
+		writer.writeUint16(bootstrapMethod.bootstrapMethodRef)
+		writer.writeUint16s(bootstrapMethod.bootstrapArguments)
+	}
+}
+
 type BootstrapMethod struct {
 	bootstrapMethodRef uint16
 	bootstrapArguments []uint16
@@ -1,6 +1,7 @@
 package classfile
 
 type CodeAttribute struct {
+	attrHeader
 	cp             ConstantPool
 	maxStack       uint16
 	maxLocals      uint16
@@ -25,6 +26,15 @@ func (self *CodeAttribute) readInfo(reader *ClassReader) {
 	self.attributes = readAttributes(reader, self.cp)
 }
 
+func (self *CodeAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.maxStack)
+	writer.writeUint16(self.maxLocals)
+	writer.writeUint32(uint32(len(self.code)))
+	writer.writeBytes(self.code)
+	writeExceptionTable(writer, self.exceptionTable)
+	writeAttributes(writer, self.attributes)
+}
+
 func (self *CodeAttribute) MaxStack() uint {
 	return uint(self.maxStack)
 }
@@ -37,6 +47,9 @@ func (self *CodeAttribute) Code() []byte {
 func (self *CodeAttribute) ExceptionTable() []*ExceptionTableEntry {
 	return self.exceptionTable
 }
+func (self *CodeAttribute) Attributes() []AttributeInfo {
+	return self.attributes
+}
 
 func readExceptionTable(reader *ClassReader) []*ExceptionTableEntry {
 	exceptionTableLength := reader.readUint16() //异常表长度
@@ -52,6 +65,16 @@ func readExceptionTable(reader *ClassReader) []*ExceptionTableEntry {
 	return exceptionTable
 }
 
+func writeExceptionTable(writer *ClassWriter, exceptionTable []*ExceptionTableEntry) {
+	writer.writeUint16(uint16(len(exceptionTable)))
+	for _, entry := range exceptionTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.endPc)
+		writer.writeUint16(entry.handlerPc)
+		writer.writeUint16(entry.catchType)
+	}
+}
+
 func (self *ExceptionTableEntry) StartPc() uint16 {
 	return self.startPc
 }
@@ -74,3 +97,17 @@ func (self *CodeAttribute) LineNumberTableAttribute() *LineNumberTableAttribute
 	}
 	return nil
 }
+
+func (self *CodeAttribute) LocalVariableTableAttribute() *LocalVariableTableAttribute {
+	for _, attrInfo := range self.attributes {
+		if attr, ok := attrInfo.(*LocalVariableTableAttribute); ok {
+			return attr
+		}
+	}
+	return nil
+}
+
+// ConstantPool 属性中的名字和描述符都是常量池索引
+func (self *CodeAttribute) ConstantPool() ConstantPool {
+	return self.cp
+}
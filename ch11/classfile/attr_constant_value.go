@@ -1,6 +1,7 @@
 package classfile
 
 type ConstantValueAttribute struct {
+	attrHeader
 	constantValueIndex uint16
 }
 
@@ -8,6 +9,10 @@ func (self *ConstantValueAttribute) readInfo(reader *ClassReader) {
 	self.constantValueIndex = reader.readUint16()
 }
 
+func (self *ConstantValueAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.constantValueIndex)
+}
+
 func (self *ConstantValueAttribute) ConstantValueIndex() uint16 {
 	return self.constantValueIndex
 }
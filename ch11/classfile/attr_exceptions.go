@@ -5,6 +5,7 @@ Exceptions是边长属性，记录 方法抛出的异常表
 */
 
 type ExceptionsAttribute struct {
+	attrHeader
 	exceptionIndexTable []uint16
 }
 
@@ -12,6 +13,10 @@ func (self *ExceptionsAttribute) readInfo(reader *ClassReader) {
 	self.exceptionIndexTable = reader.readUint16s()
 }
 
+func (self *ExceptionsAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16s(self.exceptionIndexTable)
+}
+
 func (self *ExceptionsAttribute) ExceptionIndexTable() []uint16 {
 	return self.exceptionIndexTable
 }
@@ -1,6 +1,7 @@
 package classfile
 
 type LineNumberTableAttribute struct {
+	attrHeader
 	lineNumberTable []*LineNumberTableEntry
 }
 
@@ -20,6 +21,25 @@ func (self *LineNumberTableAttribute) readInfo(reader *ClassReader) {
 	}
 }
 
+func (self *LineNumberTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.lineNumberTable)))
+	for _, entry := range self.lineNumberTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.lineNumber)
+	}
+}
+
+func (self *LineNumberTableAttribute) LineNumberTable() []*LineNumberTableEntry {
+	return self.lineNumberTable
+}
+
+func (self *LineNumberTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *LineNumberTableEntry) LineNumber() uint16 {
+	return self.lineNumber
+}
+
 func (self *LineNumberTableAttribute) GetLineNumber(pc int) int {
 	for i := len(self.lineNumberTable) - 1; i >= 0; i-- {
 		entry := self.lineNumberTable[i]
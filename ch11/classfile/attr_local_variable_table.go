@@ -1,6 +1,7 @@
 package classfile
 
 type LocalVariableTableAttribute struct {
+	attrHeader
 	localVariableTable []*LocalVariableTableEntry
 }
 
@@ -25,3 +26,34 @@ func (self *LocalVariableTableAttribute) readInfo(reader *ClassReader) {
 		}
 	}
 }
+
+func (self *LocalVariableTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.localVariableTable)))
+	for _, entry := range self.localVariableTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.length)
+		writer.writeUint16(entry.nameIndex)
+		writer.writeUint16(entry.descriptorIndex)
+		writer.writeUint16(entry.index)
+	}
+}
+
+func (self *LocalVariableTableAttribute) LocalVariableTable() []*LocalVariableTableEntry {
+	return self.localVariableTable
+}
+
+func (self *LocalVariableTableEntry) StartPc() uint16 {
+	return self.startPc
+}
+func (self *LocalVariableTableEntry) Length() uint16 {
+	return self.length
+}
+func (self *LocalVariableTableEntry) NameIndex() uint16 {
+	return self.nameIndex
+}
+func (self *LocalVariableTableEntry) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+func (self *LocalVariableTableEntry) Index() uint16 {
+	return self.index
+}
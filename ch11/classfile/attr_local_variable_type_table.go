@@ -1,6 +1,7 @@
 package classfile
 
 type LocalVariableTypeTableAttribute struct {
+	attrHeader
 	localVariableTypeTable []*LocalVariableTypeTableEntry
 }
 
@@ -25,3 +26,14 @@ func (self *LocalVariableTypeTableAttribute) readInfo(reader *ClassReader) {
 		}
 	}
 }
+
+func (self *LocalVariableTypeTableAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(uint16(len(self.localVariableTypeTable)))
+	for _, entry := range self.localVariableTypeTable {
+		writer.writeUint16(entry.startPc)
+		writer.writeUint16(entry.length)
+		writer.writeUint16(entry.nameIndex)
+		writer.writeUint16(entry.signatureIndex)
+		writer.writeUint16(entry.index)
+	}
+}
@@ -9,8 +9,13 @@ type SyntheticAttribute struct {
 }
 
 type MarkerAttribute struct {
+	attrHeader
 }
 
 func (self *MarkerAttribute) readInfo(reader *ClassReader) {
 	// read nothing
 }
+
+func (self *MarkerAttribute) writeInfo(writer *ClassWriter) {
+	// write nothing
+}
@@ -1,6 +1,7 @@
 package classfile
 
 type SourceFileAttribute struct {
+	attrHeader
 	cp              ConstantPool
 	sourceFileIndex uint16
 }
@@ -9,6 +10,14 @@ func (self *SourceFileAttribute) readInfo(reader *ClassReader) {
 	self.sourceFileIndex = reader.readUint16()
 }
 
+func (self *SourceFileAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.sourceFileIndex)
+}
+
+func (self *SourceFileAttribute) SourceFileIndex() uint16 {
+	return self.sourceFileIndex
+}
+
 func (self *SourceFileAttribute) FileName() string {
 	return self.cp.getUtf8(self.sourceFileIndex)
 }
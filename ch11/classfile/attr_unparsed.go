@@ -1,6 +1,7 @@
 package classfile
 
 type UnparsedAttribute struct {
+	attrHeader
 	name   string
 	length uint32
 	info   []byte
@@ -10,6 +11,15 @@ func (self *UnparsedAttribute) readInfo(reader *ClassReader) {
 	self.info = reader.readBytes(self.length)
 }
 
+// 不认识的属性原样写回
+func (self *UnparsedAttribute) writeInfo(writer *ClassWriter) {
+	writer.writeBytes(self.info)
+}
+
+func (self *UnparsedAttribute) Name() string {
+	return self.name
+}
+
 func (self *UnparsedAttribute) Info() []byte {
 	return self.info
 }
@@ -8,6 +8,20 @@ package classfile
 
 type AttributeInfo interface {
 	readInfo(reader *ClassReader)
+	writeInfo(writer *ClassWriter)
+	header() *attrHeader
+}
+
+/*
+attrHeader 嵌入到每种属性中，记录读取时的属性名索引
+写回时原样使用这个索引，常量池中有重复的字符串时也能得到和原来一样的字节
+*/
+type attrHeader struct {
+	nameIndex uint16
+}
+
+func (self *attrHeader) header() *attrHeader {
+	return self
 }
 
 /*
@@ -27,6 +41,7 @@ func readAttribute(reader *ClassReader, cp ConstantPool) AttributeInfo {
 	attrName := cp.getUtf8(attrNameIndex)
 	attrLen := reader.readUint32()                      //读取属性长度
 	attrInfo := newAttributeInfo(attrName, attrLen, cp) //创建对应属性实例
+	attrInfo.header().nameIndex = attrNameIndex
 	attrInfo.readInfo(reader)
 	return attrInfo
 }
@@ -50,7 +65,26 @@ func newAttributeInfo(attrName string, attrLen uint32, cp ConstantPool) Attribut
 	case "Synthetic":
 		return &SyntheticAttribute{}
 	default:
-		return &UnparsedAttribute{attrName, attrLen, nil}
+		return &UnparsedAttribute{name: attrName, length: attrLen}
 	}
 
 }
+
+/*
+writeAttributes()函数写属性表，和readAttributes()对应
+*/
+func writeAttributes(writer *ClassWriter, attributes []AttributeInfo) {
+	writer.writeUint16(uint16(len(attributes)))
+	for _, attrInfo := range attributes {
+		writeAttribute(writer, attrInfo)
+	}
+}
+
+// 属性的长度要等属性内容写完才知道，所以先写到单独的ClassWriter中
+func writeAttribute(writer *ClassWriter, attrInfo AttributeInfo) {
+	infoWriter := &ClassWriter{}
+	attrInfo.writeInfo(infoWriter)
+	writer.writeUint16(attrInfo.header().nameIndex)
+	writer.writeUint32(uint32(len(infoWriter.data)))
+	writer.writeBytes(infoWriter.data)
+}
@@ -106,6 +106,22 @@ func (self *ClassFile) Methods() []*MemberInfo {
 	return self.methods
 }
 
+func (self *ClassFile) ThisClass() uint16 {
+	return self.thisClass
+}
+
+func (self *ClassFile) SuperClass() uint16 {
+	return self.superClass
+}
+
+func (self *ClassFile) Interfaces() []uint16 {
+	return self.interfaces
+}
+
+func (self *ClassFile) Attributes() []AttributeInfo {
+	return self.attributes
+}
+
 /*
 从常量池查找类名
 */
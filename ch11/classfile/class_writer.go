@@ -0,0 +1,82 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+ClassWriter和ClassReader相反，把数据按大端序追加到[]byte后面
+*/
+type ClassWriter struct {
+	data []byte
+}
+
+func (self *ClassWriter) writeUint8(val uint8) {
+	self.data = append(self.data, val)
+}
+
+func (self *ClassWriter) writeUint16(val uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *ClassWriter) writeUint32(val uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *ClassWriter) writeUint64(val uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+/*
+writeUint16s()先写表的大小，再写表的内容，和readUint16s()对应
+*/
+func (self *ClassWriter) writeUint16s(s []uint16) {
+	self.writeUint16(uint16(len(s)))
+	for _, val := range s {
+		self.writeUint16(val)
+	}
+}
+
+func (self *ClassWriter) writeBytes(bytes []byte) {
+	self.data = append(self.data, bytes...)
+}
+
+/*
+Write()函数把ClassFile结构体转换成[]byte，是Parse()的逆过程
+解析得到的ClassFile原样写回时，得到的字节和原来的class文件完全一样
+*/
+func Write(cf *ClassFile) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	cw := &ClassWriter{}
+	cf.write(cw)
+	return cw.data, nil
+}
+
+func (self *ClassFile) write(writer *ClassWriter) {
+	writer.writeUint32(0xCAFEBABE)
+	writer.writeUint16(self.minorVersion)
+	writer.writeUint16(self.majorVersion)
+	writeConstantPool(writer, self.constantPool)
+	writer.writeUint16(self.accessFlags)
+	writer.writeUint16(self.thisClass)
+	writer.writeUint16(self.superClass)
+	writer.writeUint16s(self.interfaces)
+	writeMembers(writer, self.fields)
+	writeMembers(writer, self.methods)
+	writeAttributes(writer, self.attributes)
+}
@@ -0,0 +1,241 @@
+package classfile_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/classfile"
+)
+
+// roundTrip 解析data再写回，写回的字节必须和原来完全一样
+func roundTrip(t *testing.T, data []byte) {
+	t.Helper()
+	cf, err := classfile.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	out, err := classfile.Write(cf)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !bytes.Equal(out, data) {
+		for i := range data {
+			if i >= len(out) || out[i] != data[i] {
+				t.Fatalf("output differs at byte %d (len %d, want %d)", i, len(out), len(data))
+			}
+		}
+		t.Fatalf("output has %d extra bytes", len(out)-len(data))
+	}
+}
+
+func TestWriteRoundTripAssembledClass(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "RoundTrip", "java/lang/Object", "java/lang/Runnable")
+	cb.SetSourceFile("RoundTrip.java")
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_FINAL, "I", "I").SetConstantValue(int32(42))
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_FINAL, "J", "J").SetConstantValue(int64(1) << 40)
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_FINAL, "F", "F").SetConstantValue(float32(1.5))
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_FINAL, "D", "D").SetConstantValue(2.25)
+	cb.AddField(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_FINAL, "S", "Ljava/lang/String;").SetConstantValue("hello")
+	cb.AddField(0, "ref", "Ljava/lang/Object;")
+
+	init := cb.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.RETURN)
+
+	run := cb.AddMethod(asm.ACC_PUBLIC, "run", "()V")
+	run.Insn(asm.RETURN)
+
+	sw := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "classify", "(I)I")
+	start, end := sw.NewLabel(), sw.NewLabel()
+	dflt, one, two, far := sw.NewLabel(), sw.NewLabel(), sw.NewLabel(), sw.NewLabel()
+	handler := sw.NewLabel()
+	sw.Mark(start)
+	sw.LineNumber(10, start)
+	sw.VarInsn(asm.ILOAD, 0)
+	sw.TableSwitchInsn(1, 2, dflt, one, two)
+	sw.Mark(one)
+	sw.VarInsn(asm.ILOAD, 0)
+	sw.LookupSwitchInsn(dflt, []int32{100, -5}, []*asm.Label{far, two})
+	sw.Mark(two)
+	sw.Ldc(int64(7))
+	sw.Insn(asm.L2I)
+	sw.Insn(asm.IRETURN)
+	sw.Mark(far)
+	sw.Ldc(3.5)
+	sw.Insn(asm.D2I)
+	sw.Insn(asm.IRETURN)
+	sw.Mark(dflt)
+	sw.Insn(asm.ICONST_M1)
+	sw.Mark(end)
+	sw.Insn(asm.IRETURN)
+	sw.Mark(handler)
+	sw.Insn(asm.POP)
+	sw.Insn(asm.ICONST_0)
+	sw.Insn(asm.IRETURN)
+	sw.TryCatch(start, end, handler, "java/lang/RuntimeException")
+	sw.TryCatch(start, end, handler, "")
+	sw.LocalVariable("x", "I", start, end, 0)
+
+	cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_NATIVE, "nativeMethod", "(JD)V")
+	cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_ABSTRACT, "abstractMethod", "()Ljava/lang/String;")
+
+	roundTrip(t, cb.Bytes())
+}
+
+func TestWriteRoundTripEmptyClass(t *testing.T) {
+	cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Empty", "java/lang/Object")
+	cb.SetVersion(49, 0)
+	roundTrip(t, cb.Bytes())
+}
+
+// rawClass 按字节构造class文件，用来测试编译器不会生成的常量池
+type rawClass struct {
+	data []byte
+}
+
+func (self *rawClass) u1(val uint8) {
+	self.data = append(self.data, val)
+}
+
+func (self *rawClass) u2(val uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *rawClass) u4(val uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], val)
+	self.data = append(self.data, buf[:]...)
+}
+
+func (self *rawClass) utf8(str string) {
+	self.u1(1) // CONSTANT_Utf8
+	self.u2(uint16(len(str)))
+	self.data = append(self.data, str...)
+}
+
+func TestWriteKeepsAttributeNameIndex(t *testing.T) {
+	c := &rawClass{}
+	c.u4(0xCAFEBABE)
+	c.u2(0)
+	c.u2(52)
+	c.u2(10)                   // constant_pool_count
+	c.utf8("Dup")              // #1
+	c.u1(7)                    // #2 CONSTANT_Class
+	c.u2(1)                    //
+	c.utf8("java/lang/Object") // #3
+	c.u1(7)                    // #4 CONSTANT_Class
+	c.u2(3)                    //
+	c.utf8("SourceFile")       // #5
+	c.utf8("Dup.java")         // #6
+	c.utf8("SourceFile")       // #7 和#5重复
+	c.utf8("Vendor")           // #8 虚拟机不认识的属性
+	c.utf8("Vendor")           // #9 和#8重复
+	c.u2(asm.ACC_PUBLIC | asm.ACC_SUPER)
+	c.u2(2)
+	c.u2(4)
+	c.u2(0) // interfaces
+	c.u2(0) // fields
+	c.u2(0) // methods
+	c.u2(2) // attributes
+	c.u2(7)
+	c.u4(2)
+	c.u2(6)
+	c.u2(9)
+	c.u4(3)
+	c.data = append(c.data, 1, 2, 3)
+
+	roundTrip(t, c.data)
+
+	cf, err := classfile.Parse(c.data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := cf.SourceFileAttribute().FileName(); name != "Dup.java" {
+		t.Errorf("source file = %q, want Dup.java", name)
+	}
+}
+
+// rtJar 找到JDK8的rt.jar，找不到时返回空字符串
+func rtJar() string {
+	javaHome := os.Getenv("JAVA_HOME")
+	if javaHome == "" {
+		return ""
+	}
+	for _, path := range []string{
+		filepath.Join(javaHome, "jre", "lib", "rt.jar"),
+		filepath.Join(javaHome, "lib", "rt.jar"), // JAVA_HOME指向jre
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// 解析jar中的每一个class文件再写回，必须和原来的字节完全一样
+// 测试testdata中的jar和JAVA_HOME中的rt.jar，都没有时跳过
+func TestWriteRoundTripJar(t *testing.T) {
+	jars, _ := filepath.Glob(filepath.Join("testdata", "*.jar"))
+	if rt := rtJar(); rt != "" {
+		jars = append(jars, rt)
+	}
+	if len(jars) == 0 {
+		t.Skip("no jar in testdata and no rt.jar in JAVA_HOME")
+	}
+	for _, jar := range jars {
+		roundTripJar(t, jar)
+	}
+}
+
+func roundTripJar(t *testing.T, jar string) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	classes, failures := 0, 0
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			t.Fatalf("%s!%s: %v", jar, f.Name, err)
+		}
+		classes++
+		cf, err := classfile.Parse(data)
+		if err != nil {
+			t.Errorf("%s!%s: Parse: %v", jar, f.Name, err)
+			failures++
+		} else if out, err := classfile.Write(cf); err != nil {
+			t.Errorf("%s!%s: Write: %v", jar, f.Name, err)
+			failures++
+		} else if !bytes.Equal(out, data) {
+			t.Errorf("%s!%s: output differs from the original class file", jar, f.Name)
+			failures++
+		}
+		if failures >= 10 {
+			t.Fatalf("%s: too many failures", jar)
+		}
+	}
+	t.Logf("%s: %d classes", jar, classes)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
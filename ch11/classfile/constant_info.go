@@ -1,5 +1,7 @@
 package classfile
 
+import "fmt"
+
 const (
 	CONSTANT_Class              = 7
 	CONSTANT_Fieldref           = 9
@@ -19,6 +21,7 @@ const (
 
 type ConstantInfo interface {
 	readInfo(reader *ClassReader)
+	writeInfo(writer *ClassWriter)
 }
 
 func readConstantInfo(reader *ClassReader, cp ConstantPool) ConstantInfo {
@@ -62,3 +65,44 @@ func newConstantInfo(tag uint8, cp ConstantPool) ConstantInfo {
 		panic("java.lang.ClassFormatError: constant pool tag!")
 	}
 }
+
+func writeConstantInfo(writer *ClassWriter, c ConstantInfo) {
+	writer.writeUint8(constantTag(c))
+	c.writeInfo(writer)
+}
+
+// constantTag 和newConstantInfo()相反，根据常量的类型得到tag
+func constantTag(c ConstantInfo) uint8 {
+	switch c.(type) {
+	case *ConstantIntegerInfo:
+		return CONSTANT_Integer
+	case *ConstantFloatInfo:
+		return CONSTANT_Float
+	case *ConstantLongInfo:
+		return CONSTANT_Long
+	case *ConstantDoubleInfo:
+		return CONSTANT_Double
+	case *ConstantUtf8Info:
+		return CONSTANT_Utf8
+	case *ConstantStringInfo:
+		return CONSTANT_String
+	case *ConstantClassInfo:
+		return CONSTANT_Class
+	case *ConstantFieldrefInfo:
+		return CONSTANT_Fieldref
+	case *ConstantMethodrefInfo:
+		return CONSTANT_Methodref
+	case *ConstantInterfaceMethodrefInfo:
+		return CONSTANT_InterfaceMethodref
+	case *ConstantNameAndTypeInfo:
+		return CONSTANT_NameAndType
+	case *ConstantMethodTypeInfo:
+		return CONSTANT_MethodType
+	case *ConstantMethodHandleInfo:
+		return CONSTANT_MethodHandle
+	case *ConstantInvokeDynamicInfo:
+		return CONSTANT_InvokeDynamic
+	default:
+		panic(fmt.Sprintf("unknown constant type: %T", c))
+	}
+}
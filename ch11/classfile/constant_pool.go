@@ -19,6 +19,38 @@ func readConstantPool(reader *ClassReader) ConstantPool {
 	return cp
 }
 
+/*
+writeConstantPool()函数写常量池，long和double后面的空位不写
+*/
+func writeConstantPool(writer *ClassWriter, cp ConstantPool) {
+	writer.writeUint16(uint16(len(cp)))
+	for i := 1; i < len(cp); i++ {
+		if cp[i] != nil {
+			writeConstantInfo(writer, cp[i])
+		}
+	}
+}
+
+/*
+下面几个方法把常量池的查找功能暴露给其他包(比如javap)用
+*/
+
+func (self ConstantPool) GetConstantInfo(index uint16) ConstantInfo {
+	return self.getConstantInfo(index)
+}
+
+func (self ConstantPool) GetNameAndType(index uint16) (string, string) {
+	return self.getNameAndType(index)
+}
+
+func (self ConstantPool) GetClassName(index uint16) string {
+	return self.getClassName(index)
+}
+
+func (self ConstantPool) GetUtf8(index uint16) string {
+	return self.getUtf8(index)
+}
+
 /*
 getConstantInfo()方法按索引查找常量
 */
@@ -17,6 +17,18 @@ func (self *ConstantMethodHandleInfo) readInfo(reader *ClassReader) {
 	self.referenceIndex = reader.readUint16()
 }
 
+func (self *ConstantMethodHandleInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint8(self.referenceKind)
+	writer.writeUint16(self.referenceIndex)
+}
+
+func (self *ConstantMethodHandleInfo) ReferenceKind() uint8 {
+	return self.referenceKind
+}
+func (self *ConstantMethodHandleInfo) ReferenceIndex() uint16 {
+	return self.referenceIndex
+}
+
 /*
 CONSTANT_MethodType_info {
     u1 tag;
@@ -31,6 +43,14 @@ func (self *ConstantMethodTypeInfo) readInfo(reader *ClassReader) {
 	self.descriptorIndex = reader.readUint16()
 }
 
+func (self *ConstantMethodTypeInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.descriptorIndex)
+}
+
+func (self *ConstantMethodTypeInfo) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
+
 /*
 CONSTANT_InvokeDynamic_info {
     u1 tag;
@@ -47,3 +67,15 @@ func (self *ConstantInvokeDynamicInfo) readInfo(reader *ClassReader) {
 	self.bootstrapMethodAttrIndex = reader.readUint16()
 	self.nameAndTypeIndex = reader.readUint16()
 }
+
+func (self *ConstantInvokeDynamicInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.bootstrapMethodAttrIndex)
+	writer.writeUint16(self.nameAndTypeIndex)
+}
+
+func (self *ConstantInvokeDynamicInfo) BootstrapMethodAttrIndex() uint16 {
+	return self.bootstrapMethodAttrIndex
+}
+func (self *ConstantInvokeDynamicInfo) NameAndTypeIndex() uint16 {
+	return self.nameAndTypeIndex
+}
@@ -11,6 +11,19 @@ func (self *ConstantMemberrefInfo) readInfo(reader *ClassReader) {
 	self.nameAndTypeIndex = reader.readUint16()
 }
 
+func (self *ConstantMemberrefInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.classIndex)
+	writer.writeUint16(self.nameAndTypeIndex)
+}
+
+func (self *ConstantMemberrefInfo) ClassIndex() uint16 {
+	return self.classIndex
+}
+
+func (self *ConstantMemberrefInfo) NameAndTypeIndex() uint16 {
+	return self.nameAndTypeIndex
+}
+
 func (self *ConstantMemberrefInfo) ClassName() string {
 	return self.cp.getClassName(self.classIndex)
 }
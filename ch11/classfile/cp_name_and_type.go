@@ -9,3 +9,16 @@ func (self *ConstantNameAndTypeInfo) readInfo(reader *ClassReader) {
 	self.nameIndex = reader.readUint16()
 	self.descriptorIndex = reader.readUint16()
 }
+
+func (self *ConstantNameAndTypeInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.nameIndex)
+	writer.writeUint16(self.descriptorIndex)
+}
+
+func (self *ConstantNameAndTypeInfo) NameIndex() uint16 {
+	return self.nameIndex
+}
+
+func (self *ConstantNameAndTypeInfo) DescriptorIndex() uint16 {
+	return self.descriptorIndex
+}
@@ -19,6 +19,9 @@ func (self *ConstantIntegerInfo) readInfo(reader *ClassReader) {
 	bytes := reader.readUint32()
 	self.val = int32(bytes)
 }
+func (self *ConstantIntegerInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint32(uint32(self.val))
+}
 func (self *ConstantIntegerInfo) Value() int32 {
 	return self.val
 }
@@ -35,6 +38,9 @@ func (self *ConstantFloatInfo) readInfo(reader *ClassReader) {
 	bytes := reader.readUint32()           //读取一个uint32数据
 	self.val = math.Float32frombits(bytes) //转换为float32类型
 }
+func (self *ConstantFloatInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint32(math.Float32bits(self.val))
+}
 func (self *ConstantFloatInfo) Value() float32 {
 	return self.val
 }
@@ -51,6 +57,9 @@ func (self *ConstantLongInfo) readInfo(reader *ClassReader) {
 	bytes := reader.readUint64() //先读取一个uint64的数据
 	self.val = int64(bytes)      //转型为int64类型
 }
+func (self *ConstantLongInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint64(uint64(self.val))
+}
 func (self *ConstantLongInfo) Value() int64 {
 	return self.val
 }
@@ -67,6 +76,9 @@ func (self *ConstantDoubleInfo) readInfo(reader *ClassReader) {
 	bytes := reader.readUint64()           //读取uint64数据
 	self.val = math.Float64frombits(bytes) //转型为float64类型
 }
+func (self *ConstantDoubleInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint64(math.Float64bits(self.val))
+}
 func (self *ConstantDoubleInfo) Value() float64 {
 	return self.val
 }
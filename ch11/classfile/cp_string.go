@@ -17,6 +17,14 @@ func (self *ConstantStringInfo) readInfo(reader *ClassReader) {
 	self.stringIndex = reader.readUint16()
 }
 
+func (self *ConstantStringInfo) writeInfo(writer *ClassWriter) {
+	writer.writeUint16(self.stringIndex)
+}
+
+func (self *ConstantStringInfo) StringIndex() uint16 {
+	return self.stringIndex
+}
+
 /*
 String()方法按索引从常量池中查找字符串
 */
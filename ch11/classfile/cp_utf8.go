@@ -10,6 +10,21 @@ func (self *ConstantUtf8Info) readInfo(reader *ClassReader) {
 	self.str = decodeMUTF8(bytes)
 }
 
+func (self *ConstantUtf8Info) writeInfo(writer *ClassWriter) {
+	bytes := encodeMUTF8(self.str)
+	writer.writeUint16(uint16(len(bytes)))
+	writer.writeBytes(bytes)
+}
+
+func (self *ConstantUtf8Info) Str() string {
+	return self.str
+}
+
 func decodeMUTF8(bytes []byte) string {
 	return string(bytes)
 }
+
+// 和decodeMUTF8()相反
+func encodeMUTF8(str string) []byte {
+	return []byte(str)
+}
@@ -38,6 +38,19 @@ func readMember(reader *ClassReader, cp ConstantPool) *MemberInfo {
 	}
 }
 
+/*
+writeMembers()写字段表或方法表，和readMembers()对应
+*/
+func writeMembers(writer *ClassWriter, members []*MemberInfo) {
+	writer.writeUint16(uint16(len(members)))
+	for _, member := range members {
+		writer.writeUint16(member.accessFlags)
+		writer.writeUint16(member.nameIndex)
+		writer.writeUint16(member.descriptionIndex)
+		writeAttributes(writer, member.attributes)
+	}
+}
+
 /*
 Name()从常量池查找字段或方法名
 */
@@ -58,6 +71,10 @@ func (self *MemberInfo) AccessFlags() uint16 {
 	return self.accessFlags
 }
 
+func (self *MemberInfo) Attributes() []AttributeInfo {
+	return self.attributes
+}
+
 // CodeAttribute 获取MemberInfo的Code属性
 func (self *MemberInfo) CodeAttribute() *CodeAttribute {
 	for _, attrInfo := range self.attributes {
@@ -5,10 +5,11 @@ import (
 	"path/filepath"
 )
 
+/*
+Classpath按顺序在entries中查找类，Parse()得到的顺序是 启动类路径→拓展类路径→用户类路径
+*/
 type Classpath struct {
-	boolClasspath Entry //主类
-	extClasspath  Entry //拓展类
-	userClasspath Entry //用户自定义的类
+	entries []Entry
 }
 
 /*
@@ -17,24 +18,30 @@ Parse()函数使用 -Xjre选项解析 启动类路径和拓展类路径，使用
 
 func Parse(jreOption, cpOption string) *Classpath {
 
-	cp := &Classpath{}
-	cp.parseBootAndExtClasspath(jreOption)
-	cp.parseUserClasspath(cpOption)
-	return cp
+	bootClasspath, extClasspath := parseBootAndExtClasspath(jreOption)
+	return New(bootClasspath, extClasspath, parseUserClasspath(cpOption))
 }
 
-func (self *Classpath) parseBootAndExtClasspath(jreOption string) {
+func parseBootAndExtClasspath(jreOption string) (bootClasspath, extClasspath Entry) {
 
 	jreDir := getJreDir(jreOption)
 
 	// jre/lib/*
 	jreLibPath := filepath.Join(jreDir, "lib", "*")
-	self.boolClasspath = newWildcardEntry(jreLibPath)
+	bootClasspath = newWildcardEntry(jreLibPath)
 
 	//jre/lib/ext
 	jreExtPath := filepath.Join(jreDir, "lib", "ext", "*")
-	self.extClasspath = newWildcardEntry(jreExtPath)
+	extClasspath = newWildcardEntry(jreExtPath)
+	return
+}
 
+/*
+New()函数用任意的Entry创建Classpath，按参数的顺序查找类，不需要JRE
+比如用MemoryEntry提供在内存中生成的类，这时java/lang/Object等类也要由Entry提供
+*/
+func New(entries ...Entry) *Classpath {
+	return &Classpath{entries: entries}
 }
 
 func getJreDir(jreOption string) string {
@@ -62,24 +69,28 @@ func exists(path string) bool {
 	return true //文件存在  return true
 }
 
-func (self *Classpath) parseUserClasspath(cpOption string) {
+func parseUserClasspath(cpOption string) Entry {
 	if cpOption == "" {
 		cpOption = "."
 	}
-	self.userClasspath = newEntry(cpOption)
+	return NewEntry(cpOption)
 }
 
+// Entries 返回查找类的顺序
+func (self *Classpath) Entries() []Entry {
+	return self.entries
+}
+
+// SetEntries 修改查找类的顺序，比如把内存中的类放在启动类路径前面
+func (self *Classpath) SetEntries(entries ...Entry) {
+	self.entries = entries
+}
+
+// ReadClass 参数是类名(例如java/lang/Object)，不带.class后缀
 func (self *Classpath) ReadClass(className string) ([]byte, Entry, error) {
-	className = className + ".class"
-	if data, entry, err := self.boolClasspath.readClass(className); err == nil {
-		return data, entry, err
-	}
-	if data, entry, err := self.extClasspath.readClass(className); err == nil {
-		return data, entry, err
-	}
-	return self.userClasspath.readClass(className)
+	return CompositeEntry(self.entries).ReadClass(className + ".class")
 }
 
 func (self *Classpath) String() string {
-	return self.userClasspath.String()
+	return CompositeEntry(self.entries).String()
 }
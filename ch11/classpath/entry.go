@@ -8,20 +8,22 @@ import (
 const pathListSeparator = string(os.PathListSeparator) //常量，存放路径分隔符
 
 /*
-	readClass()方法：负责寻找和加载class文件 参数为class文件的相对路径，路径之间用斜线分隔/，文件名有后缀.class，例如要读取java.lang.Object类，
+	ReadClass()方法：负责寻找和加载class文件 参数为class文件的相对路径，路径之间用斜线分隔/，文件名有后缀.class，例如要读取java.lang.Object类，
 					传入的参数应该是java/lang/Object.class，返回值是读取到的字节数，最终定位到class文件的Entry，以及错误信息
 
 	String()方法：相当于Java中的toString()，用于返回变量的字符串表示
+
+	Entry是导出的接口，包外的代码也可以实现它，比如从网络或者数据库中读取class文件
 */
 type Entry interface {
-	readClass(className string) ([]byte, Entry, error)
+	ReadClass(className string) ([]byte, Entry, error)
 	String() string
 }
 
 /*
-newEntry()函数根据参数创建不同类型的Entry实例
+NewEntry()函数根据参数创建不同类型的Entry实例
 */
-func newEntry(path string) Entry {
+func NewEntry(path string) Entry {
 	if strings.Contains(path, pathListSeparator) {
 		return newCompositeEntry(path) //有多个由分隔符分开的路径
 	}
@@ -0,0 +1,31 @@
+package classpath
+
+import "errors"
+
+/*
+CallbackEntry 调用Go函数读取class文件，参数和ReadClass()一样是class文件的相对路径，例如java/lang/Object.class
+函数返回nil表示找不到类，适合按需生成类或者从其他地方读取类
+*/
+type CallbackEntry struct {
+	name     string
+	callback func(className string) ([]byte, error)
+}
+
+func NewCallbackEntry(name string, callback func(className string) ([]byte, error)) *CallbackEntry {
+	return &CallbackEntry{name, callback}
+}
+
+func (self *CallbackEntry) ReadClass(className string) ([]byte, Entry, error) {
+	data, err := self.callback(className)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data == nil {
+		return nil, nil, errors.New("class not found: " + className)
+	}
+	return data, self, nil
+}
+
+func (self *CallbackEntry) String() string {
+	return self.name
+}
@@ -14,7 +14,7 @@ type CompositeEntry []Entry
 func newCompositeEntry(pathList string) CompositeEntry {
 	compositeEntry := []Entry{}
 	for _, path := range strings.Split(pathList, pathListSeparator) {
-		entry := newEntry(path)                        //一个路径生成单个Entry
+		entry := NewEntry(path)                        //一个路径生成单个Entry
 		compositeEntry = append(compositeEntry, entry) //追加到compositeEntry中
 	}
 
@@ -22,9 +22,9 @@ func newCompositeEntry(pathList string) CompositeEntry {
 
 }
 
-func (self CompositeEntry) readClass(className string) ([]byte, Entry, error) {
+func (self CompositeEntry) ReadClass(className string) ([]byte, Entry, error) {
 	for _, entry := range self {
-		data, from, err := entry.readClass(className)
+		data, from, err := entry.ReadClass(className)
 		if err == nil {
 			return data, from, nil
 		}
@@ -18,7 +18,7 @@ func newDirEntry(path string) *DirEntry { //Go没有专门的构造函数，统
 	return &DirEntry{absDir}
 }
 
-func (self *DirEntry) readClass(className string) ([]byte, Entry, error) {
+func (self *DirEntry) ReadClass(className string) ([]byte, Entry, error) {
 	fileName := filepath.Join(self.absDir, className) //把目录和class文件名拼成一个完整的路径
 	data, err := ioutil.ReadFile(fileName)            //读取class文件内容
 	return data, self, err
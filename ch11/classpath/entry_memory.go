@@ -0,0 +1,40 @@
+package classpath
+
+import "errors"
+
+/*
+MemoryEntry 从内存中读取class文件，比如在Go代码中用asm包生成的类
+key是class文件的相对路径，例如java/lang/Object.class
+*/
+type MemoryEntry struct {
+	classes map[string][]byte
+}
+
+func NewMemoryEntry() *MemoryEntry {
+	return &MemoryEntry{classes: map[string][]byte{}}
+}
+
+// NewMemoryEntryFromMap 用map创建MemoryEntry，key是类的内部名，例如java/lang/Object
+func NewMemoryEntryFromMap(classes map[string][]byte) *MemoryEntry {
+	self := NewMemoryEntry()
+	for className, data := range classes {
+		self.AddClass(className, data)
+	}
+	return self
+}
+
+// AddClass 添加类，className是类的内部名，例如java/lang/Object
+func (self *MemoryEntry) AddClass(className string, data []byte) {
+	self.classes[className+".class"] = data
+}
+
+func (self *MemoryEntry) ReadClass(className string) ([]byte, Entry, error) {
+	if data, ok := self.classes[className]; ok {
+		return data, self, nil
+	}
+	return nil, nil, errors.New("class not found: " + className)
+}
+
+func (self *MemoryEntry) String() string {
+	return "memory"
+}
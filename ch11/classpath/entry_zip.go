@@ -21,7 +21,7 @@ func newZipEntry(path string) *ZipEntry {
 }
 
 //方法，重点是如何从ZIP文件中提取class文件
-func (self *ZipEntry) readClass(className string) ([]byte, Entry, error) {
+func (self *ZipEntry) ReadClass(className string) ([]byte, Entry, error) {
 	r, err := zip.OpenReader(self.absPath) //首先打开ZIP文件
 	if err != nil {
 		return nil, nil, err
@@ -3,7 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"jvmgo/ch11/rtda/heap"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Cmd struct {
@@ -11,10 +16,28 @@ type Cmd struct {
 	versionFlag      bool
 	verboseClassFlag bool
 	verboseInstFlag  bool
+	javapFlag        bool
 	cpOption         string
 	class            string
 	args             []string
 	XjreOption       string
+	XdebugFlag       bool
+	jdwpOption       string
+	XprofFlag        bool
+	XprofExactFlag   bool
+	XprofInterval    time.Duration
+	XprofFile        string
+	Xtrace           traceFlag
+	XXhashCode       int
+	Xms              memorySize
+	Xmx              memorySize
+	Xss              memorySize
+	XXheapDumpOnOOM  bool
+	XXheapDumpPath   string
+	XXthreadDumpPath string
+	XXuseJvmgoGC     bool
+	XXstrTableStats  bool
+	verboseGCFlag    bool
 }
 
 func parseCmd() *Cmd {
@@ -26,10 +49,28 @@ func parseCmd() *Cmd {
 	flag.BoolVar(&cmd.verboseClassFlag, "verbose", false, "enable verbose output")
 	flag.BoolVar(&cmd.verboseClassFlag, "verbose:class", false, "enable verbose output")
 	flag.BoolVar(&cmd.verboseInstFlag, "verbose:inst", false, "enable verbose output")
+	flag.BoolVar(&cmd.verboseGCFlag, "verbose:gc", false, "print a line for every garbage collection")
+	flag.BoolVar(&cmd.javapFlag, "javap", false, "disassemble class file")
 	flag.StringVar(&cmd.cpOption, "classpath", "", "classpath")
 	flag.StringVar(&cmd.cpOption, "cp", "", "classpath")
+	flag.Var(&cmd.Xms, "Xms", "initial Java heap size, e.g. -Xms64m")
+	flag.Var(&cmd.Xmx, "Xmx", "maximum Java heap size, e.g. -Xmx512m")
+	flag.Var(&cmd.Xss, "Xss", "Java thread stack size, e.g. -Xss2m")
+	flag.IntVar(&cmd.XXhashCode, "XX:hashCode", heap.HASH_CODE_XOR_SHIFT, "identity hash code algorithm")
+	flag.BoolVar(&cmd.XXheapDumpOnOOM, "XX:+HeapDumpOnOutOfMemoryError", false, "dump heap when OutOfMemoryError is thrown")
+	flag.StringVar(&cmd.XXheapDumpPath, "XX:HeapDumpPath", "", "heap dump file or directory, default java_pid<pid>.hprof")
+	flag.StringVar(&cmd.XXthreadDumpPath, "XX:ThreadDumpPath", "", "append thread dumps to this file instead of stderr")
+	flag.BoolVar(&cmd.XXuseJvmgoGC, "XX:+UseJvmgoGC", false, "allocate objects in the VM's own heap and collect them with mark-sweep")
+	flag.BoolVar(&cmd.XXstrTableStats, "XX:+PrintStringTableStatistics", false, "print string table statistics at exit")
+	flag.BoolVar(&cmd.XdebugFlag, "Xdebug", false, "start interactive bytecode debugger")
+	flag.BoolVar(&cmd.XprofFlag, "Xprof", false, "profile by sampling Java stacks")
+	flag.BoolVar(&cmd.XprofExactFlag, "Xprof:exact", false, "profile by counting every instruction and invocation")
+	flag.DurationVar(&cmd.XprofInterval, "Xprof:interval", time.Millisecond, "profiler sampling interval")
+	flag.StringVar(&cmd.XprofFile, "Xprof:file", "jvmgo.collapsed", "collapsed stacks output for flame graphs")
+	flag.Var(&cmd.Xtrace, "Xtrace", "trace execution, e.g. -Xtrace:method=com/acme/*,events=call,throw")
+	flag.StringVar(&cmd.jdwpOption, "agentlib:jdwp", "", "load JDWP agent, e.g. transport=dt_socket,server=y,address=8000")
 	flag.StringVar(&cmd.XjreOption, "Xjre", "", "path to jre") //指定jre路径
-	flag.Parse()                                               //通过以上方法定义好命令行flag参数后，需要通过调用flag.Parse()来对命令行参数进行解析
+	flag.CommandLine.Parse(rewriteArgs(os.Args[1:]))           //通过以上方法定义好命令行flag参数后，需要通过调用flag.Parse()来对命令行参数进行解析
 
 	args := flag.Args()
 	if len(args) > 0 {
@@ -39,6 +80,96 @@ func parseCmd() *Cmd {
 	return cmd
 }
 
+// traceFlag 可以单独使用-Xtrace，也可以带选项-Xtrace:method=Foo.*
+type traceFlag struct {
+	enabled bool
+	options string
+}
+
+func (self *traceFlag) String() string {
+	return self.options
+}
+func (self *traceFlag) Set(s string) error {
+	self.enabled = true
+	if s != "true" {
+		self.options = s
+	}
+	return nil
+}
+func (self *traceFlag) IsBoolFlag() bool {
+	return true
+}
+
+/*
+rewriteArgs 把flag包不认识的HotSpot风格的选项改成name=value的形式，否则flag包会把整个参数当成flag名
+-Xtrace:选项 改成 -Xtrace=选项，-Xmx512m、-Xss2m 改成 -Xmx=512m、-Xss=2m
+*/
+func rewriteArgs(args []string) []string {
+	result := append([]string{}, args...)
+	for i := 0; i < len(result); i++ {
+		arg := result[i]
+		if !strings.HasPrefix(arg, "-") || arg == "--" {
+			break //类名和程序的参数
+		}
+		if strings.HasPrefix(arg, "-Xtrace:") {
+			result[i] = "-Xtrace=" + strings.TrimPrefix(arg, "-Xtrace:")
+			continue
+		}
+		if isSizeOption(arg) {
+			result[i] = arg[:4] + "=" + arg[4:]
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if f := flag.Lookup(name); f != nil && !strings.Contains(name, "=") {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++ //跳过-cp等选项的值
+			}
+		}
+	}
+	return result
+}
+
+// isSizeOption 参数是不是-Xmx512m这样名字和值连在一起的内存大小选项
+func isSizeOption(arg string) bool {
+	for _, name := range []string{"-Xms", "-Xmx", "-Xss"} {
+		if strings.HasPrefix(arg, name) && len(arg) > len(name) && arg[len(name)] != '=' {
+			return true
+		}
+	}
+	return false
+}
+
+// memorySize 内存大小，和HotSpot一样可以带k、m、g、t后缀(不区分大小写)，没有后缀时单位是字节
+type memorySize int64
+
+func (self *memorySize) String() string {
+	return strconv.FormatInt(int64(*self), 10)
+}
+func (self *memorySize) Set(arg string) error {
+	s, shift := arg, uint(0)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			shift = 10
+		case 'm', 'M':
+			shift = 20
+		case 'g', 'G':
+			shift = 30
+		case 't', 'T':
+			shift = 40
+		}
+		if shift > 0 {
+			s = s[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size < 0 || size > math.MaxInt64>>shift {
+		return fmt.Errorf("invalid memory size %q", arg)
+	}
+	*self = memorySize(size << shift)
+	return nil
+}
+
 func printUsage() {
 	fmt.Printf("Usage: %s [-options] class [args...]\n", os.Args[0])
 }
@@ -0,0 +1,516 @@
+package hprof
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+/*
+HPROF 把堆中可达的对象写成HPROF二进制格式(JAVA PROFILE 1.0.2)，可以用Eclipse MAT、VisualVM打开
+格式见JDK源码中的heapDumper.cpp，这里只写出下面这些记录：
+
+	STRING LOAD_CLASS STACK_FRAME STACK_TRACE HEAP_DUMP_SEGMENT HEAP_DUMP_END
+
+所有ID都是8字节，对象ID从1开始按遍历顺序分配，0表示null
+*/
+
+const (
+	TAG_STRING            = 0x01
+	TAG_LOAD_CLASS        = 0x02
+	TAG_STACK_FRAME       = 0x04
+	TAG_STACK_TRACE       = 0x05
+	TAG_HEAP_DUMP_SEGMENT = 0x1c
+	TAG_HEAP_DUMP_END     = 0x2c
+)
+
+// HEAP_DUMP_SEGMENT中的子记录
+const (
+	ROOT_UNKNOWN          = 0xff
+	ROOT_JAVA_FRAME       = 0x03
+	ROOT_STICKY_CLASS     = 0x05
+	ROOT_THREAD_OBJECT    = 0x08
+	GC_CLASS_DUMP         = 0x20
+	GC_INSTANCE_DUMP      = 0x21
+	GC_OBJ_ARRAY_DUMP     = 0x22
+	GC_PRIM_ARRAY_DUMP    = 0x23
+	HPROF_HEADER          = "JAVA PROFILE 1.0.2"
+	ID_SIZE               = 8
+	SEGMENT_SIZE          = 1 << 20 //每个HEAP_DUMP_SEGMENT的大约大小
+	DUMMY_STACK_TRACE     = 1       //对象没有记录分配位置，都使用这个空的栈
+	FIRST_THREAD_TRACE_ID = 2
+)
+
+// 基本类型
+const (
+	T_OBJECT  = 2
+	T_BOOLEAN = 4
+	T_CHAR    = 5
+	T_FLOAT   = 6
+	T_DOUBLE  = 7
+	T_BYTE    = 8
+	T_SHORT   = 9
+	T_INT     = 10
+	T_LONG    = 11
+)
+
+var typeSizes = map[uint8]uint32{
+	T_OBJECT: ID_SIZE, T_BOOLEAN: 1, T_CHAR: 2, T_FLOAT: 4, T_DOUBLE: 8,
+	T_BYTE: 1, T_SHORT: 2, T_INT: 4, T_LONG: 8,
+}
+
+type dumper struct {
+	w       *bufio.Writer
+	runtime *rtda.Runtime
+	nextID  uint64
+	objIDs  map[*heap.Object]uint64
+	strIDs  map[string]uint64
+	segment bytes.Buffer
+	classes []*heap.Class
+	objects []*heap.Object
+	threads map[*rtda.Thread]uint32  //线程的序号
+	depths  map[*rtda.Thread][]int32 //帧在STACK_TRACE中的位置，用于ROOT_JAVA_FRAME
+	err     error
+}
+
+// DumpHeap 把堆写到文件中，返回文件大小
+func DumpHeap(path string, runtime *rtda.Runtime) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	err = Write(f, runtime)
+	size, _ := f.Seek(0, io.SeekCurrent)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return size, err
+}
+
+// Write 写出HPROF格式的堆转储，必须在执行Java代码的goroutine中调用
+func Write(w io.Writer, runtime *rtda.Runtime) error {
+	self := &dumper{
+		w:       bufio.NewWriter(w),
+		runtime: runtime,
+		nextID:  1,
+		objIDs:  map[*heap.Object]uint64{},
+		strIDs:  map[string]uint64{},
+		threads: map[*rtda.Thread]uint32{},
+		depths:  map[*rtda.Thread][]int32{},
+	}
+	self.collect()
+
+	self.w.WriteString(HPROF_HEADER)
+	self.w.WriteByte(0)
+	self.writeU4(ID_SIZE)
+	self.writeU8(uint64(time.Now().UnixNano() / int64(time.Millisecond)))
+
+	self.writeLoadClasses()
+	self.writeStackTraces()
+	self.writeRoots()
+	for _, class := range self.classes {
+		self.writeClassDump(class)
+	}
+	for _, obj := range self.objects {
+		self.writeObjectDump(obj)
+	}
+	self.flushSegment()
+	self.writeRecord(TAG_HEAP_DUMP_END, nil)
+	if self.err != nil {
+		return self.err
+	}
+	return self.w.Flush()
+}
+
+// collect 找到所有的类和可达的对象，类按名字排序，对象按遍历顺序
+func (self *dumper) collect() {
+	for _, class := range self.runtime.Loader().LoadedClasses() {
+		if class.JClass() != nil {
+			self.classes = append(self.classes, class)
+		}
+	}
+	sort.Slice(self.classes, func(i, j int) bool {
+		return self.classes[i].Name() < self.classes[j].Name()
+	})
+	for _, class := range self.classes {
+		self.objectID(class.JClass())
+	}
+	self.runtime.WalkHeap(func(obj *heap.Object) {
+		if _, ok := obj.Extra().(*heap.Class); !ok { //类对象写成CLASS_DUMP
+			self.objectID(obj)
+			self.objects = append(self.objects, obj)
+		}
+	})
+}
+
+func (self *dumper) objectID(obj *heap.Object) uint64 {
+	if obj == nil {
+		return 0
+	}
+	id, ok := self.objIDs[obj]
+	if !ok {
+		id = self.newID()
+		self.objIDs[obj] = id
+	}
+	return id
+}
+
+func (self *dumper) classID(class *heap.Class) uint64 {
+	if class == nil {
+		return 0
+	}
+	return self.objectID(class.JClass())
+}
+
+func (self *dumper) newID() uint64 {
+	id := self.nextID
+	self.nextID++
+	return id
+}
+
+// stringID 第一次用到字符串时写出STRING记录
+func (self *dumper) stringID(s string) uint64 {
+	if id, ok := self.strIDs[s]; ok {
+		return id
+	}
+	id := self.newID()
+	self.strIDs[s] = id
+	body := make([]byte, 0, ID_SIZE+len(s))
+	body = appendU8(body, id)
+	body = append(body, s...)
+	self.writeRecord(TAG_STRING, body)
+	return id
+}
+
+func (self *dumper) writeLoadClasses() {
+	for i, class := range self.classes {
+		nameID := self.stringID(class.Name())
+		var body []byte
+		body = appendU4(body, uint32(i+1))
+		body = appendU8(body, self.classID(class))
+		body = appendU4(body, DUMMY_STACK_TRACE)
+		body = appendU8(body, nameID)
+		self.writeRecord(TAG_LOAD_CLASS, body)
+	}
+}
+
+// writeStackTraces 先写一个空的栈给对象使用，再给每个线程写出STACK_FRAME和STACK_TRACE
+func (self *dumper) writeStackTraces() {
+	var body []byte
+	body = appendU4(body, DUMMY_STACK_TRACE)
+	body = appendU4(body, 0)
+	body = appendU4(body, 0)
+	self.writeRecord(TAG_STACK_TRACE, body)
+
+	classSerials := map[*heap.Class]uint32{}
+	for i, class := range self.classes {
+		classSerials[class] = uint32(i + 1)
+	}
+	for i, thread := range self.runtime.AllThreads() {
+		serial := uint32(i + 1)
+		self.threads[thread] = serial
+		var frameIDs []uint64
+		for _, frame := range thread.GetFrames() {
+			method := frame.Method()
+			self.depths[thread] = append(self.depths[thread], int32(len(frameIDs)))
+			if method.IsShim() {
+				continue //从Go代码调用Java方法时使用的帧，其中的引用算到下一个Java帧上
+			}
+			id := self.newID()
+			pc := frame.NextPC() - 1
+			if frame == thread.TopFrame() {
+				pc = thread.PC()
+			}
+			line := method.GetLineNumber(pc)
+			if line < 0 {
+				line = 0 //未知
+			}
+			var fb []byte
+			fb = appendU8(fb, id)
+			fb = appendU8(fb, self.stringID(method.Name()))
+			fb = appendU8(fb, self.stringID(method.Descriptor()))
+			fb = appendU8(fb, self.stringID(method.Class().SourceFile()))
+			fb = appendU4(fb, classSerials[method.Class()])
+			fb = appendU4(fb, uint32(int32(line)))
+			self.writeRecord(TAG_STACK_FRAME, fb)
+			frameIDs = append(frameIDs, id)
+		}
+		for j, depth := range self.depths[thread] {
+			if depth == int32(len(frameIDs)) {
+				self.depths[thread][j] = -1 //栈底的帧之下没有Java帧
+			}
+		}
+		var tb []byte
+		tb = appendU4(tb, FIRST_THREAD_TRACE_ID+uint32(i))
+		tb = appendU4(tb, serial)
+		tb = appendU4(tb, uint32(len(frameIDs)))
+		for _, id := range frameIDs {
+			tb = appendU8(tb, id)
+		}
+		self.writeRecord(TAG_STACK_TRACE, tb)
+	}
+}
+
+func (self *dumper) writeRoots() {
+	for _, root := range self.runtime.Roots() {
+		id := self.objectID(root.Object)
+		switch root.Kind {
+		case rtda.ROOT_FRAME:
+			serial := self.threads[root.Thread]
+			self.subRecord(ROOT_JAVA_FRAME)
+			self.segmentU8(id)
+			self.segmentU4(serial)
+			self.segmentU4(uint32(self.depths[root.Thread][root.Frame]))
+		case rtda.ROOT_THREAD:
+			serial := self.threads[root.Thread]
+			self.subRecord(ROOT_THREAD_OBJECT)
+			self.segmentU8(id)
+			self.segmentU4(serial)
+			self.segmentU4(FIRST_THREAD_TRACE_ID + serial - 1)
+		case rtda.ROOT_CLASS:
+			self.subRecord(ROOT_STICKY_CLASS)
+			self.segmentU8(id)
+		default:
+			self.subRecord(ROOT_UNKNOWN)
+			self.segmentU8(id)
+		}
+	}
+}
+
+func (self *dumper) writeClassDump(class *heap.Class) {
+	self.subRecord(GC_CLASS_DUMP)
+	self.segmentU8(self.classID(class))
+	self.segmentU4(DUMMY_STACK_TRACE)
+	self.segmentU8(self.classID(class.SuperClass()))
+	for i := 0; i < 5; i++ { // class loader, signers, protection domain, reserved, reserved
+		self.segmentU8(0)
+	}
+	self.segmentU4(instanceSize(class))
+	self.segmentU2(0) // constant pool
+
+	var statics, fields []*heap.Field
+	for _, field := range class.Fields() {
+		if field.IsStatic() {
+			statics = append(statics, field)
+		} else {
+			fields = append(fields, field)
+		}
+	}
+	self.segmentU2(uint16(len(statics)))
+	for _, field := range statics {
+		self.segmentU8(self.stringID(field.Name()))
+		self.writeValue(class.StaticVars(), field)
+	}
+	self.segmentU2(uint16(len(fields)))
+	for _, field := range fields {
+		self.segmentU8(self.stringID(field.Name()))
+		self.segment.WriteByte(basicType(field.Descriptor()))
+	}
+}
+
+// instanceSize 实例变量的字节数，包括超类的实例变量
+func instanceSize(class *heap.Class) uint32 {
+	size := uint32(0)
+	for c := class; c != nil; c = c.SuperClass() {
+		for _, field := range c.Fields() {
+			if !field.IsStatic() {
+				size += typeSizes[basicType(field.Descriptor())]
+			}
+		}
+	}
+	return size
+}
+
+func (self *dumper) writeObjectDump(obj *heap.Object) {
+	class := obj.Class()
+	if !class.IsArray() {
+		self.subRecord(GC_INSTANCE_DUMP)
+		self.segmentU8(self.objectID(obj))
+		self.segmentU4(DUMMY_STACK_TRACE)
+		self.segmentU8(self.classID(class))
+		self.segmentU4(instanceSize(class))
+		for c := class; c != nil; c = c.SuperClass() { //先写自己的实例变量，再写超类的
+			for _, field := range c.Fields() {
+				if !field.IsStatic() {
+					self.writeFieldValue(obj.Fields(), field)
+				}
+			}
+		}
+		return
+	}
+
+	n := uint32(obj.ArrayLength())
+	elemType := basicType(class.Name()[1:])
+	if elemType == T_OBJECT {
+		self.subRecord(GC_OBJ_ARRAY_DUMP)
+		self.segmentU8(self.objectID(obj))
+		self.segmentU4(DUMMY_STACK_TRACE)
+		self.segmentU4(n)
+		self.segmentU8(self.classID(class))
+		for _, ref := range obj.Refs() {
+			self.segmentU8(self.objectID(ref))
+		}
+		return
+	}
+	self.subRecord(GC_PRIM_ARRAY_DUMP)
+	self.segmentU8(self.objectID(obj))
+	self.segmentU4(DUMMY_STACK_TRACE)
+	self.segmentU4(n)
+	self.segment.WriteByte(elemType)
+	switch elemType {
+	case T_BOOLEAN, T_BYTE: // boolean[]和byte[]都是[]int8
+		for _, v := range obj.Bytes() {
+			self.segment.WriteByte(uint8(v))
+		}
+	case T_SHORT:
+		for _, v := range obj.Shorts() {
+			self.segmentU2(uint16(v))
+		}
+	case T_CHAR:
+		for i := uint32(0); i < n; i++ {
+			self.segmentU2(obj.CharAt(int32(i)))
+		}
+	case T_INT:
+		for _, v := range obj.Ints() {
+			self.segmentU4(uint32(v))
+		}
+	case T_LONG:
+		for _, v := range obj.Longs() {
+			self.segmentU8(uint64(v))
+		}
+	case T_FLOAT:
+		for _, v := range obj.Floats() {
+			self.segmentU4(math.Float32bits(v))
+		}
+	case T_DOUBLE:
+		for _, v := range obj.Doubles() {
+			self.segmentU8(math.Float64bits(v))
+		}
+	}
+}
+
+// writeValue 静态变量：类型和值
+func (self *dumper) writeValue(slots heap.Slots, field *heap.Field) {
+	self.segment.WriteByte(basicType(field.Descriptor()))
+	self.writeFieldValue(slots, field)
+}
+
+// writeFieldValue 按类型写出变量的值，不带类型
+func (self *dumper) writeFieldValue(slots heap.Slots, field *heap.Field) {
+	index := field.SlotId()
+	switch basicType(field.Descriptor()) {
+	case T_OBJECT:
+		self.segmentU8(self.objIDs[slots.GetRef(index)]) //不可达的对象不会出现在引用中
+	case T_BOOLEAN, T_BYTE:
+		self.segment.WriteByte(uint8(slots.GetInt(index)))
+	case T_CHAR, T_SHORT:
+		self.segmentU2(uint16(slots.GetInt(index)))
+	case T_INT:
+		self.segmentU4(uint32(slots.GetInt(index)))
+	case T_FLOAT:
+		self.segmentU4(math.Float32bits(slots.GetFloat(index)))
+	case T_LONG:
+		self.segmentU8(uint64(slots.GetLong(index)))
+	case T_DOUBLE:
+		self.segmentU8(math.Float64bits(slots.GetDouble(index)))
+	}
+}
+
+func basicType(descriptor string) uint8 {
+	switch descriptor[0] {
+	case 'Z':
+		return T_BOOLEAN
+	case 'C':
+		return T_CHAR
+	case 'F':
+		return T_FLOAT
+	case 'D':
+		return T_DOUBLE
+	case 'B':
+		return T_BYTE
+	case 'S':
+		return T_SHORT
+	case 'I':
+		return T_INT
+	case 'J':
+		return T_LONG
+	}
+	return T_OBJECT
+}
+
+/*
+写记录的辅助方法，HEAP_DUMP_SEGMENT的子记录先写到缓冲区，缓冲区满了再写成一个记录
+*/
+
+func (self *dumper) writeRecord(tag uint8, body []byte) {
+	if self.err != nil {
+		return
+	}
+	self.w.WriteByte(tag)
+	self.writeU4(0) // 相对于文件头中时间的微秒数
+	self.writeU4(uint32(len(body)))
+	_, self.err = self.w.Write(body)
+}
+
+func (self *dumper) subRecord(tag uint8) {
+	if self.segment.Len() >= SEGMENT_SIZE {
+		self.flushSegment()
+	}
+	self.segment.WriteByte(tag)
+}
+
+func (self *dumper) flushSegment() {
+	if self.segment.Len() > 0 {
+		self.writeRecord(TAG_HEAP_DUMP_SEGMENT, self.segment.Bytes())
+		self.segment.Reset()
+	}
+}
+
+func (self *dumper) writeU4(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	self.w.Write(b[:])
+}
+
+func (self *dumper) writeU8(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	self.w.Write(b[:])
+}
+
+func (self *dumper) segmentU2(v uint16) {
+	self.segment.WriteByte(byte(v >> 8))
+	self.segment.WriteByte(byte(v))
+}
+
+func (self *dumper) segmentU4(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	self.segment.Write(b[:])
+}
+
+func (self *dumper) segmentU8(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	self.segment.Write(b[:])
+}
+
+// 记录的内容先放在切片中，binary.BigEndian.AppendUint32要Go 1.19
+func appendU4(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendU8(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
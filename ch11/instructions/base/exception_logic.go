@@ -0,0 +1,37 @@
+package base
+
+import (
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+)
+
+/*
+ThrowException 在本地方法中抛出Java异常
+先推入一个只有athrow指令的shim帧(操作数栈上放着异常对象)，再推入异常类构造函数的帧
+message为空时调用无参的构造函数，异常类由虚拟机的类加载器加载，frame可以是没有类加载器的shim帧
+构造函数执行完之后，由shim帧把异常抛出，异常处理和athrow指令完全一样
+*/
+func ThrowException(frame *rtda.Frame, className, message string) {
+	thread := frame.Thread()
+	loader := thread.Runtime().Loader()
+	exClass := loader.LoadClass(className)
+	ex := exClass.NewObject()
+
+	athrowFrame := thread.NewFrame(heap.ShimAthrowMethod())
+	athrowFrame.OperandStack().PushRef(ex)
+	thread.PushFrame(athrowFrame)
+
+	var initFrame *rtda.Frame
+	if message == "" { //和HotSpot一样，没有消息的异常getMessage()返回null
+		initFrame = thread.NewFrame(exClass.GetConstructor("()V"))
+	} else {
+		initFrame = thread.NewFrame(exClass.GetConstructor("(Ljava/lang/String;)V"))
+		initFrame.LocalVars().SetRef(1, heap.JString(loader, message))
+	}
+	initFrame.LocalVars().SetRef(0, ex)
+	thread.PushFrame(initFrame)
+
+	if !exClass.InitStarted() {
+		InitClass(thread, exClass) //<clinit>在构造函数之前执行
+	}
+}
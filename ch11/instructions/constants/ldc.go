@@ -52,7 +52,7 @@ func _ldc(frame *rtda.Frame, index uint) {
 	case float32:
 		stack.PushFloat(c.(float32))
 	case string:
-		internedStr := heap.JString(class.Loader(), c.(string))
+		internedStr := heap.InternedJString(class.Loader(), c.(string))
 		stack.PushRef(internedStr)
 	case *heap.ClassRef: //如果运行时，常量池中的常量是类引用，则解析类引用，然后把类的类对象推入操作数栈顶
 		classRef := c.(*heap.ClassRef)
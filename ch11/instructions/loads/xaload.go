@@ -4,6 +4,7 @@ import (
 	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
+	"strconv"
 )
 
 type AALOAD struct {
@@ -14,9 +15,13 @@ func (self *AALOAD) Execute(frame *rtda.Frame) {
 	stack := frame.OperandStack()
 	index := stack.PopInt()  //拿到索引
 	arrRef := stack.PopRef() //拿到数组引用
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	refs := arrRef.Refs()
-	checkIndex(len(refs), index)
+	if !checkIndex(frame, len(refs), index) {
+		return
+	}
 	stack.PushRef(refs[index]) //取出元素并放入操作数栈
 }
 
@@ -30,9 +35,13 @@ func (self *BALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	bytes := arrRef.Bytes() //得到bytes数组
-	checkIndex(len(bytes), index)
+	if !checkIndex(frame, len(bytes), index) {
+		return
+	}
 	stack.PushInt(int32(bytes[index]))
 }
 
@@ -46,10 +55,13 @@ func (self *CALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
-	chars := arrRef.Chars()
-	checkIndex(len(chars), index)
-	stack.PushInt(int32(chars[index]))
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
+	if !checkIndex(frame, int(arrRef.ArrayLength()), index) {
+		return
+	}
+	stack.PushInt(int32(arrRef.CharAt(index)))
 }
 
 // DALOAD Load double from array
@@ -62,9 +74,13 @@ func (self *DALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	doubles := arrRef.Doubles()
-	checkIndex(len(doubles), index)
+	if !checkIndex(frame, len(doubles), index) {
+		return
+	}
 	stack.PushDouble(doubles[index])
 }
 
@@ -78,9 +94,13 @@ func (self *FALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	floats := arrRef.Floats()
-	checkIndex(len(floats), index)
+	if !checkIndex(frame, len(floats), index) {
+		return
+	}
 	stack.PushFloat(floats[index])
 }
 
@@ -94,9 +114,13 @@ func (self *IALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	ints := arrRef.Ints()
-	checkIndex(len(ints), index)
+	if !checkIndex(frame, len(ints), index) {
+		return
+	}
 	stack.PushInt(ints[index])
 }
 
@@ -110,9 +134,13 @@ func (self *LALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	longs := arrRef.Longs()
-	checkIndex(len(longs), index)
+	if !checkIndex(frame, len(longs), index) {
+		return
+	}
 	stack.PushLong(longs[index])
 }
 
@@ -126,19 +154,30 @@ func (self *SALOAD) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	shorts := arrRef.Shorts()
-	checkIndex(len(shorts), index)
+	if !checkIndex(frame, len(shorts), index) {
+		return
+	}
 	stack.PushInt(int32(shorts[index]))
 }
 
-func checkNotNil(ref *heap.Object) {
-	if ref == nil {
-		panic("java.lang.NullPointerException")
+// checkNotNil 数组引用是null时抛出NullPointerException并返回false，指令不再继续执行
+func checkNotNil(frame *rtda.Frame, ref *heap.Object) bool {
+	if ref != nil {
+		return true
 	}
+	base.ThrowException(frame, "java/lang/NullPointerException", "")
+	return false
 }
-func checkIndex(arrLen int, index int32) {
-	if index < 0 || index >= int32((arrLen)) {
-		panic("ArrayIndexOutOfBoundsException")
+
+// checkIndex 下标越界时抛出ArrayIndexOutOfBoundsException并返回false，指令不再继续执行
+func checkIndex(frame *rtda.Frame, arrLen int, index int32) bool {
+	if uint32(index) < uint32(arrLen) { //index是负数时转换成uint32之后一定越界
+		return true
 	}
+	base.ThrowException(frame, "java/lang/ArrayIndexOutOfBoundsException", strconv.Itoa(int(index)))
+	return false
 }
@@ -1,6 +1,8 @@
 package references
 
 import (
+	"fmt"
+	"io"
 	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
@@ -45,19 +47,161 @@ func findAndGotoExceptionHandler(thread *rtda.Thread, ex *heap.Object) bool {
 	return false
 }
 
-// todo
+/*
+和HotSpot一样，未捕获的异常交给Thread.dispatchUncaughtException()
+也就是 Thread.getUncaughtExceptionHandler().uncaughtException(thread, ex) 处理
+没有设置任何处理器并且System.err还不可用时，按ThreadGroup的默认行为直接打印到标准错误
+*/
 func handleUncaughtException(thread *rtda.Thread, ex *heap.Object) {
 	thread.ClearStack()
+	if thread.UncaughtException() != nil {
+		return //处理器自己抛出的异常被忽略
+	}
+	thread.SetUncaughtException(ex) //线程因异常终止，进程退出码为1
+
+	loader := ex.Class().Loader()
+	jThread := thread.EnsureJThread(loader)
+	if hasUncaughtExceptionHandler(jThread) {
+		dispatchUncaughtException(thread, jThread, ex)
+	} else {
+		stderr := thread.Runtime().Stderr()
+		fmt.Fprintf(stderr, "Exception in thread \"%s\" ", rtda.ThreadName(jThread))
+		PrintStackTrace(stderr, ex)
+	}
+}
+
+// 线程自己的处理器、Thread的默认处理器，或者可以让ThreadGroup打印异常的System.err
+func hasUncaughtExceptionHandler(jThread *heap.Object) bool {
+	if jThread.GetRefVar("uncaughtExceptionHandler", "Ljava/lang/Thread$UncaughtExceptionHandler;") != nil {
+		return true
+	}
+	loader := jThread.Class().Loader()
+	threadClass := loader.LoadClass("java/lang/Thread")
+	if threadClass.InitStarted() &&
+		threadClass.GetRefVar("defaultUncaughtExceptionHandler", "Ljava/lang/Thread$UncaughtExceptionHandler;") != nil {
+		return true
+	}
+	systemClass := loader.LoadClass("java/lang/System")
+	return systemClass.InitStarted() &&
+		systemClass.GetRefVar("err", "Ljava/io/PrintStream;") != nil
+}
+
+// 栈已经清空，直接把dispatchUncaughtException()的帧推入栈底
+func dispatchUncaughtException(thread *rtda.Thread, jThread, ex *heap.Object) {
+	method := jThread.Class().GetInstanceMethod("dispatchUncaughtException", "(Ljava/lang/Throwable;)V")
+	frame := thread.NewFrame(method)
+	frame.LocalVars().SetRef(0, jThread)
+	frame.LocalVars().SetRef(1, ex)
+	thread.PushFrame(frame)
+}
+
+/*
+下面按照Throwable.printStackTrace()的格式打印异常，包括Caused by:链和Suppressed:异常
+和后面的异常相同的栈帧省略为 "... n more"
+*/
+
+// PrintStackTrace 按照Throwable.printStackTrace()的格式打印异常
+func PrintStackTrace(w io.Writer, ex *heap.Object) {
+	dejaVu := map[*heap.Object]bool{ex: true} //防止异常链成环
+	fmt.Fprintln(w, ThrowableToString(ex))
+	trace := getStackTrace(ex)
+	for _, ste := range trace {
+		fmt.Fprintln(w, "	at "+ste)
+	}
+	for _, se := range getSuppressed(ex) {
+		printEnclosedStackTrace(w, se, trace, "Suppressed: ", "\t", dejaVu)
+	}
+	if cause := getCause(ex); cause != nil {
+		printEnclosedStackTrace(w, cause, trace, "Caused by: ", "", dejaVu)
+	}
+}
+
+func printEnclosedStackTrace(w io.Writer, ex *heap.Object, enclosingTrace []string,
+	caption, prefix string, dejaVu map[*heap.Object]bool) {
+
+	if dejaVu[ex] {
+		fmt.Fprintln(w, prefix+caption+"[CIRCULAR REFERENCE:"+ThrowableToString(ex)+"]")
+		return
+	}
+	dejaVu[ex] = true
+
+	//计算和外层异常相同的栈帧数
+	trace := getStackTrace(ex)
+	m := len(trace) - 1
+	n := len(enclosingTrace) - 1
+	for m >= 0 && n >= 0 && trace[m] == enclosingTrace[n] {
+		m--
+		n--
+	}
+	framesInCommon := len(trace) - 1 - m
 
-	jMsg := ex.GetRefVar("detailMessage", "Ljava/lang/String;")
-	goMsg := heap.GoString(jMsg)
-	println(ex.Class().JavaName() + ": " + goMsg)
+	fmt.Fprintln(w, prefix+caption+ThrowableToString(ex))
+	for i := 0; i <= m; i++ {
+		fmt.Fprintln(w, prefix+"\tat "+trace[i])
+	}
+	if framesInCommon != 0 {
+		fmt.Fprintf(w, "%s\t... %d more\n", prefix, framesInCommon)
+	}
+	for _, se := range getSuppressed(ex) {
+		printEnclosedStackTrace(w, se, trace, "Suppressed: ", prefix+"\t", dejaVu)
+	}
+	if cause := getCause(ex); cause != nil {
+		printEnclosedStackTrace(w, cause, trace, "Caused by: ", prefix, dejaVu)
+	}
+}
 
+// ThrowableToString 和Throwable.toString()一样，返回异常类名和消息
+func ThrowableToString(ex *heap.Object) string {
+	s := ex.Class().JavaName()
+	if jMsg := ex.GetRefVar("detailMessage", "Ljava/lang/String;"); jMsg != nil {
+		s += ": " + heap.GoString(jMsg)
+	}
+	return s
+}
+
+// fillInStackTrace()把栈信息保存在extra中，这里通过反射拿到每一帧的字符串形式
+func getStackTrace(ex *heap.Object) []string {
 	stes := reflect.ValueOf(ex.Extra())
-	for i := 0; i < stes.Len(); i++ {
-		ste := stes.Index(i).Interface().(interface {
-			String() string
-		})
-		println("\tat " + ste.String())
+	if stes.Kind() != reflect.Slice {
+		return nil
+	}
+	trace := make([]string, stes.Len())
+	for i := range trace {
+		ste := stes.Index(i).Interface().(fmt.Stringer)
+		trace[i] = ste.String()
+	}
+	return trace
+}
+
+// Throwable.getCause() cause字段指向自己表示还没有设置
+func getCause(ex *heap.Object) *heap.Object {
+	cause := ex.GetRefVar("cause", "Ljava/lang/Throwable;")
+	if cause == ex {
+		return nil
+	}
+	return cause
+}
+
+func getSuppressed(ex *heap.Object) []*heap.Object {
+	if ex.Class().GetInstanceField("suppressedExceptions", "Ljava/util/List;") == nil {
+		return nil
+	}
+	return listElements(ex.GetRefVar("suppressedExceptions", "Ljava/util/List;"))
+}
+
+// listElements 直接读取ArrayList内部的数组，Collections.unmodifiableList()包装的列表先拆开
+func listElements(list *heap.Object) []*heap.Object {
+	for list != nil {
+		class := list.Class()
+		if class.GetInstanceField("elementData", "[Ljava/lang/Object;") != nil {
+			size := list.GetIntVar("size", "I")
+			elementData := list.GetRefVar("elementData", "[Ljava/lang/Object;")
+			return elementData.Refs()[:size]
+		}
+		if class.GetInstanceField("list", "Ljava/util/List;") == nil {
+			break
+		}
+		list = list.GetRefVar("list", "Ljava/util/List;")
 	}
+	return nil
 }
@@ -2,6 +2,7 @@ package references
 
 import (
 	"fmt"
+	"io"
 	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
@@ -24,7 +25,7 @@ func (self *INVOKE_VIRTUAL) Execute(frame *rtda.Frame) {
 	if ref == nil {
 		//hack!
 		if methodRef.Name() == "println" { //如果是print方法，还可以调用
-			_println(frame.OperandStack(), methodRef.Descriptor())
+			_println(frame.Thread().Runtime().Stdout(), frame.OperandStack(), methodRef.Descriptor())
 			return
 		}
 		panic("java.lang.NullPointerException")
@@ -49,24 +50,24 @@ func (self *INVOKE_VIRTUAL) Execute(frame *rtda.Frame) {
 }
 
 // hack!
-func _println(stack *rtda.OperandStack, descriptor string) {
+func _println(w io.Writer, stack *rtda.OperandStack, descriptor string) {
 	switch descriptor {
 	case "(Z)V":
-		fmt.Printf("%v\n", stack.PopInt() != 0)
+		fmt.Fprintf(w, "%v\n", stack.PopInt() != 0)
 	case "(C)V":
-		fmt.Printf("%c\n", stack.PopInt())
+		fmt.Fprintf(w, "%c\n", stack.PopInt())
 	case "(I)V", "(B)V", "(S)V":
-		fmt.Printf("%v\n", stack.PopInt())
+		fmt.Fprintf(w, "%v\n", stack.PopInt())
 	case "(F)V":
-		fmt.Printf("%v\n", stack.PopFloat())
+		fmt.Fprintf(w, "%v\n", stack.PopFloat())
 	case "(J)V":
-		fmt.Printf("%v\n", stack.PopLong())
+		fmt.Fprintf(w, "%v\n", stack.PopLong())
 	case "(D)V":
-		fmt.Printf("%v\n", stack.PopDouble())
+		fmt.Fprintf(w, "%v\n", stack.PopDouble())
 	case "(Ljava/lang/String;)V":
 		jStr := stack.PopRef()
 		goStr := heap.GoString(jStr)
-		fmt.Println(goStr)
+		fmt.Fprintln(w, goStr)
 	default:
 		panic("println: " + descriptor)
 	}
@@ -0,0 +1,41 @@
+package references
+
+import (
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/rtda"
+)
+
+/*
+线程不会被抢占，但是可以在synchronized中wait/sleep/yield，所以进入监视器时要真正加锁，见rtda/monitor.go
+锁被其他线程持有时，线程在这条指令之后停止执行，直到调度器把锁交给它
+*/
+
+// MONITOR_ENTER Enter monitor for object
+type MONITOR_ENTER struct {
+	base.NoOperandsInstruction
+}
+
+func (self *MONITOR_ENTER) Execute(frame *rtda.Frame) {
+	ref := frame.OperandStack().PopRef()
+	if ref == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	frame.EnterMonitor(ref)
+}
+
+// MONITOR_EXIT Exit monitor for object
+type MONITOR_EXIT struct {
+	base.NoOperandsInstruction
+}
+
+func (self *MONITOR_EXIT) Execute(frame *rtda.Frame) {
+	ref := frame.OperandStack().PopRef()
+	if ref == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	if !frame.ExitMonitor(ref) {
+		base.ThrowException(frame, "java/lang/IllegalMonitorStateException", "")
+	}
+}
@@ -29,4 +29,7 @@ func (self *NEW) Execute(frame *rtda.Frame) {
 	}
 	ref := class.NewObject()          //调用类的NewObject方法即可
 	frame.OperandStack().PushRef(ref) //把对象推入栈顶
+	if class.IsFinalizable() {
+		class.Loader().Allocator().RegisterFinalizer(ref) //不可达时由Finalizer线程调用finalize()
+	}
 }
@@ -2,9 +2,11 @@ package reserved
 
 import (
 	"jvmgo/ch11/instructions/base"
-	"jvmgo/ch11/native"
 	_ "jvmgo/ch11/native/java/lang"
+	_ "jvmgo/ch11/native/java/security"
+	_ "jvmgo/ch11/native/java/util"
 	_ "jvmgo/ch11/native/sun/misc"
+	_ "jvmgo/ch11/native/sun/reflect"
 	"jvmgo/ch11/rtda"
 )
 
@@ -17,8 +19,8 @@ func (self *INVOKE_NATIVE) Execute(frame *rtda.Frame) {
 	className := method.Class().Name()
 	methodName := method.Name()
 	methodDescriptor := method.Descriptor()
-	nativeMethod := native.FindNativeMethod(className, methodName, methodDescriptor) //在本地方法注册表中找到对应的本地方法
-	if nativeMethod == nil {                                                         //本地方法为nil，报异常
+	nativeMethod := frame.Thread().Runtime().FindNativeMethod(className, methodName, methodDescriptor) //在虚拟机的本地方法表中找到对应的本地方法
+	if nativeMethod == nil {                                                                           //本地方法为nil，报异常
 		methodInfo := className + "." + methodName + methodDescriptor
 		panic("java.lang.UnsatisfiedLinkError:" + methodInfo)
 	}
@@ -4,6 +4,7 @@ import (
 	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
+	"strconv"
 )
 
 // AASTORE Store into reference array
@@ -17,9 +18,17 @@ func (self *AASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	refs := arrRef.Refs()
-	checkIndex(len(refs), index)
+	if !checkIndex(frame, len(refs), index) {
+		return
+	}
+	if ref != nil && !ref.IsInstanceOf(arrRef.Class().ComponentClass()) { //比如把Integer存到实际是String[]的Object[]中
+		base.ThrowException(frame, "java/lang/ArrayStoreException", ref.Class().JavaName())
+		return
+	}
 	refs[index] = ref
 }
 
@@ -34,9 +43,13 @@ func (self *BASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	bytes := arrRef.Bytes()
-	checkIndex(len(bytes), index)
+	if !checkIndex(frame, len(bytes), index) {
+		return
+	}
 	bytes[index] = int8(val)
 }
 
@@ -51,10 +64,13 @@ func (self *CASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
-	chars := arrRef.Chars()
-	checkIndex(len(chars), index)
-	chars[index] = uint16(val)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
+	if !checkIndex(frame, int(arrRef.ArrayLength()), index) {
+		return
+	}
+	arrRef.SetCharAt(index, uint16(val))
 }
 
 // DASTORE Store into double array
@@ -68,9 +84,13 @@ func (self *DASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	doubles := arrRef.Doubles()
-	checkIndex(len(doubles), index)
+	if !checkIndex(frame, len(doubles), index) {
+		return
+	}
 	doubles[index] = float64(val)
 }
 
@@ -85,9 +105,13 @@ func (self *FASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	floats := arrRef.Floats()
-	checkIndex(len(floats), index)
+	if !checkIndex(frame, len(floats), index) {
+		return
+	}
 	floats[index] = float32(val)
 }
 
@@ -101,9 +125,13 @@ func (self *IASTORE) Execute(frame *rtda.Frame) {
 	val := stack.PopInt() //要设置的新值
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	ints := arrRef.Ints()
-	checkIndex(len(ints), index)
+	if !checkIndex(frame, len(ints), index) {
+		return
+	}
 	ints[index] = int32(val)
 
 }
@@ -119,9 +147,13 @@ func (self *LASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	longs := arrRef.Longs()
-	checkIndex(len(longs), index)
+	if !checkIndex(frame, len(longs), index) {
+		return
+	}
 	longs[index] = int64(val)
 }
 
@@ -136,19 +168,30 @@ func (self *SASTORE) Execute(frame *rtda.Frame) {
 	index := stack.PopInt()
 	arrRef := stack.PopRef()
 
-	checkNotNil(arrRef)
+	if !checkNotNil(frame, arrRef) {
+		return
+	}
 	shorts := arrRef.Shorts()
-	checkIndex(len(shorts), index)
+	if !checkIndex(frame, len(shorts), index) {
+		return
+	}
 	shorts[index] = int16(val)
 }
 
-func checkNotNil(ref *heap.Object) {
-	if ref == nil {
-		panic("java.lang.NullPointerException")
+// checkNotNil 数组引用是null时抛出NullPointerException并返回false，指令不再继续执行
+func checkNotNil(frame *rtda.Frame, ref *heap.Object) bool {
+	if ref != nil {
+		return true
 	}
+	base.ThrowException(frame, "java/lang/NullPointerException", "")
+	return false
 }
-func checkIndex(arrLen int, index int32) {
-	if index < 0 || index >= int32(arrLen) {
-		panic("ArrayIndexOutOfBoundsException")
+
+// checkIndex 下标越界时抛出ArrayIndexOutOfBoundsException并返回false，指令不再继续执行
+func checkIndex(frame *rtda.Frame, arrLen int, index int32) bool {
+	if uint32(index) < uint32(arrLen) { //index是负数时转换成uint32之后一定越界
+		return true
 	}
+	base.ThrowException(frame, "java/lang/ArrayIndexOutOfBoundsException", strconv.Itoa(int(index)))
+	return false
 }
@@ -0,0 +1,221 @@
+package javap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"jvmgo/ch11/classfile"
+	"strings"
+)
+
+// codeReader 按大端序读取字节码，pc就是当前读到的位置
+type codeReader struct {
+	code []byte
+	pc   int
+}
+
+func (self *codeReader) readUint8() uint8 {
+	b := self.code[self.pc]
+	self.pc++
+	return b
+}
+func (self *codeReader) readInt8() int8 {
+	return int8(self.readUint8())
+}
+func (self *codeReader) readUint16() uint16 {
+	val := binary.BigEndian.Uint16(self.code[self.pc:])
+	self.pc += 2
+	return val
+}
+func (self *codeReader) readInt16() int16 {
+	return int16(self.readUint16())
+}
+func (self *codeReader) readInt32() int32 {
+	val := binary.BigEndian.Uint32(self.code[self.pc:])
+	self.pc += 4
+	return int32(val)
+}
+
+// tableswitch和lookupswitch的操作数按4字节对齐
+func (self *codeReader) skipPadding() {
+	for self.pc%4 != 0 {
+		self.pc++
+	}
+}
+
+func (self *Printer) printCode(codeAttr *classfile.CodeAttribute, argsSize int) {
+	self.println("    Code:")
+	self.printf("      stack=%d, locals=%d, args_size=%d\n",
+		codeAttr.MaxStack(), codeAttr.MaxLocals(), argsSize)
+	self.disassemble(codeAttr.Code())
+	self.printExceptionTable(codeAttr)
+
+	for _, attrInfo := range codeAttr.Attributes() {
+		switch attr := attrInfo.(type) {
+		case *classfile.LineNumberTableAttribute:
+			self.printLineNumberTable(attr)
+		case *classfile.LocalVariableTableAttribute:
+			self.printLocalVariableTable(attr)
+		default:
+			self.printAttribute(attrInfo, "      ")
+		}
+	}
+}
+
+// disassemble 逐条打印指令，指令不完整时(比如class文件被截断)打印出错的位置后停止
+func (self *Printer) disassemble(code []byte) {
+	reader := &codeReader{code: code}
+	for reader.pc < len(code) {
+		pc := reader.pc
+		ok := func() (ok bool) {
+			defer func() {
+				if r := recover(); r != nil {
+					self.printf("%10d: <truncated instruction>\n", pc)
+				}
+			}()
+			self.printInstruction(reader)
+			return true
+		}()
+		if !ok {
+			return
+		}
+	}
+}
+
+func (self *Printer) printInstruction(reader *codeReader) {
+	pc := reader.pc
+	op := opcodes[reader.readUint8()]
+	prefix := fmt.Sprintf("%10d: %-13s", pc, op.mnemonic)
+
+	switch op.operandKind {
+	case OPERAND_NONE:
+		self.println(strings.TrimRight(prefix, " "))
+	case OPERAND_BYTE:
+		self.printf("%s %d\n", prefix, reader.readInt8())
+	case OPERAND_SHORT:
+		self.printf("%s %d\n", prefix, reader.readInt16())
+	case OPERAND_CPREF:
+		self.printCPRef(prefix, uint16(reader.readUint8()), "")
+	case OPERAND_CPREF_W:
+		self.printCPRef(prefix, reader.readUint16(), "")
+	case OPERAND_LOCAL:
+		self.printf("%s %d\n", prefix, reader.readUint8())
+	case OPERAND_IINC:
+		index := reader.readUint8()
+		self.printf("%s %d, %d\n", prefix, index, reader.readInt8())
+	case OPERAND_BRANCH:
+		self.printf("%s %d\n", prefix, pc+int(reader.readInt16()))
+	case OPERAND_BRANCH_W:
+		self.printf("%s %d\n", prefix, pc+int(reader.readInt32()))
+	case OPERAND_TABLESWITCH:
+		self.printTableSwitch(reader, pc, prefix)
+	case OPERAND_LOOKUPSWITCH:
+		self.printLookupSwitch(reader, pc, prefix)
+	case OPERAND_INVOKEINTERFACE:
+		index := reader.readUint16()
+		count := reader.readUint8()
+		reader.readUint8()
+		self.printCPRef(prefix, index, fmt.Sprintf(",  %d", count))
+	case OPERAND_INVOKEDYNAMIC:
+		index := reader.readUint16()
+		reader.readUint16()
+		self.printCPRef(prefix, index, ",  0")
+	case OPERAND_ATYPE:
+		atype := reader.readUint8()
+		name, ok := arrayTypeNames[atype]
+		if !ok {
+			name = fmt.Sprintf("%d", atype)
+		}
+		self.printf("%s  %s\n", prefix, name)
+	case OPERAND_MULTIANEWARRAY:
+		index := reader.readUint16()
+		dimensions := reader.readUint8()
+		self.printCPRef(prefix, index, fmt.Sprintf(",  %d", dimensions))
+	case OPERAND_WIDE:
+		self.printWide(reader, pc)
+	default:
+		self.printf("%10d: <illegal opcode 0x%02x>\n", pc, reader.code[pc])
+	}
+}
+
+// invokevirtual #4                  // Method java/io/PrintStream.println:(Ljava/lang/String;)V
+func (self *Printer) printCPRef(prefix string, index uint16, extra string) {
+	operand := fmt.Sprintf("#%d%s", index, extra)
+	self.printf("%s %-18s // %s\n", prefix, operand, self.constantString(index))
+}
+
+// wide修改后面一条指令的局部变量索引(以及iinc的常量)为2字节，和javap一样打印成xxx_w
+func (self *Printer) printWide(reader *codeReader, pc int) {
+	op := opcodes[reader.readUint8()]
+	prefix := fmt.Sprintf("%10d: %-13s", pc, op.mnemonic+"_w")
+	switch op.operandKind {
+	case OPERAND_LOCAL:
+		self.printf("%s %d\n", prefix, reader.readUint16())
+	case OPERAND_IINC:
+		index := reader.readUint16()
+		self.printf("%s %d, %d\n", prefix, index, reader.readInt16())
+	default:
+		self.printf("%10d: <illegal wide opcode %s>\n", pc, op.mnemonic)
+	}
+}
+
+// tableswitch的跳转表按low到high的顺序打印，lookupswitch打印match和偏移的对应关系
+func (self *Printer) printTableSwitch(reader *codeReader, pc int, prefix string) {
+	reader.skipPadding()
+	defaultOffset := reader.readInt32()
+	low := reader.readInt32()
+	high := reader.readInt32()
+	self.printf("%s { // %d to %d\n", prefix, low, high)
+	for i := int64(low); i <= int64(high); i++ {
+		self.printf("%24d: %d\n", i, pc+int(reader.readInt32()))
+	}
+	self.printf("%24s: %d\n", "default", pc+int(defaultOffset))
+	self.println("            }")
+}
+
+func (self *Printer) printLookupSwitch(reader *codeReader, pc int, prefix string) {
+	reader.skipPadding()
+	defaultOffset := reader.readInt32()
+	npairs := reader.readInt32()
+	self.printf("%s { // %d\n", prefix, npairs)
+	for i := int32(0); i < npairs; i++ {
+		match := reader.readInt32()
+		self.printf("%24d: %d\n", match, pc+int(reader.readInt32()))
+	}
+	self.printf("%24s: %d\n", "default", pc+int(defaultOffset))
+	self.println("            }")
+}
+
+func (self *Printer) printExceptionTable(codeAttr *classfile.CodeAttribute) {
+	exceptionTable := codeAttr.ExceptionTable()
+	if len(exceptionTable) == 0 {
+		return
+	}
+	self.println("      Exception table:")
+	self.println("         from    to  target type")
+	for _, entry := range exceptionTable {
+		catchType := "any" //catchType为0表示捕获所有异常(finally)
+		if entry.CatchType() != 0 {
+			catchType = "Class " + self.cf.ConstantPool().GetClassName(entry.CatchType())
+		}
+		self.printf("         %5d %5d %5d   %s\n",
+			entry.StartPc(), entry.EndPc(), entry.HandlerPc(), catchType)
+	}
+}
+
+func (self *Printer) printLineNumberTable(attr *classfile.LineNumberTableAttribute) {
+	self.println("      LineNumberTable:")
+	for _, entry := range attr.LineNumberTable() {
+		self.printf("        line %d: %d\n", entry.LineNumber(), entry.StartPc())
+	}
+}
+
+func (self *Printer) printLocalVariableTable(attr *classfile.LocalVariableTableAttribute) {
+	cp := self.cf.ConstantPool()
+	self.println("      LocalVariableTable:")
+	self.println("        Start  Length  Slot  Name   Signature")
+	for _, entry := range attr.LocalVariableTable() {
+		self.printf("        %5d  %6d  %4d  %4s   %s\n",
+			entry.StartPc(), entry.Length(), entry.Index(),
+			cp.GetUtf8(entry.NameIndex()), cp.GetUtf8(entry.DescriptorIndex()))
+	}
+}
@@ -0,0 +1,151 @@
+package javap
+
+import (
+	"fmt"
+	"jvmgo/ch11/classfile"
+	"strconv"
+	"strings"
+)
+
+// 打印常量池，每一项后面的注释给出解析后的内容
+func (self *Printer) printConstantPool() {
+	cp := self.cf.ConstantPool()
+	self.println("Constant pool:")
+	for i := 1; i < len(cp); i++ {
+		c := cp[i]
+		if c == nil {
+			continue //long和double占两个位置
+		}
+		tag, args, comment := self.describeConstant(uint16(i))
+		line := fmt.Sprintf("%5s = %-18s %s", "#"+strconv.Itoa(i), tag, args)
+		if comment != "" {
+			line = fmt.Sprintf("%-42s // %s", line, comment)
+		}
+		self.println(line)
+	}
+}
+
+// 返回常量的类型名、引用的其他常量以及解析后的内容
+func (self *Printer) describeConstant(index uint16) (tag, args, comment string) {
+	cp := self.cf.ConstantPool()
+	switch c := cp.GetConstantInfo(index).(type) {
+	case *classfile.ConstantUtf8Info:
+		return "Utf8", c.Str(), ""
+	case *classfile.ConstantIntegerInfo:
+		return "Integer", strconv.Itoa(int(c.Value())), ""
+	case *classfile.ConstantFloatInfo:
+		return "Float", formatFloat(float64(c.Value()), 32) + "f", ""
+	case *classfile.ConstantLongInfo:
+		return "Long", strconv.FormatInt(c.Value(), 10) + "l", ""
+	case *classfile.ConstantDoubleInfo:
+		return "Double", formatFloat(c.Value(), 64) + "d", ""
+	case *classfile.ConstantStringInfo:
+		return "String", ref(c.StringIndex()), c.String()
+	case *classfile.ConstantClassInfo:
+		return "Class", ref(c.NameIndex()), quoteClassName(c.Name())
+	case *classfile.ConstantFieldrefInfo:
+		return "Fieldref", ref(c.ClassIndex()) + "." + ref(c.NameAndTypeIndex()), self.memberrefString(&c.ConstantMemberrefInfo, false)
+	case *classfile.ConstantMethodrefInfo:
+		return "Methodref", ref(c.ClassIndex()) + "." + ref(c.NameAndTypeIndex()), self.memberrefString(&c.ConstantMemberrefInfo, false)
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethodref", ref(c.ClassIndex()) + "." + ref(c.NameAndTypeIndex()), self.memberrefString(&c.ConstantMemberrefInfo, false)
+	case *classfile.ConstantNameAndTypeInfo:
+		return "NameAndType", ref(c.NameIndex()) + ":" + ref(c.DescriptorIndex()), self.nameAndTypeString(index)
+	case *classfile.ConstantMethodHandleInfo:
+		return "MethodHandle", fmt.Sprintf("%d:%s", c.ReferenceKind(), ref(c.ReferenceIndex())),
+			referenceKindName(c.ReferenceKind()) + " " + self.constantString(c.ReferenceIndex())
+	case *classfile.ConstantMethodTypeInfo:
+		return "MethodType", ref(c.DescriptorIndex()), cp.GetUtf8(c.DescriptorIndex())
+	case *classfile.ConstantInvokeDynamicInfo:
+		bsm := "#" + strconv.Itoa(int(c.BootstrapMethodAttrIndex()))
+		return "InvokeDynamic", bsm + ":" + ref(c.NameAndTypeIndex()), bsm + ":" + self.nameAndTypeString(c.NameAndTypeIndex())
+	default:
+		return "Unknown", "", ""
+	}
+}
+
+// constantString 指令和属性中引用的常量，和javap一样在前面加上常量的类型
+func (self *Printer) constantString(index uint16) string {
+	cp := self.cf.ConstantPool()
+	if int(index) >= len(cp) || cp[index] == nil {
+		return "<invalid>"
+	}
+	switch c := cp[index].(type) {
+	case *classfile.ConstantUtf8Info:
+		return c.Str()
+	case *classfile.ConstantIntegerInfo:
+		return "int " + strconv.Itoa(int(c.Value()))
+	case *classfile.ConstantFloatInfo:
+		return "float " + formatFloat(float64(c.Value()), 32) + "f"
+	case *classfile.ConstantLongInfo:
+		return "long " + strconv.FormatInt(c.Value(), 10) + "l"
+	case *classfile.ConstantDoubleInfo:
+		return "double " + formatFloat(c.Value(), 64) + "d"
+	case *classfile.ConstantStringInfo:
+		return "String " + c.String()
+	case *classfile.ConstantClassInfo:
+		return "class " + quoteClassName(c.Name())
+	case *classfile.ConstantFieldrefInfo:
+		return "Field " + self.memberrefString(&c.ConstantMemberrefInfo, true)
+	case *classfile.ConstantMethodrefInfo:
+		return "Method " + self.memberrefString(&c.ConstantMemberrefInfo, true)
+	case *classfile.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethod " + self.memberrefString(&c.ConstantMemberrefInfo, true)
+	case *classfile.ConstantNameAndTypeInfo:
+		return "NameAndType " + self.nameAndTypeString(index)
+	case *classfile.ConstantMethodTypeInfo:
+		return "MethodType " + self.cf.ConstantPool().GetUtf8(c.DescriptorIndex())
+	default:
+		_, _, comment := self.describeConstant(index)
+		return comment
+	}
+}
+
+// java/lang/Object."<init>":()V
+func (self *Printer) memberrefString(c *classfile.ConstantMemberrefInfo, omitThisClass bool) string {
+	className := quoteClassName(c.ClassName())
+	if omitThisClass && className == self.cf.ClassName() {
+		className = "" //和javap一样，指令中引用当前类的成员时省略类名
+	} else {
+		className += "."
+	}
+	return className + self.nameAndTypeString(c.NameAndTypeIndex())
+}
+
+func (self *Printer) nameAndTypeString(index uint16) string {
+	name, descriptor := self.cf.ConstantPool().GetNameAndType(index)
+	if strings.HasPrefix(name, "<") {
+		name = `"` + name + `"`
+	}
+	return name + ":" + descriptor
+}
+
+func ref(index uint16) string {
+	return "#" + strconv.Itoa(int(index))
+}
+
+// 数组类名要加上引号
+func quoteClassName(name string) string {
+	if strings.HasPrefix(name, "[") {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+func formatFloat(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0" //和Java一样，整数值的浮点数也带小数点
+	}
+	return s
+}
+
+func referenceKindName(kind uint8) string {
+	names := []string{"", "REF_getField", "REF_getStatic", "REF_putField", "REF_putStatic",
+		"REF_invokeVirtual", "REF_invokeStatic", "REF_invokeSpecial", "REF_newInvokeSpecial",
+		"REF_invokeInterface"}
+	if int(kind) < len(names) && kind > 0 {
+		return names[kind]
+	}
+	return "REF_???"
+}
@@ -0,0 +1,51 @@
+package javap
+
+import "strings"
+
+var primitiveTypeNames = map[byte]string{
+	'V': "void",
+	'Z': "boolean",
+	'B': "byte",
+	'S': "short",
+	'C': "char",
+	'I': "int",
+	'J': "long",
+	'F': "float",
+	'D': "double",
+}
+
+// java/lang/String -> java.lang.String
+func javaName(className string) string {
+	return strings.Replace(className, "/", ".", -1)
+}
+
+// 字段描述符转换成Java源代码中的类型，比如[Ljava/lang/String; -> java.lang.String[]
+func javaType(descriptor string) string {
+	dimensions := strings.LastIndex(descriptor, "[") + 1
+	elementType := descriptor[dimensions:]
+	var name string
+	if elementType[0] == 'L' {
+		name = javaName(elementType[1 : len(elementType)-1])
+	} else {
+		name = primitiveTypeNames[elementType[0]]
+	}
+	return name + strings.Repeat("[]", dimensions)
+}
+
+// 把方法描述符拆分成参数类型和返回值类型的描述符
+func parseMethodDescriptor(descriptor string) ([]string, string) {
+	var paramTypes []string
+	i := 1 //跳过(
+	for descriptor[i] != ')' {
+		j := i
+		for descriptor[j] == '[' {
+			j++
+		}
+		if descriptor[j] == 'L' {
+			j = i + strings.IndexByte(descriptor[i:], ';')
+		}
+		paramTypes = append(paramTypes, descriptor[i:j+1])
+		i = j + 1
+	}
+	return paramTypes, descriptor[i+1:]
+}
@@ -0,0 +1,95 @@
+package javap
+
+import (
+	"jvmgo/ch11/rtda/heap"
+	"strings"
+)
+
+type flagName struct {
+	flag uint16
+	name string
+}
+
+// 同一个比特位对于类、字段和方法的含义不同，所以要分开定义
+var classFlags = []flagName{
+	{heap.ACC_PUBLIC, "ACC_PUBLIC"},
+	{heap.ACC_FINAL, "ACC_FINAL"},
+	{heap.ACC_SUPER, "ACC_SUPER"},
+	{heap.ACC_INTERFACE, "ACC_INTERFACE"},
+	{heap.ACC_ABSTRACT, "ACC_ABSTRACT"},
+	{heap.ACC_SYNTHETIC, "ACC_SYNTHETIC"},
+	{heap.ACC_ANNOTATION, "ACC_ANNOTATION"},
+	{heap.ACC_ENUM, "ACC_ENUM"},
+}
+
+var fieldFlags = []flagName{
+	{heap.ACC_PUBLIC, "ACC_PUBLIC"},
+	{heap.ACC_PRIVATE, "ACC_PRIVATE"},
+	{heap.ACC_PROTECTED, "ACC_PROTECTED"},
+	{heap.ACC_STATIC, "ACC_STATIC"},
+	{heap.ACC_FINAL, "ACC_FINAL"},
+	{heap.ACC_VOLATILE, "ACC_VOLATILE"},
+	{heap.ACC_TRANSIENT, "ACC_TRANSIENT"},
+	{heap.ACC_SYNTHETIC, "ACC_SYNTHETIC"},
+	{heap.ACC_ENUM, "ACC_ENUM"},
+}
+
+var methodFlags = []flagName{
+	{heap.ACC_PUBLIC, "ACC_PUBLIC"},
+	{heap.ACC_PRIVATE, "ACC_PRIVATE"},
+	{heap.ACC_PROTECTED, "ACC_PROTECTED"},
+	{heap.ACC_STATIC, "ACC_STATIC"},
+	{heap.ACC_FINAL, "ACC_FINAL"},
+	{heap.ACC_SYNCHRONIZED, "ACC_SYNCHRONIZED"},
+	{heap.ACC_BRIDGE, "ACC_BRIDGE"},
+	{heap.ACC_VARARGS, "ACC_VARARGS"},
+	{heap.ACC_NATIVE, "ACC_NATIVE"},
+	{heap.ACC_ABSTRACT, "ACC_ABSTRACT"},
+	{heap.ACC_STRICT, "ACC_STRICT"},
+	{heap.ACC_SYNTHETIC, "ACC_SYNTHETIC"},
+}
+
+// Java源代码中的修饰符，顺序和javap一致
+var fieldModifiers = []flagName{
+	{heap.ACC_PUBLIC, "public"},
+	{heap.ACC_PRIVATE, "private"},
+	{heap.ACC_PROTECTED, "protected"},
+	{heap.ACC_STATIC, "static"},
+	{heap.ACC_FINAL, "final"},
+	{heap.ACC_VOLATILE, "volatile"},
+	{heap.ACC_TRANSIENT, "transient"},
+}
+
+var methodModifiers = []flagName{
+	{heap.ACC_PUBLIC, "public"},
+	{heap.ACC_PRIVATE, "private"},
+	{heap.ACC_PROTECTED, "protected"},
+	{heap.ACC_STATIC, "static"},
+	{heap.ACC_FINAL, "final"},
+	{heap.ACC_SYNCHRONIZED, "synchronized"},
+	{heap.ACC_NATIVE, "native"},
+	{heap.ACC_ABSTRACT, "abstract"},
+	{heap.ACC_STRICT, "strictfp"},
+}
+
+// ACC_PUBLIC, ACC_SUPER
+func flagNames(flags uint16, names []flagName) string {
+	var s []string
+	for _, fn := range names {
+		if flags&fn.flag != 0 {
+			s = append(s, fn.name)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// 返回"public static "这样的修饰符前缀，没有修饰符时返回空字符串
+func modifierNames(flags uint16, names []flagName) string {
+	var s string
+	for _, fn := range names {
+		if flags&fn.flag != 0 {
+			s += fn.name + " "
+		}
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+package javap
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/classfile"
+	"jvmgo/ch11/rtda/heap"
+	"strings"
+)
+
+/*
+javap 按照 javap -v -p 的格式打印class文件的内容
+包括常量池、访问标志、字段、方法的字节码以及各种属性，不认识的属性以十六进制打印
+*/
+
+type Printer struct {
+	w  io.Writer
+	cf *classfile.ClassFile
+}
+
+// Print 打印class文件，source是class文件的来源(比如classpath中的目录或者jar文件)
+func Print(w io.Writer, cf *classfile.ClassFile, source string) {
+	printer := &Printer{w: w, cf: cf}
+	printer.printClass(source)
+}
+
+func (self *Printer) println(s string) {
+	fmt.Fprintln(self.w, s)
+}
+
+func (self *Printer) printf(format string, a ...interface{}) {
+	fmt.Fprintf(self.w, format, a...)
+}
+
+func (self *Printer) printClass(source string) {
+	cf := self.cf
+	cp := cf.ConstantPool()
+	self.printf("Classfile %s\n", source)
+	if sourceFile := cf.SourceFileAttribute(); sourceFile != nil {
+		self.printf("  Compiled from \"%s\"\n", sourceFile.FileName())
+	}
+	self.println(self.classDeclaration())
+	self.printf("  minor version: %d\n", cf.MinorVersion())
+	self.printf("  major version: %d\n", cf.MajorVersion())
+	self.printf("  flags: (0x%04x) %s\n", cf.AccessFlags(), flagNames(cf.AccessFlags(), classFlags))
+	self.printf("  %-38s // %s\n", fmt.Sprintf("this_class: #%d", cf.ThisClass()), cf.ClassName())
+	if cf.SuperClass() != 0 {
+		self.printf("  %-38s // %s\n", fmt.Sprintf("super_class: #%d", cf.SuperClass()),
+			cp.GetClassName(cf.SuperClass()))
+	} else {
+		self.println("  super_class: #0")
+	}
+	self.printf("  interfaces: %d, fields: %d, methods: %d, attributes: %d\n",
+		len(cf.Interfaces()), len(cf.Fields()), len(cf.Methods()), len(cf.Attributes()))
+
+	self.printConstantPool()
+	self.println("{")
+	for i, field := range cf.Fields() {
+		if i > 0 {
+			self.println("")
+		}
+		self.printField(field)
+	}
+	for i, method := range cf.Methods() {
+		if i > 0 || len(cf.Fields()) > 0 {
+			self.println("")
+		}
+		self.printMethod(method)
+	}
+	self.println("}")
+	for _, attr := range cf.Attributes() {
+		self.printAttribute(attr, "")
+	}
+}
+
+// public class Foo extends Bar implements Baz
+func (self *Printer) classDeclaration() string {
+	cf := self.cf
+	flags := cf.AccessFlags()
+	var modifiers []string
+	if flags&heap.ACC_PUBLIC != 0 {
+		modifiers = append(modifiers, "public")
+	}
+	if flags&heap.ACC_FINAL != 0 {
+		modifiers = append(modifiers, "final")
+	}
+	if flags&heap.ACC_ABSTRACT != 0 && flags&heap.ACC_INTERFACE == 0 {
+		modifiers = append(modifiers, "abstract")
+	}
+	kind := "class"
+	if flags&heap.ACC_INTERFACE != 0 {
+		kind = "interface"
+	}
+	decl := strings.Join(append(modifiers, kind, javaName(cf.ClassName())), " ")
+
+	interfaces := make([]string, len(cf.InterfaceNames()))
+	for i, name := range cf.InterfaceNames() {
+		interfaces[i] = javaName(name)
+	}
+	if flags&heap.ACC_INTERFACE != 0 {
+		if len(interfaces) > 0 {
+			decl += " extends " + strings.Join(interfaces, ",")
+		}
+		return decl
+	}
+	if superClassName := cf.SuperClassName(); superClassName != "" {
+		decl += " extends " + javaName(superClassName)
+	}
+	if len(interfaces) > 0 {
+		decl += " implements " + strings.Join(interfaces, ",")
+	}
+	return decl
+}
+
+func (self *Printer) printField(field *classfile.MemberInfo) {
+	modifiers := modifierNames(field.AccessFlags(), fieldModifiers)
+	self.printf("  %s%s %s;\n", modifiers, javaType(field.Descriptor()), field.Name())
+	self.printf("    descriptor: %s\n", field.Descriptor())
+	self.printf("    flags: (0x%04x) %s\n", field.AccessFlags(), flagNames(field.AccessFlags(), fieldFlags))
+	for _, attrInfo := range field.Attributes() {
+		switch attr := attrInfo.(type) {
+		case *classfile.ConstantValueAttribute:
+			self.printf("    ConstantValue: %s\n", self.constantString(attr.ConstantValueIndex()))
+		default:
+			self.printAttribute(attrInfo, "    ")
+		}
+	}
+}
+
+func (self *Printer) printMethod(method *classfile.MemberInfo) {
+	flags := method.AccessFlags()
+	name := method.Name()
+	paramTypes, returnType := parseMethodDescriptor(method.Descriptor())
+
+	params := make([]string, len(paramTypes))
+	argsSize := 0
+	for i, paramType := range paramTypes {
+		params[i] = javaType(paramType)
+		argsSize++
+		if paramType == "J" || paramType == "D" {
+			argsSize++
+		}
+	}
+	if flags&heap.ACC_STATIC == 0 {
+		argsSize++ //this
+	}
+
+	var decl string
+	switch name {
+	case "<clinit>":
+		decl = "static {}"
+	case "<init>":
+		decl = modifierNames(flags, methodModifiers) + javaName(self.cf.ClassName()) +
+			"(" + strings.Join(params, ", ") + ")"
+	default:
+		decl = modifierNames(flags, methodModifiers) + javaType(returnType) + " " + name +
+			"(" + strings.Join(params, ", ") + ")"
+	}
+	for _, attrInfo := range method.Attributes() {
+		if attr, ok := attrInfo.(*classfile.ExceptionsAttribute); ok {
+			decl += " throws " + strings.Join(self.exceptionNames(attr), ", ")
+		}
+	}
+	self.printf("  %s;\n", decl)
+	self.printf("    descriptor: %s\n", method.Descriptor())
+	self.printf("    flags: (0x%04x) %s\n", flags, flagNames(flags, methodFlags))
+
+	for _, attrInfo := range method.Attributes() {
+		switch attr := attrInfo.(type) {
+		case *classfile.CodeAttribute:
+			self.printCode(attr, argsSize)
+		case *classfile.ExceptionsAttribute:
+			self.println("    Exceptions:")
+			self.printf("      throws %s\n", strings.Join(self.exceptionNames(attr), ", "))
+		default:
+			self.printAttribute(attrInfo, "    ")
+		}
+	}
+}
+
+func (self *Printer) exceptionNames(attr *classfile.ExceptionsAttribute) []string {
+	names := make([]string, len(attr.ExceptionIndexTable()))
+	for i, index := range attr.ExceptionIndexTable() {
+		names[i] = javaName(self.cf.ConstantPool().GetClassName(index))
+	}
+	return names
+}
+
+// printAttribute 打印没有特别处理的属性，不认识的属性打印长度和十六进制内容
+func (self *Printer) printAttribute(attrInfo classfile.AttributeInfo, indent string) {
+	switch attr := attrInfo.(type) {
+	case *classfile.SourceFileAttribute:
+		self.printf("%sSourceFile: \"%s\"\n", indent, attr.FileName())
+	case *classfile.DeprecatedAttribute:
+		self.printf("%sDeprecated: true\n", indent)
+	case *classfile.SyntheticAttribute:
+		self.printf("%sSynthetic: true\n", indent)
+	case *classfile.UnparsedAttribute:
+		info := attr.Info()
+		self.printf("%s%s: length = 0x%x\n", indent, attr.Name(), len(info))
+		for i := 0; i < len(info); i += 16 {
+			end := i + 16
+			if end > len(info) {
+				end = len(info)
+			}
+			self.printf("%s   %s\n", indent, hexBytes(info[i:end]))
+		}
+	default:
+		self.printf("%s%T\n", indent, attrInfo)
+	}
+}
+
+func hexBytes(bytes []byte) string {
+	hex := make([]string, len(bytes))
+	for i, b := range bytes {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, " ")
+}
@@ -0,0 +1,206 @@
+package javap
+
+// 操作数的种类，决定了反汇编时读取几个字节以及如何打印
+const (
+	OPERAND_NONE     = iota
+	OPERAND_BYTE     // bipush
+	OPERAND_SHORT    // sipush
+	OPERAND_CPREF    // u1常量池索引，ldc
+	OPERAND_CPREF_W  // u2常量池索引
+	OPERAND_LOCAL    // u1局部变量表索引
+	OPERAND_IINC     // u1索引和s1常量
+	OPERAND_BRANCH   // s2跳转偏移
+	OPERAND_BRANCH_W // s4跳转偏移
+	OPERAND_TABLESWITCH
+	OPERAND_LOOKUPSWITCH
+	OPERAND_INVOKEINTERFACE // u2常量池索引，u1参数个数，u1 0
+	OPERAND_INVOKEDYNAMIC   // u2常量池索引，u1 0，u1 0
+	OPERAND_ATYPE           // newarray的数组类型
+	OPERAND_MULTIANEWARRAY  // u2常量池索引，u1维度
+	OPERAND_WIDE
+	OPERAND_UNKNOWN // 保留指令以及没有定义的操作码
+)
+
+type opcode struct {
+	mnemonic    string
+	operandKind int
+}
+
+var opcodes [256]opcode
+
+// Mnemonic 返回操作码的助记符，比如0x60返回iadd
+func Mnemonic(op byte) string {
+	return opcodes[op].mnemonic
+}
+
+func init() {
+	for i := range opcodes {
+		opcodes[i] = opcode{"???", OPERAND_UNKNOWN}
+	}
+	def := func(op byte, mnemonic string, operandKind int) {
+		opcodes[op] = opcode{mnemonic, operandKind}
+	}
+
+	// constants
+	def(0x00, "nop", OPERAND_NONE)
+	def(0x01, "aconst_null", OPERAND_NONE)
+	def(0x02, "iconst_m1", OPERAND_NONE)
+	def(0x03, "iconst_0", OPERAND_NONE)
+	def(0x04, "iconst_1", OPERAND_NONE)
+	def(0x05, "iconst_2", OPERAND_NONE)
+	def(0x06, "iconst_3", OPERAND_NONE)
+	def(0x07, "iconst_4", OPERAND_NONE)
+	def(0x08, "iconst_5", OPERAND_NONE)
+	def(0x09, "lconst_0", OPERAND_NONE)
+	def(0x0a, "lconst_1", OPERAND_NONE)
+	def(0x0b, "fconst_0", OPERAND_NONE)
+	def(0x0c, "fconst_1", OPERAND_NONE)
+	def(0x0d, "fconst_2", OPERAND_NONE)
+	def(0x0e, "dconst_0", OPERAND_NONE)
+	def(0x0f, "dconst_1", OPERAND_NONE)
+	def(0x10, "bipush", OPERAND_BYTE)
+	def(0x11, "sipush", OPERAND_SHORT)
+	def(0x12, "ldc", OPERAND_CPREF)
+	def(0x13, "ldc_w", OPERAND_CPREF_W)
+	def(0x14, "ldc2_w", OPERAND_CPREF_W)
+
+	// loads and stores
+	typedLocals := []struct {
+		prefix      string
+		load, store byte
+	}{
+		{"i", 0x15, 0x36},
+		{"l", 0x16, 0x37},
+		{"f", 0x17, 0x38},
+		{"d", 0x18, 0x39},
+		{"a", 0x19, 0x3a},
+	}
+	for i, t := range typedLocals {
+		def(t.load, t.prefix+"load", OPERAND_LOCAL)
+		def(t.store, t.prefix+"store", OPERAND_LOCAL)
+		for n := 0; n < 4; n++ {
+			suffix := "_" + string(rune('0'+n))
+			def(byte(0x1a+i*4+n), t.prefix+"load"+suffix, OPERAND_NONE)
+			def(byte(0x3b+i*4+n), t.prefix+"store"+suffix, OPERAND_NONE)
+		}
+	}
+	arrayTypes := []string{"i", "l", "f", "d", "a", "b", "c", "s"}
+	for i, prefix := range arrayTypes {
+		def(byte(0x2e+i), prefix+"aload", OPERAND_NONE)
+		def(byte(0x4f+i), prefix+"astore", OPERAND_NONE)
+	}
+
+	// stack
+	def(0x57, "pop", OPERAND_NONE)
+	def(0x58, "pop2", OPERAND_NONE)
+	def(0x59, "dup", OPERAND_NONE)
+	def(0x5a, "dup_x1", OPERAND_NONE)
+	def(0x5b, "dup_x2", OPERAND_NONE)
+	def(0x5c, "dup2", OPERAND_NONE)
+	def(0x5d, "dup2_x1", OPERAND_NONE)
+	def(0x5e, "dup2_x2", OPERAND_NONE)
+	def(0x5f, "swap", OPERAND_NONE)
+
+	// math
+	mathOps := []string{"add", "sub", "mul", "div", "rem", "neg"}
+	for i, op := range mathOps {
+		for j, prefix := range []string{"i", "l", "f", "d"} {
+			def(byte(0x60+i*4+j), prefix+op, OPERAND_NONE)
+		}
+	}
+	def(0x78, "ishl", OPERAND_NONE)
+	def(0x79, "lshl", OPERAND_NONE)
+	def(0x7a, "ishr", OPERAND_NONE)
+	def(0x7b, "lshr", OPERAND_NONE)
+	def(0x7c, "iushr", OPERAND_NONE)
+	def(0x7d, "lushr", OPERAND_NONE)
+	def(0x7e, "iand", OPERAND_NONE)
+	def(0x7f, "land", OPERAND_NONE)
+	def(0x80, "ior", OPERAND_NONE)
+	def(0x81, "lor", OPERAND_NONE)
+	def(0x82, "ixor", OPERAND_NONE)
+	def(0x83, "lxor", OPERAND_NONE)
+	def(0x84, "iinc", OPERAND_IINC)
+
+	// conversions
+	conversions := []string{
+		"i2l", "i2f", "i2d", "l2i", "l2f", "l2d", "f2i", "f2l",
+		"f2d", "d2i", "d2l", "d2f", "i2b", "i2c", "i2s",
+	}
+	for i, mnemonic := range conversions {
+		def(byte(0x85+i), mnemonic, OPERAND_NONE)
+	}
+
+	// comparisons
+	def(0x94, "lcmp", OPERAND_NONE)
+	def(0x95, "fcmpl", OPERAND_NONE)
+	def(0x96, "fcmpg", OPERAND_NONE)
+	def(0x97, "dcmpl", OPERAND_NONE)
+	def(0x98, "dcmpg", OPERAND_NONE)
+	branches := []string{
+		"ifeq", "ifne", "iflt", "ifge", "ifgt", "ifle",
+		"if_icmpeq", "if_icmpne", "if_icmplt", "if_icmpge", "if_icmpgt", "if_icmple",
+		"if_acmpeq", "if_acmpne",
+	}
+	for i, mnemonic := range branches {
+		def(byte(0x99+i), mnemonic, OPERAND_BRANCH)
+	}
+
+	// control
+	def(0xa7, "goto", OPERAND_BRANCH)
+	def(0xa8, "jsr", OPERAND_BRANCH)
+	def(0xa9, "ret", OPERAND_LOCAL)
+	def(0xaa, "tableswitch", OPERAND_TABLESWITCH)
+	def(0xab, "lookupswitch", OPERAND_LOOKUPSWITCH)
+	def(0xac, "ireturn", OPERAND_NONE)
+	def(0xad, "lreturn", OPERAND_NONE)
+	def(0xae, "freturn", OPERAND_NONE)
+	def(0xaf, "dreturn", OPERAND_NONE)
+	def(0xb0, "areturn", OPERAND_NONE)
+	def(0xb1, "return", OPERAND_NONE)
+
+	// references
+	def(0xb2, "getstatic", OPERAND_CPREF_W)
+	def(0xb3, "putstatic", OPERAND_CPREF_W)
+	def(0xb4, "getfield", OPERAND_CPREF_W)
+	def(0xb5, "putfield", OPERAND_CPREF_W)
+	def(0xb6, "invokevirtual", OPERAND_CPREF_W)
+	def(0xb7, "invokespecial", OPERAND_CPREF_W)
+	def(0xb8, "invokestatic", OPERAND_CPREF_W)
+	def(0xb9, "invokeinterface", OPERAND_INVOKEINTERFACE)
+	def(0xba, "invokedynamic", OPERAND_INVOKEDYNAMIC)
+	def(0xbb, "new", OPERAND_CPREF_W)
+	def(0xbc, "newarray", OPERAND_ATYPE)
+	def(0xbd, "anewarray", OPERAND_CPREF_W)
+	def(0xbe, "arraylength", OPERAND_NONE)
+	def(0xbf, "athrow", OPERAND_NONE)
+	def(0xc0, "checkcast", OPERAND_CPREF_W)
+	def(0xc1, "instanceof", OPERAND_CPREF_W)
+	def(0xc2, "monitorenter", OPERAND_NONE)
+	def(0xc3, "monitorexit", OPERAND_NONE)
+
+	// extended
+	def(0xc4, "wide", OPERAND_WIDE)
+	def(0xc5, "multianewarray", OPERAND_MULTIANEWARRAY)
+	def(0xc6, "ifnull", OPERAND_BRANCH)
+	def(0xc7, "ifnonnull", OPERAND_BRANCH)
+	def(0xc8, "goto_w", OPERAND_BRANCH_W)
+	def(0xc9, "jsr_w", OPERAND_BRANCH_W)
+
+	// reserved
+	def(0xca, "breakpoint", OPERAND_NONE)
+	def(0xfe, "impdep1", OPERAND_NONE)
+	def(0xff, "impdep2", OPERAND_NONE)
+}
+
+// newarray指令的atype操作数
+var arrayTypeNames = map[uint8]string{
+	4:  "boolean",
+	5:  "char",
+	6:  "float",
+	7:  "double",
+	8:  "byte",
+	9:  "short",
+	10: "int",
+	11: "long",
+}
@@ -0,0 +1,413 @@
+package jdwp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"jvmgo/ch11/vm"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+JDWP代理，让jdb或者IDE通过Java Debug Wire Protocol调试jvmgo执行的程序
+
+	jvmgo -agentlib:jdwp=transport=dt_socket,server=y,suspend=y,address=8000 Foo
+	jdb -attach 8000
+
+所有的命令都在解释器所在的goroutine中处理，不需要加锁：
+程序运行时每执行一条指令检查一次有没有新命令；程序被挂起时(断点、单步或者调试器要求挂起)一直处理命令，直到调试器让它恢复执行
+所有Java线程都在同一个goroutine中轮流执行，所以挂起一个线程就是挂起整个虚拟机
+*/
+
+type Options struct {
+	Transport string // 只支持dt_socket
+	Address   string // [host:]port
+	Server    bool   // 为true时监听Address等待调试器连接，否则连接Address上的调试器
+	Suspend   bool   // 为true时在执行第一条指令之前挂起，等待调试器让程序继续执行
+}
+
+// ParseOptions 解析-agentlib:jdwp=后面的选项，比如transport=dt_socket,server=y,address=8000
+func ParseOptions(s string) (*Options, error) {
+	options := &Options{Transport: "dt_socket", Suspend: true}
+	for _, opt := range strings.Split(s, ",") {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("jdwp: bad option: %s", opt)
+		}
+		switch kv[0] {
+		case "transport":
+			options.Transport = kv[1]
+		case "address":
+			options.Address = kv[1]
+		case "server":
+			options.Server = kv[1] == "y"
+		case "suspend":
+			options.Suspend = kv[1] == "y"
+		default:
+			return nil, fmt.Errorf("jdwp: unsupported option: %s", kv[0])
+		}
+	}
+	if options.Transport != "dt_socket" {
+		return nil, fmt.Errorf("jdwp: unsupported transport: %s", options.Transport)
+	}
+	if options.Address == "" {
+		return nil, errors.New("jdwp: address is required")
+	}
+	if !strings.Contains(options.Address, ":") {
+		options.Address = "localhost:" + options.Address
+	} else if strings.HasPrefix(options.Address, "*:") {
+		options.Address = options.Address[1:] //监听所有地址
+	}
+	return options, nil
+}
+
+type Agent struct {
+	vm            *vm.VM
+	loader        *heap.ClassLoader
+	runtime       *rtda.Runtime
+	options       *Options
+	listener      net.Listener
+	accepted      chan net.Conn //suspend=n时在后台等待调试器连接
+	conn          net.Conn      //为nil表示调试器还没有连接或者已经断开
+	commands      chan *packet  //读goroutine收到的命令，连接断开时关闭
+	ids           *ids
+	requests      []*eventRequest
+	nextRequestID int32
+	nextPacketID  uint32
+	started       bool         //已经发送了VMStart事件
+	suspendCount  int          //大于0时虚拟机被挂起
+	thread        *rtda.Thread //正在执行指令的线程
+	current       *rtda.Frame  //虚拟机被挂起时正在执行的帧，它的位置是thread.PC()而不是NextPC()
+	unhookLoad    func()       //删除类加载回调
+}
+
+/*
+Start 启动JDWP代理
+server=y时监听地址，suspend=y时等到调试器连接之后才返回，否则在后台等待连接
+server=n时连接到正在监听的调试器
+*/
+func Start(jvm *vm.VM, options *Options) (*Agent, error) {
+	self := &Agent{
+		vm:      jvm,
+		loader:  jvm.Loader(),
+		runtime: jvm.Runtime(),
+		options: options,
+		ids:     newIDs(),
+	}
+	self.runtime.AddRootSource(self.ids.objects)
+	if options.Server {
+		listener, err := net.Listen("tcp", options.Address)
+		if err != nil {
+			return nil, err
+		}
+		self.listener = listener
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		fmt.Fprintf(self.runtime.Stdout(), "Listening for transport dt_socket at address: %s\n", port)
+		if options.Suspend {
+			if err := self.accept(); err != nil {
+				return nil, err
+			}
+		} else {
+			self.accepted = make(chan net.Conn, 1)
+			go self.acceptInBackground()
+		}
+	} else {
+		conn, err := net.DialTimeout("tcp", options.Address, 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if err := handshake(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		self.attach(conn)
+	}
+	jvm.SetDebugHook(self)
+	self.unhookLoad = self.loader.AddLoadHook(self.classLoaded)
+	return self, nil
+}
+
+func (self *Agent) accept() error {
+	for {
+		conn, err := self.listener.Accept()
+		if err != nil {
+			return err
+		}
+		if handshake(conn) == nil {
+			self.attach(conn)
+			return nil
+		}
+		conn.Close()
+	}
+}
+
+func (self *Agent) acceptInBackground() {
+	for {
+		conn, err := self.listener.Accept()
+		if err != nil {
+			return
+		}
+		if handshake(conn) == nil {
+			self.accepted <- conn
+			return
+		}
+		conn.Close()
+	}
+}
+
+// handshake 调试器先发送"JDWP-Handshake"，虚拟机原样返回
+func handshake(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	buf := make([]byte, len(HANDSHAKE))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if string(buf) != HANDSHAKE {
+		return errors.New("jdwp: bad handshake")
+	}
+	_, err := conn.Write(buf)
+	return err
+}
+
+func (self *Agent) attach(conn net.Conn) {
+	self.conn = conn
+	self.commands = make(chan *packet, 16)
+	go readCommands(conn, self.commands)
+}
+
+// readCommands 在单独的goroutine中读取命令，交给解释器所在的goroutine处理
+func readCommands(conn net.Conn, commands chan<- *packet) {
+	defer close(commands)
+	for {
+		p, err := readPacket(conn)
+		if err != nil {
+			return
+		}
+		if p.flags&FLAG_REPLY == 0 { //虚拟机不发送命令，不会收到回复
+			commands <- p
+		}
+	}
+}
+
+// detach 调试器断开连接或者发送了Dispose命令，删除所有事件请求并恢复执行
+func (self *Agent) detach() {
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+	self.requests = nil
+	self.suspendCount = 0
+}
+
+// Close 程序结束时发送VMDeath事件并断开连接
+func (self *Agent) Close() {
+	if self.conn != nil && self.started {
+		w := &packetWriter{}
+		w.writeByte(SUSPEND_NONE)
+		w.writeInt(1)
+		w.writeByte(EVENT_VM_DEATH)
+		w.writeInt(0)
+		self.sendEvent(w)
+	}
+	self.detach()
+	if self.listener != nil {
+		self.listener.Close()
+	}
+	self.vm.SetDebugHook(nil)
+	self.unhookLoad()
+}
+
+// BeforeInstruction 实现vm.DebugHook
+func (self *Agent) BeforeInstruction(frame *rtda.Frame, opcode uint8) {
+	if self.conn == nil {
+		if !self.checkAccepted() {
+			return
+		}
+	}
+	thread := frame.Thread()
+	self.thread = thread
+	if !self.started {
+		self.sendVMStart(thread)
+	}
+	self.pollCommands()
+	if !frame.Method().IsShim() {
+		self.checkLocationEvents(frame)
+	}
+	self.waitWhileSuspended(frame)
+}
+
+func (self *Agent) checkAccepted() bool {
+	if self.accepted == nil {
+		return false
+	}
+	select {
+	case conn := <-self.accepted:
+		self.accepted = nil
+		self.attach(conn)
+		return true
+	default:
+		return false
+	}
+}
+
+// sendVMStart 在执行第一条指令之前发送，suspend=y时挂起虚拟机
+func (self *Agent) sendVMStart(thread *rtda.Thread) {
+	self.started = true
+	policy := uint8(SUSPEND_NONE)
+	if self.options.Suspend {
+		policy = SUSPEND_ALL
+		self.suspendCount++
+	}
+	w := &packetWriter{}
+	w.writeByte(policy)
+	w.writeInt(1)
+	w.writeByte(EVENT_VM_START)
+	w.writeInt(0)
+	w.writeID(self.ids.id(thread))
+	self.sendEvent(w)
+}
+
+// pollCommands 处理已经收到的命令，不等待
+func (self *Agent) pollCommands() {
+	for self.conn != nil {
+		select {
+		case p, ok := <-self.commands:
+			if !ok {
+				self.detach()
+				return
+			}
+			self.dispatch(p)
+		default:
+			return
+		}
+	}
+}
+
+// waitWhileSuspended 虚拟机被挂起时一直处理命令，直到调试器让它恢复执行
+func (self *Agent) waitWhileSuspended(frame *rtda.Frame) {
+	self.current = frame
+	for self.suspendCount > 0 && self.conn != nil {
+		p, ok := <-self.commands
+		if !ok {
+			self.detach()
+			break
+		}
+		self.dispatch(p)
+	}
+	self.current = nil
+}
+
+// dispatch 执行命令并发送回复，命令处理函数通过panic(jdwpError)返回错误码
+func (self *Agent) dispatch(p *packet) {
+	reply := &packet{id: p.id, flags: FLAG_REPLY}
+	w := &packetWriter{}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if code, ok := r.(jdwpError); ok {
+					reply.errorCode = uint16(code)
+				} else {
+					reply.errorCode = ERROR_INTERNAL //比如读取对象的字段时出错
+				}
+			}
+		}()
+		handler := commandHandlers[[2]uint8{p.commandSet, p.command}]
+		if handler == nil {
+			panic(jdwpError(ERROR_NOT_IMPLEMENTED))
+		}
+		handler(self, &packetReader{p.data}, w)
+	}()
+	if reply.errorCode == ERROR_NONE {
+		reply.data = w.data
+	}
+	self.send(reply)
+}
+
+// sendEvent 发送Event.Composite命令，w中是挂起策略和所有事件
+func (self *Agent) sendEvent(w *packetWriter) {
+	self.nextPacketID++
+	self.send(&packet{
+		id:         self.nextPacketID,
+		commandSet: CS_EVENT,
+		command:    CMD_EVENT_COMPOSITE,
+		data:       w.data,
+	})
+}
+
+func (self *Agent) send(p *packet) {
+	if self.conn == nil {
+		return
+	}
+	if _, err := self.conn.Write(p.bytes()); err != nil {
+		self.detach()
+	}
+}
+
+// frameLocation 返回帧正在执行的指令的位置
+func (self *Agent) frameLocation(frame *rtda.Frame) int64 {
+	if frame.Method().IsNative() {
+		return NATIVE_LOCATION
+	}
+	if frame == self.current {
+		return int64(frame.Thread().PC())
+	}
+	if frame == frame.Thread().TopFrame() {
+		return int64(frame.NextPC()) //线程让出执行权时，下一条要执行的指令
+	}
+	return int64(frame.NextPC() - 1) //调用指令中的某个位置，和调用指令在同一行
+}
+
+func (self *Agent) writeLocation(w *packetWriter, method *heap.Method, index int64) {
+	class := method.Class()
+	w.writeByte(typeTag(class))
+	w.writeID(self.ids.id(class))
+	w.writeID(self.ids.id(method))
+	w.writeLong(index)
+}
+
+func typeTag(class *heap.Class) uint8 {
+	switch {
+	case class.IsArray():
+		return TYPE_TAG_ARRAY
+	case class.IsInterface():
+		return TYPE_TAG_INTERFACE
+	}
+	return TYPE_TAG_CLASS
+}
+
+// signature 类的描述符，比如Ljava/lang/String;、[I和I
+func signature(class *heap.Class) string {
+	name := class.Name()
+	if class.IsArray() {
+		return name
+	}
+	if d, ok := primitiveDescriptors[name]; ok {
+		return d
+	}
+	return "L" + name + ";"
+}
+
+var primitiveDescriptors = map[string]string{
+	"void":    "V",
+	"boolean": "Z",
+	"byte":    "B",
+	"short":   "S",
+	"int":     "I",
+	"long":    "J",
+	"char":    "C",
+	"float":   "F",
+	"double":  "D",
+}
+
+func classStatus(class *heap.Class) int32 {
+	status := int32(CLASS_STATUS_VERIFIED | CLASS_STATUS_PREPARED)
+	if class.InitStarted() {
+		status |= CLASS_STATUS_INITIALIZED
+	}
+	return status
+}
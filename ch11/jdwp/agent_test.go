@@ -0,0 +1,423 @@
+package jdwp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/vm"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, p := range []*packet{
+		{id: 1, commandSet: CS_VIRTUAL_MACHINE, command: 1},
+		{id: 0x01020304, commandSet: CS_EVENT_REQUEST, command: 1, data: []byte{1, 2, 3}},
+		{id: 7, flags: FLAG_REPLY, errorCode: ERROR_INVALID_LENGTH, data: []byte{}},
+		{id: 8, flags: FLAG_REPLY, data: []byte("reply")},
+	} {
+		data := p.bytes()
+		if n := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3]); n != len(data) {
+			t.Errorf("length field = %d, packet has %d bytes", n, len(data))
+		}
+		got, err := readPacket(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("readPacket: %v", err)
+		}
+		if got.id != p.id || got.flags != p.flags || got.commandSet != p.commandSet ||
+			got.command != p.command || got.errorCode != p.errorCode || !bytes.Equal(got.data, p.data) {
+			t.Errorf("readPacket(bytes(%+v)) = %+v", p, got)
+		}
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	short := []byte{0, 0, 0, 5, 0, 0, 0, 1, 0, 1, 1} // 长度小于头部
+	if _, err := readPacket(bytes.NewReader(short)); err == nil {
+		t.Error("readPacket accepted a length shorter than the header")
+	}
+	truncated := (&packet{id: 1, data: []byte{1, 2, 3}}).bytes()
+	if _, err := readPacket(bytes.NewReader(truncated[:len(truncated)-1])); err == nil {
+		t.Error("readPacket accepted a truncated packet")
+	}
+}
+
+func TestPacketReaderWriter(t *testing.T) {
+	w := &packetWriter{}
+	w.writeByte(0xfe)
+	w.writeBoolean(true)
+	w.writeShort(-2)
+	w.writeInt(-3)
+	w.writeLong(1 << 40)
+	w.writeID(0xdeadbeef)
+	w.writeString("hello, 世界")
+	w.writeFloat(1.5)
+	w.writeDouble(-2.25)
+
+	r := &packetReader{w.data}
+	if v := r.readByte(); v != 0xfe {
+		t.Errorf("readByte = %#x", v)
+	}
+	if !r.readBoolean() {
+		t.Error("readBoolean = false")
+	}
+	if v := r.readShort(); v != -2 {
+		t.Errorf("readShort = %d", v)
+	}
+	if v := r.readInt(); v != -3 {
+		t.Errorf("readInt = %d", v)
+	}
+	if v := r.readLong(); v != 1<<40 {
+		t.Errorf("readLong = %d", v)
+	}
+	if v := r.readID(); v != 0xdeadbeef {
+		t.Errorf("readID = %#x", v)
+	}
+	if v := r.readString(); v != "hello, 世界" {
+		t.Errorf("readString = %q", v)
+	}
+	if v := math.Float32frombits(uint32(r.readInt())); v != 1.5 {
+		t.Errorf("float = %v", v)
+	}
+	if v := math.Float64frombits(uint64(r.readLong())); v != -2.25 {
+		t.Errorf("double = %v", v)
+	}
+
+	defer func() {
+		if r := recover(); r != jdwpError(ERROR_INVALID_LENGTH) {
+			t.Errorf("reading past the end: recover() = %v", r)
+		}
+	}()
+	r.readByte()
+}
+
+func TestParseOptions(t *testing.T) {
+	options, err := ParseOptions("transport=dt_socket,server=y,suspend=n,address=8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{Transport: "dt_socket", Address: "localhost:8000", Server: true}
+	if *options != want {
+		t.Errorf("options = %+v, want %+v", *options, want)
+	}
+	if options, _ := ParseOptions("address=*:9000"); options.Address != ":9000" || !options.Suspend {
+		t.Errorf("options = %+v", *options)
+	}
+	for _, bad := range []string{"server=y", "transport=dt_shmem,address=1", "address", "address=1,foo=bar"} {
+		if _, err := ParseOptions(bad); err == nil {
+			t.Errorf("ParseOptions(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	vmSide, debuggerSide := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- handshake(vmSide) }()
+	debuggerSide.Write([]byte(HANDSHAKE))
+	buf := make([]byte, len(HANDSHAKE))
+	if _, err := io.ReadFull(debuggerSide, buf); err != nil || string(buf) != HANDSHAKE {
+		t.Errorf("handshake reply = %q, %v", buf, err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("handshake: %v", err)
+	}
+
+	vmSide, debuggerSide = net.Pipe()
+	go func() { done <- handshake(vmSide) }()
+	debuggerSide.Write([]byte("JDWP-Handshakx"))
+	if err := <-done; err == nil {
+		t.Error("handshake accepted a bad greeting")
+	}
+}
+
+// client 测试用的调试器，按JDWP协议发送命令并读取回复和事件
+type client struct {
+	t       *testing.T
+	conn    net.Conn
+	nextID  uint32
+	replies chan *packet
+	events  chan *packet
+}
+
+func dial(t *testing.T, address string) *client {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte(HANDSHAKE))
+	buf := make([]byte, len(HANDSHAKE))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != HANDSHAKE {
+		t.Fatalf("handshake reply = %q, %v", buf, err)
+	}
+	c := &client{
+		t:       t,
+		conn:    conn,
+		replies: make(chan *packet, 16),
+		events:  make(chan *packet, 16),
+	}
+	go func() {
+		for {
+			p, err := readPacket(conn)
+			if err != nil {
+				close(c.events)
+				return
+			}
+			if p.flags&FLAG_REPLY != 0 {
+				c.replies <- p
+			} else {
+				c.events <- p
+			}
+		}
+	}()
+	return c
+}
+
+// command 发送命令并等待回复，回复的错误码必须是wantError
+func (self *client) command(commandSet, command uint8, wantError uint16, args *packetWriter) *packetReader {
+	self.t.Helper()
+	self.nextID++
+	p := &packet{id: self.nextID, commandSet: commandSet, command: command}
+	if args != nil {
+		p.data = args.data
+	}
+	if _, err := self.conn.Write(p.bytes()); err != nil {
+		self.t.Fatal(err)
+	}
+	select {
+	case reply := <-self.replies:
+		if reply.id != p.id {
+			self.t.Fatalf("reply id = %d, want %d", reply.id, p.id)
+		}
+		if reply.errorCode != wantError {
+			self.t.Fatalf("command %d/%d: error = %d, want %d", commandSet, command, reply.errorCode, wantError)
+		}
+		return &packetReader{reply.data}
+	case <-time.After(5 * time.Second):
+		self.t.Fatalf("command %d/%d: no reply", commandSet, command)
+		return nil
+	}
+}
+
+// event 读取一个Event.Composite，返回挂起策略和其中唯一的事件的数据
+func (self *client) event(wantKind uint8) (policy uint8, requestID int32, r *packetReader) {
+	self.t.Helper()
+	select {
+	case p, ok := <-self.events:
+		if !ok {
+			self.t.Fatal("connection closed while waiting for an event")
+		}
+		if p.commandSet != CS_EVENT || p.command != CMD_EVENT_COMPOSITE {
+			self.t.Fatalf("got command %d/%d, want Event.Composite", p.commandSet, p.command)
+		}
+		r = &packetReader{p.data}
+		policy = r.readByte()
+		if n := r.readInt(); n != 1 {
+			self.t.Fatalf("composite has %d events, want 1", n)
+		}
+		if kind := r.readByte(); kind != wantKind {
+			self.t.Fatalf("event kind = %d, want %d", kind, wantKind)
+		}
+		return policy, r.readInt(), r
+	case <-time.After(5 * time.Second):
+		self.t.Fatalf("no event of kind %d", wantKind)
+		return
+	}
+}
+
+// portWriter 从虚拟机的标准输出中找到代理监听的端口
+type portWriter struct {
+	port chan string
+}
+
+var listeningPattern = regexp.MustCompile(`address: (\d+)`)
+
+func (self *portWriter) Write(p []byte) (int, error) {
+	if m := listeningPattern.FindSubmatch(p); m != nil {
+		self.port <- string(m[1])
+	}
+	return len(p), nil
+}
+
+// newTestVM 用asm生成不依赖JDK的类
+func newTestVM(t *testing.T, stdout io.Writer) *vm.VM {
+	calc := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Calc", "java/lang/Object")
+	calc.SetSourceFile("Calc.java")
+	add := calc.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "add", "(II)I")
+	start := add.NewLabel()
+	add.Mark(start)
+	add.LineNumber(3, start)
+	add.VarInsn(asm.ILOAD, 0) // pc 0
+	add.VarInsn(asm.ILOAD, 1) // pc 2
+	add.Insn(asm.IADD)        // pc 4
+	add.Insn(asm.IRETURN)     // pc 5
+
+	return asmtest.NewVM(t, vm.Options{Stdout: stdout}, calc)
+}
+
+func TestLoopbackAgent(t *testing.T) {
+	stdout := &portWriter{port: make(chan string, 1)}
+	jvm := newTestVM(t, stdout)
+	if _, err := jvm.LoadClass("Calc"); err != nil {
+		t.Fatal(err)
+	}
+
+	// suspend=y时Start()等到调试器连接之后才返回
+	agents := make(chan *Agent, 1)
+	go func() {
+		agent, err := Start(jvm, &Options{Transport: "dt_socket", Address: "localhost:0", Server: true, Suspend: true})
+		if err != nil {
+			t.Error(err)
+		}
+		agents <- agent
+	}()
+	var port string
+	select {
+	case port = <-stdout.port:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent is not listening")
+	}
+	c := dial(t, "localhost:"+port)
+	agent := <-agents
+	if agent == nil {
+		t.FailNow()
+	}
+
+	results := make(chan interface{}, 1)
+	go func() {
+		result, err := jvm.InvokeStatic("Calc", "add", "(II)I", 40, 2)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- result
+	}()
+
+	// 执行第一条指令之前发送VMStart并挂起
+	policy, _, r := c.event(EVENT_VM_START)
+	if policy != SUSPEND_ALL {
+		t.Errorf("VMStart suspend policy = %d, want SUSPEND_ALL", policy)
+	}
+	threadID := r.readID()
+
+	// VirtualMachine命令集
+	r = c.command(CS_VIRTUAL_MACHINE, 1, ERROR_NONE, nil) // Version
+	if desc := r.readString(); desc == "" {
+		t.Error("empty VM description")
+	}
+	if major, minor := r.readInt(), r.readInt(); major != 1 || minor != 8 {
+		t.Errorf("JDWP version = %d.%d", major, minor)
+	}
+	r = c.command(CS_VIRTUAL_MACHINE, 7, ERROR_NONE, nil) // IDSizes
+	for i := 0; i < 5; i++ {
+		if size := r.readInt(); size != ID_SIZE {
+			t.Errorf("ID size %d = %d", i, size)
+		}
+	}
+	r = c.command(CS_VIRTUAL_MACHINE, 4, ERROR_NONE, nil) // AllThreads
+	if n := r.readInt(); n < 1 {
+		t.Errorf("AllThreads returned %d threads", n)
+	}
+	args := &packetWriter{}
+	args.writeString("LCalc;")
+	r = c.command(CS_VIRTUAL_MACHINE, 2, ERROR_NONE, args) // ClassesBySignature
+	if n := r.readInt(); n != 1 {
+		t.Fatalf("ClassesBySignature(LCalc;) found %d classes", n)
+	}
+	if tag := r.readByte(); tag != TYPE_TAG_CLASS {
+		t.Errorf("type tag = %d", tag)
+	}
+	classID := r.readID()
+
+	// ReferenceType.Methods找到add的methodID
+	args = &packetWriter{}
+	args.writeID(classID)
+	r = c.command(CS_REFERENCE_TYPE, 5, ERROR_NONE, args)
+	var methodID uint64
+	for n := r.readInt(); n > 0; n-- {
+		id, name, descriptor := r.readID(), r.readString(), r.readString()
+		r.readInt()
+		if name == "add" && descriptor == "(II)I" {
+			methodID = id
+		}
+	}
+	if methodID == 0 {
+		t.Fatal("ReferenceType.Methods did not return Calc.add")
+	}
+	args = &packetWriter{}
+	args.writeID(classID)
+	r = c.command(CS_REFERENCE_TYPE, 7, ERROR_NONE, args) // SourceFile
+	if name := r.readString(); name != "Calc.java" {
+		t.Errorf("source file = %q", name)
+	}
+
+	c.command(CS_VIRTUAL_MACHINE, 0xff, ERROR_NOT_IMPLEMENTED, nil)
+	c.command(CS_EVENT_REQUEST, 1, ERROR_INVALID_LENGTH, &packetWriter{})
+
+	// EventRequest.Set：在iadd上设置断点
+	args = &packetWriter{}
+	args.writeByte(EVENT_BREAKPOINT)
+	args.writeByte(SUSPEND_ALL)
+	args.writeInt(1)
+	args.writeByte(MOD_LOCATION_ONLY)
+	args.writeByte(TYPE_TAG_CLASS)
+	args.writeID(classID)
+	args.writeID(methodID)
+	args.writeLong(4)
+	requestID := c.command(CS_EVENT_REQUEST, 1, ERROR_NONE, args).readInt()
+	// 没有位置的断点是无效的
+	args = &packetWriter{}
+	args.writeByte(EVENT_BREAKPOINT)
+	args.writeByte(SUSPEND_ALL)
+	args.writeInt(0)
+	c.command(CS_EVENT_REQUEST, 1, ERROR_INVALID_EVENT_TYPE, args)
+
+	c.command(CS_VIRTUAL_MACHINE, 9, ERROR_NONE, nil) // Resume
+
+	policy, id, r := c.event(EVENT_BREAKPOINT)
+	if policy != SUSPEND_ALL || id != requestID {
+		t.Errorf("breakpoint event: policy = %d, request = %d, want %d", policy, id, requestID)
+	}
+	if thread := r.readID(); thread != threadID {
+		t.Errorf("breakpoint thread = %d, want %d", thread, threadID)
+	}
+	tag, class, method, index := r.readByte(), r.readID(), r.readID(), r.readLong()
+	if tag != TYPE_TAG_CLASS || class != classID || method != methodID || index != 4 {
+		t.Errorf("breakpoint location = %d %d %d %d", tag, class, method, index)
+	}
+	select {
+	case <-results:
+		t.Fatal("method returned while suspended at a breakpoint")
+	default:
+	}
+
+	// 挂起时可以查看线程的帧
+	args = &packetWriter{}
+	args.writeID(threadID)
+	if n := c.command(CS_THREAD_REFERENCE, 7, ERROR_NONE, args).readInt(); n != 1 {
+		t.Errorf("FrameCount = %d, want 1", n)
+	}
+
+	// EventRequest.Clear之后断点不再触发
+	args = &packetWriter{}
+	args.writeByte(EVENT_BREAKPOINT)
+	args.writeInt(requestID)
+	c.command(CS_EVENT_REQUEST, 2, ERROR_NONE, args)
+	c.command(CS_VIRTUAL_MACHINE, 9, ERROR_NONE, nil) // Resume
+
+	select {
+	case result := <-results:
+		if result != int32(42) {
+			t.Errorf("Calc.add(40, 2) = %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("method did not return after Resume")
+	}
+
+	agent.Close()
+	c.event(EVENT_VM_DEATH)
+}
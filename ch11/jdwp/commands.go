@@ -0,0 +1,405 @@
+package jdwp
+
+import "jvmgo/ch11/rtda/heap"
+
+// commandHandler 从r中读取命令的参数，把回复写到w中，出错时panic(jdwpError)
+type commandHandler func(self *Agent, r *packetReader, w *packetWriter)
+
+// 命令集和命令对应的处理函数
+var commandHandlers map[[2]uint8]commandHandler
+
+func init() {
+	commandHandlers = map[[2]uint8]commandHandler{
+		{CS_VIRTUAL_MACHINE, 1}:        (*Agent).vmVersion,
+		{CS_VIRTUAL_MACHINE, 2}:        (*Agent).vmClassesBySignature,
+		{CS_VIRTUAL_MACHINE, 3}:        (*Agent).vmAllClasses,
+		{CS_VIRTUAL_MACHINE, 4}:        (*Agent).vmAllThreads,
+		{CS_VIRTUAL_MACHINE, 5}:        (*Agent).vmTopLevelThreadGroups,
+		{CS_VIRTUAL_MACHINE, 6}:        (*Agent).vmDispose,
+		{CS_VIRTUAL_MACHINE, 7}:        (*Agent).vmIDSizes,
+		{CS_VIRTUAL_MACHINE, 8}:        (*Agent).vmSuspend,
+		{CS_VIRTUAL_MACHINE, 9}:        (*Agent).vmResume,
+		{CS_VIRTUAL_MACHINE, 10}:       (*Agent).vmExit,
+		{CS_VIRTUAL_MACHINE, 11}:       (*Agent).vmCreateString,
+		{CS_VIRTUAL_MACHINE, 12}:       (*Agent).vmCapabilities,
+		{CS_VIRTUAL_MACHINE, 13}:       (*Agent).vmClassPaths,
+		{CS_VIRTUAL_MACHINE, 14}:       (*Agent).ignore, // DisposeObjects
+		{CS_VIRTUAL_MACHINE, 15}:       (*Agent).ignore, // HoldEvents
+		{CS_VIRTUAL_MACHINE, 16}:       (*Agent).ignore, // ReleaseEvents
+		{CS_VIRTUAL_MACHINE, 17}:       (*Agent).vmCapabilitiesNew,
+		{CS_VIRTUAL_MACHINE, 20}:       (*Agent).vmAllClassesWithGeneric,
+		{CS_REFERENCE_TYPE, 1}:         (*Agent).rtSignature,
+		{CS_REFERENCE_TYPE, 2}:         (*Agent).rtClassLoader,
+		{CS_REFERENCE_TYPE, 3}:         (*Agent).rtModifiers,
+		{CS_REFERENCE_TYPE, 4}:         (*Agent).rtFields,
+		{CS_REFERENCE_TYPE, 5}:         (*Agent).rtMethods,
+		{CS_REFERENCE_TYPE, 6}:         (*Agent).rtGetValues,
+		{CS_REFERENCE_TYPE, 7}:         (*Agent).rtSourceFile,
+		{CS_REFERENCE_TYPE, 9}:         (*Agent).rtStatus,
+		{CS_REFERENCE_TYPE, 10}:        (*Agent).rtInterfaces,
+		{CS_REFERENCE_TYPE, 11}:        (*Agent).rtClassObject,
+		{CS_REFERENCE_TYPE, 13}:        (*Agent).rtSignatureWithGeneric,
+		{CS_REFERENCE_TYPE, 14}:        (*Agent).rtFieldsWithGeneric,
+		{CS_REFERENCE_TYPE, 15}:        (*Agent).rtMethodsWithGeneric,
+		{CS_CLASS_TYPE, 1}:             (*Agent).ctSuperclass,
+		{CS_METHOD, 1}:                 (*Agent).methodLineTable,
+		{CS_METHOD, 2}:                 (*Agent).methodVariableTable,
+		{CS_METHOD, 3}:                 (*Agent).methodBytecodes,
+		{CS_METHOD, 5}:                 (*Agent).methodVariableTableWithGeneric,
+		{CS_OBJECT_REFERENCE, 1}:       (*Agent).orReferenceType,
+		{CS_OBJECT_REFERENCE, 2}:       (*Agent).orGetValues,
+		{CS_OBJECT_REFERENCE, 9}:       (*Agent).orIsCollected,
+		{CS_STRING_REFERENCE, 1}:       (*Agent).srValue,
+		{CS_THREAD_REFERENCE, 1}:       (*Agent).trName,
+		{CS_THREAD_REFERENCE, 2}:       (*Agent).vmSuspend,
+		{CS_THREAD_REFERENCE, 3}:       (*Agent).vmResume,
+		{CS_THREAD_REFERENCE, 4}:       (*Agent).trStatus,
+		{CS_THREAD_REFERENCE, 5}:       (*Agent).trThreadGroup,
+		{CS_THREAD_REFERENCE, 6}:       (*Agent).trFrames,
+		{CS_THREAD_REFERENCE, 7}:       (*Agent).trFrameCount,
+		{CS_THREAD_REFERENCE, 12}:      (*Agent).trSuspendCount,
+		{CS_THREAD_GROUP_REFERENCE, 1}: (*Agent).tgrName,
+		{CS_THREAD_GROUP_REFERENCE, 2}: (*Agent).tgrParent,
+		{CS_THREAD_GROUP_REFERENCE, 3}: (*Agent).tgrChildren,
+		{CS_ARRAY_REFERENCE, 1}:        (*Agent).arLength,
+		{CS_ARRAY_REFERENCE, 2}:        (*Agent).arGetValues,
+		{CS_EVENT_REQUEST, 1}:          (*Agent).eventRequestSet,
+		{CS_EVENT_REQUEST, 2}:          (*Agent).eventRequestClear,
+		{CS_EVENT_REQUEST, 3}:          (*Agent).eventRequestClearAllBreakpoints,
+		{CS_STACK_FRAME, 1}:            (*Agent).sfGetValues,
+		{CS_STACK_FRAME, 3}:            (*Agent).sfThisObject,
+		{CS_CLASS_OBJECT_REFERENCE, 1}: (*Agent).corReflectedType,
+	}
+}
+
+func (self *Agent) ignore(r *packetReader, w *packetWriter) {
+	// do nothing
+}
+
+/*
+VirtualMachine命令集
+*/
+
+func (self *Agent) vmVersion(r *packetReader, w *packetWriter) {
+	w.writeString("jvmgo JDWP agent")
+	w.writeInt(1) // jdwpMajor
+	w.writeInt(8) // jdwpMinor
+	w.writeString("1.8.0")
+	w.writeString("jvmgo")
+}
+
+func (self *Agent) vmClassesBySignature(r *packetReader, w *packetWriter) {
+	sig := r.readString()
+	var classes []*heap.Class
+	for _, class := range self.loadedClasses() {
+		if signature(class) == sig {
+			classes = append(classes, class)
+		}
+	}
+	w.writeInt(int32(len(classes)))
+	for _, class := range classes {
+		w.writeByte(typeTag(class))
+		w.writeID(self.ids.id(class))
+		w.writeInt(classStatus(class))
+	}
+}
+
+func (self *Agent) vmAllClasses(r *packetReader, w *packetWriter) {
+	self.writeAllClasses(w, false)
+}
+
+func (self *Agent) vmAllClassesWithGeneric(r *packetReader, w *packetWriter) {
+	self.writeAllClasses(w, true)
+}
+
+func (self *Agent) writeAllClasses(w *packetWriter, withGeneric bool) {
+	classes := self.loadedClasses()
+	w.writeInt(int32(len(classes)))
+	for _, class := range classes {
+		w.writeByte(typeTag(class))
+		w.writeID(self.ids.id(class))
+		w.writeString(signature(class))
+		if withGeneric {
+			w.writeString("") //没有泛型信息
+		}
+		w.writeInt(classStatus(class))
+	}
+}
+
+// loadedClasses 调试器看不到基本类型的类
+func (self *Agent) loadedClasses() []*heap.Class {
+	var classes []*heap.Class
+	for _, class := range self.loader.LoadedClasses() {
+		if !class.IsPrimitive() {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+func (self *Agent) vmAllThreads(r *packetReader, w *packetWriter) {
+	threads := self.runtime.AllThreads()
+	w.writeInt(int32(len(threads)))
+	for _, thread := range threads {
+		w.writeID(self.ids.id(thread))
+	}
+}
+
+func (self *Agent) vmTopLevelThreadGroups(r *packetReader, w *packetWriter) {
+	w.writeInt(1)
+	w.writeID(self.ids.id(mainThreadGroup))
+}
+
+func (self *Agent) vmDispose(r *packetReader, w *packetWriter) {
+	self.requests = nil
+	self.suspendCount = 0 //回复之后由读goroutine发现连接断开
+}
+
+func (self *Agent) vmIDSizes(r *packetReader, w *packetWriter) {
+	for i := 0; i < 5; i++ { // fieldID methodID objectID referenceTypeID frameID
+		w.writeInt(ID_SIZE)
+	}
+}
+
+// vmSuspend 所有线程在同一个goroutine中执行，挂起一个线程和挂起所有线程是一样的
+func (self *Agent) vmSuspend(r *packetReader, w *packetWriter) {
+	self.suspendCount++
+}
+
+func (self *Agent) vmResume(r *packetReader, w *packetWriter) {
+	if self.suspendCount > 0 {
+		self.suspendCount--
+	}
+}
+
+func (self *Agent) vmExit(r *packetReader, w *packetWriter) {
+	self.runtime.Halt(int(r.readInt()))
+	self.suspendCount = 0
+}
+
+func (self *Agent) vmCreateString(r *packetReader, w *packetWriter) {
+	w.writeID(self.ids.id(heap.JString(self.loader, r.readString())))
+}
+
+func (self *Agent) vmCapabilities(r *packetReader, w *packetWriter) {
+	self.writeCapabilities(w, 7)
+}
+
+func (self *Agent) vmCapabilitiesNew(r *packetReader, w *packetWriter) {
+	self.writeCapabilities(w, 32)
+}
+
+// 只支持获取字节码(canGetBytecodes)和按源文件名过滤(canUseSourceNameFilters)
+func (self *Agent) writeCapabilities(w *packetWriter, n int) {
+	for i := 0; i < n; i++ {
+		w.writeBoolean(i == 2 || i == 18)
+	}
+}
+
+func (self *Agent) vmClassPaths(r *packetReader, w *packetWriter) {
+	w.writeString("")
+	w.writeInt(0) // classpaths
+	w.writeInt(0) // bootclasspaths
+}
+
+/*
+ReferenceType命令集
+*/
+
+func (self *Agent) rtSignature(r *packetReader, w *packetWriter) {
+	w.writeString(signature(self.readClass(r)))
+}
+
+func (self *Agent) rtSignatureWithGeneric(r *packetReader, w *packetWriter) {
+	w.writeString(signature(self.readClass(r)))
+	w.writeString("")
+}
+
+// rtClassLoader 所有类都由启动类加载器加载
+func (self *Agent) rtClassLoader(r *packetReader, w *packetWriter) {
+	self.readClass(r)
+	w.writeID(0)
+}
+
+func (self *Agent) rtModifiers(r *packetReader, w *packetWriter) {
+	w.writeInt(int32(self.readClass(r).AccessFlags()))
+}
+
+func (self *Agent) rtFields(r *packetReader, w *packetWriter) {
+	self.writeFields(w, self.readClass(r), false)
+}
+
+func (self *Agent) rtFieldsWithGeneric(r *packetReader, w *packetWriter) {
+	self.writeFields(w, self.readClass(r), true)
+}
+
+func (self *Agent) writeFields(w *packetWriter, class *heap.Class, withGeneric bool) {
+	fields := class.Fields()
+	w.writeInt(int32(len(fields)))
+	for _, field := range fields {
+		w.writeID(self.ids.id(field))
+		w.writeString(field.Name())
+		w.writeString(field.Descriptor())
+		if withGeneric {
+			w.writeString("")
+		}
+		w.writeInt(int32(field.AccessFlags()))
+	}
+}
+
+func (self *Agent) rtMethods(r *packetReader, w *packetWriter) {
+	self.writeMethods(w, self.readClass(r), false)
+}
+
+func (self *Agent) rtMethodsWithGeneric(r *packetReader, w *packetWriter) {
+	self.writeMethods(w, self.readClass(r), true)
+}
+
+func (self *Agent) writeMethods(w *packetWriter, class *heap.Class, withGeneric bool) {
+	methods := class.Methods()
+	w.writeInt(int32(len(methods)))
+	for _, method := range methods {
+		w.writeID(self.ids.id(method))
+		w.writeString(method.Name())
+		w.writeString(method.Descriptor())
+		if withGeneric {
+			w.writeString("")
+		}
+		w.writeInt(int32(method.AccessFlags()))
+	}
+}
+
+// rtGetValues 读取静态变量
+func (self *Agent) rtGetValues(r *packetReader, w *packetWriter) {
+	self.readClass(r)
+	n := r.readInt()
+	w.writeInt(n)
+	for i := int32(0); i < n; i++ {
+		field := self.readField(r)
+		if !field.IsStatic() {
+			panic(jdwpError(ERROR_INVALID_FIELDID))
+		}
+		self.writeValue(w, field.Class().StaticVars(), field.SlotId(), field.Descriptor())
+	}
+}
+
+func (self *Agent) rtSourceFile(r *packetReader, w *packetWriter) {
+	class := self.readClass(r)
+	if class.SourceFile() == "" || class.SourceFile() == "Unknown" {
+		panic(jdwpError(ERROR_ABSENT_INFORMATION))
+	}
+	w.writeString(class.SourceFile())
+}
+
+func (self *Agent) rtStatus(r *packetReader, w *packetWriter) {
+	w.writeInt(classStatus(self.readClass(r)))
+}
+
+func (self *Agent) rtInterfaces(r *packetReader, w *packetWriter) {
+	interfaces := self.readClass(r).Interfaces()
+	w.writeInt(int32(len(interfaces)))
+	for _, iface := range interfaces {
+		w.writeID(self.ids.id(iface))
+	}
+}
+
+func (self *Agent) rtClassObject(r *packetReader, w *packetWriter) {
+	w.writeID(self.ids.id(self.readClass(r).JClass()))
+}
+
+/*
+ClassType命令集
+*/
+
+func (self *Agent) ctSuperclass(r *packetReader, w *packetWriter) {
+	superClass := self.readClass(r).SuperClass()
+	if superClass == nil {
+		w.writeID(0)
+	} else {
+		w.writeID(self.ids.id(superClass))
+	}
+}
+
+/*
+Method命令集
+*/
+
+func (self *Agent) methodLineTable(r *packetReader, w *packetWriter) {
+	self.readClass(r)
+	method := self.readMethod(r)
+	if method.IsNative() || method.IsAbstract() {
+		w.writeLong(NATIVE_LOCATION)
+		w.writeLong(NATIVE_LOCATION)
+		w.writeInt(0)
+		return
+	}
+	lineNumberTable := method.LineNumberTable()
+	if lineNumberTable == nil {
+		panic(jdwpError(ERROR_ABSENT_INFORMATION))
+	}
+	w.writeLong(0)
+	w.writeLong(int64(len(method.Code()) - 1))
+	entries := lineNumberTable.LineNumberTable()
+	w.writeInt(int32(len(entries)))
+	for _, entry := range entries {
+		w.writeLong(int64(entry.StartPc()))
+		w.writeInt(int32(entry.LineNumber()))
+	}
+}
+
+func (self *Agent) methodVariableTable(r *packetReader, w *packetWriter) {
+	self.writeVariableTable(r, w, false)
+}
+
+func (self *Agent) methodVariableTableWithGeneric(r *packetReader, w *packetWriter) {
+	self.writeVariableTable(r, w, true)
+}
+
+func (self *Agent) writeVariableTable(r *packetReader, w *packetWriter, withGeneric bool) {
+	self.readClass(r)
+	method := self.readMethod(r)
+	vars := method.LocalVariableTable()
+	if vars == nil {
+		panic(jdwpError(ERROR_ABSENT_INFORMATION))
+	}
+	w.writeInt(int32(method.ArgSlotCount())) //参数占用的slot数，包括this
+	w.writeInt(int32(len(vars)))
+	for _, v := range vars {
+		w.writeLong(int64(v.StartPC()))
+		w.writeString(v.Name())
+		w.writeString(v.Descriptor())
+		if withGeneric {
+			w.writeString("")
+		}
+		w.writeInt(int32(v.Length()))
+		w.writeInt(int32(v.Index()))
+	}
+}
+
+func (self *Agent) methodBytecodes(r *packetReader, w *packetWriter) {
+	self.readClass(r)
+	code := self.readMethod(r).Code()
+	w.writeInt(int32(len(code)))
+	w.data = append(w.data, code...)
+}
+
+/*
+ClassObjectReference和StringReference命令集
+*/
+
+func (self *Agent) corReflectedType(r *packetReader, w *packetWriter) {
+	class, ok := self.readNonNullObject(r).Extra().(*heap.Class)
+	if !ok {
+		panic(jdwpError(ERROR_INVALID_OBJECT))
+	}
+	w.writeByte(typeTag(class))
+	w.writeID(self.ids.id(class))
+}
+
+func (self *Agent) srValue(r *packetReader, w *packetWriter) {
+	obj := self.readNonNullObject(r)
+	if obj.Class().Name() != "java/lang/String" {
+		panic(jdwpError(ERROR_INVALID_OBJECT))
+	}
+	w.writeString(heap.GoString(obj))
+}
@@ -0,0 +1,145 @@
+package jdwp
+
+import (
+	"jvmgo/ch11/rtda"
+)
+
+/*
+ThreadReference命令集
+*/
+
+func (self *Agent) trName(r *packetReader, w *packetWriter) {
+	thread := self.readThread(r)
+	if thread.JThread() == nil {
+		w.writeString("main") //主线程的Thread对象还没有创建
+	} else {
+		w.writeString(rtda.ThreadName(thread.JThread()))
+	}
+}
+
+func (self *Agent) trStatus(r *packetReader, w *packetWriter) {
+	thread := self.readThread(r)
+	status := int32(THREAD_STATUS_RUNNING)
+	if thread.IsWaiting() {
+		status = THREAD_STATUS_WAIT
+	} else if thread.EnteringMonitor() != nil {
+		status = THREAD_STATUS_MONITOR
+	}
+	w.writeInt(status)
+	if self.suspendCount > 0 {
+		w.writeInt(SUSPEND_STATUS_SUSPEND)
+	} else {
+		w.writeInt(0)
+	}
+}
+
+func (self *Agent) trThreadGroup(r *packetReader, w *packetWriter) {
+	self.readThread(r)
+	w.writeID(self.ids.id(mainThreadGroup))
+}
+
+func (self *Agent) trFrames(r *packetReader, w *packetWriter) {
+	frames := self.javaFrames(self.readThread(r))
+	start := int(r.readInt())
+	length := int(r.readInt())
+	if length == -1 {
+		length = len(frames) - start
+	}
+	if start < 0 || length < 0 || start+length > len(frames) {
+		panic(jdwpError(ERROR_INVALID_LENGTH))
+	}
+	w.writeInt(int32(length))
+	for _, frame := range frames[start : start+length] {
+		w.writeID(self.ids.id(frame))
+		self.writeLocation(w, frame.Method(), self.frameLocation(frame))
+	}
+}
+
+func (self *Agent) trFrameCount(r *packetReader, w *packetWriter) {
+	w.writeInt(int32(len(self.javaFrames(self.readThread(r)))))
+}
+
+func (self *Agent) trSuspendCount(r *packetReader, w *packetWriter) {
+	self.readThread(r)
+	w.writeInt(int32(self.suspendCount))
+}
+
+// javaFrames 线程的帧，从栈顶开始，不包括从Go代码调用Java方法时使用的帧
+func (self *Agent) javaFrames(thread *rtda.Thread) []*rtda.Frame {
+	if self.suspendCount == 0 {
+		panic(jdwpError(ERROR_THREAD_NOT_SUSPENDED))
+	}
+	var frames []*rtda.Frame
+	for _, frame := range thread.GetFrames() {
+		if !frame.Method().IsShim() {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+/*
+ThreadGroupReference命令集
+*/
+
+func (self *Agent) tgrName(r *packetReader, w *packetWriter) {
+	w.writeString(self.readThreadGroup(r).name)
+}
+
+func (self *Agent) tgrParent(r *packetReader, w *packetWriter) {
+	self.readThreadGroup(r)
+	w.writeID(0)
+}
+
+func (self *Agent) tgrChildren(r *packetReader, w *packetWriter) {
+	self.readThreadGroup(r)
+	threads := self.runtime.AllThreads()
+	w.writeInt(int32(len(threads)))
+	for _, thread := range threads {
+		w.writeID(self.ids.id(thread))
+	}
+	w.writeInt(0) //没有子线程组
+}
+
+/*
+StackFrame命令集
+*/
+
+func (self *Agent) sfGetValues(r *packetReader, w *packetWriter) {
+	frame := self.readThreadFrame(r)
+	n := r.readInt()
+	w.writeInt(n)
+	for i := int32(0); i < n; i++ {
+		slot := uint(r.readInt())
+		tag := r.readByte()
+		size := uint(1)
+		if tag == TAG_LONG || tag == TAG_DOUBLE {
+			size = 2
+		}
+		if slot+size > frame.Method().MaxLocals() {
+			panic(jdwpError(ERROR_INVALID_SLOT))
+		}
+		self.writeValue(w, frame.LocalVars(), slot, string(tag))
+	}
+}
+
+func (self *Agent) sfThisObject(r *packetReader, w *packetWriter) {
+	frame := self.readThreadFrame(r)
+	if frame.Method().IsStatic() || frame.Method().IsNative() {
+		self.writeTaggedObject(w, nil)
+	} else {
+		self.writeTaggedObject(w, frame.LocalVars().GetThis())
+	}
+}
+
+// readThreadFrame 读取线程ID和帧ID，帧必须还在线程的栈中
+func (self *Agent) readThreadFrame(r *packetReader) *rtda.Frame {
+	thread := self.readThread(r)
+	frame := self.readFrame(r)
+	for _, f := range self.javaFrames(thread) {
+		if f == frame {
+			return frame
+		}
+	}
+	panic(jdwpError(ERROR_INVALID_FRAMEID))
+}
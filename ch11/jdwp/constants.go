@@ -0,0 +1,131 @@
+package jdwp
+
+// JDWP规范中的常量，只列出用到的部分
+
+const (
+	HANDSHAKE           = "JDWP-Handshake"
+	FLAG_REPLY          = 0x80
+	ID_SIZE             = 8  // 所有ID都是8字节
+	NATIVE_LOCATION     = -1 // 本地方法的帧没有字节码位置
+	CMD_EVENT_COMPOSITE = 100
+)
+
+// 命令集
+const (
+	CS_VIRTUAL_MACHINE        = 1
+	CS_REFERENCE_TYPE         = 2
+	CS_CLASS_TYPE             = 3
+	CS_METHOD                 = 6
+	CS_OBJECT_REFERENCE       = 9
+	CS_STRING_REFERENCE       = 10
+	CS_THREAD_REFERENCE       = 11
+	CS_THREAD_GROUP_REFERENCE = 12
+	CS_ARRAY_REFERENCE        = 13
+	CS_EVENT_REQUEST          = 15
+	CS_STACK_FRAME            = 16
+	CS_CLASS_OBJECT_REFERENCE = 17
+	CS_EVENT                  = 64
+)
+
+// 错误码
+const (
+	ERROR_NONE                 = 0
+	ERROR_INVALID_THREAD       = 10
+	ERROR_INVALID_THREAD_GROUP = 11
+	ERROR_THREAD_NOT_SUSPENDED = 13
+	ERROR_INVALID_OBJECT       = 20
+	ERROR_INVALID_CLASS        = 21
+	ERROR_INVALID_METHODID     = 23
+	ERROR_INVALID_LOCATION     = 24
+	ERROR_INVALID_FIELDID      = 25
+	ERROR_INVALID_FRAMEID      = 30
+	ERROR_INVALID_SLOT         = 35
+	ERROR_NOT_FOUND            = 41
+	ERROR_NOT_IMPLEMENTED      = 99
+	ERROR_ABSENT_INFORMATION   = 101
+	ERROR_INVALID_EVENT_TYPE   = 102
+	ERROR_VM_DEAD              = 112
+	ERROR_INTERNAL             = 113
+	ERROR_INVALID_LENGTH       = 504
+)
+
+// 事件类型
+const (
+	EVENT_SINGLE_STEP   = 1
+	EVENT_BREAKPOINT    = 2
+	EVENT_THREAD_START  = 6
+	EVENT_THREAD_DEATH  = 7
+	EVENT_CLASS_PREPARE = 8
+	EVENT_VM_START      = 90
+	EVENT_VM_DEATH      = 99
+)
+
+// 事件请求的修饰符
+const (
+	MOD_COUNT             = 1
+	MOD_CONDITIONAL       = 2
+	MOD_THREAD_ONLY       = 3
+	MOD_CLASS_ONLY        = 4
+	MOD_CLASS_MATCH       = 5
+	MOD_CLASS_EXCLUDE     = 6
+	MOD_LOCATION_ONLY     = 7
+	MOD_EXCEPTION_ONLY    = 8
+	MOD_FIELD_ONLY        = 9
+	MOD_STEP              = 10
+	MOD_INSTANCE_ONLY     = 11
+	MOD_SOURCE_NAME_MATCH = 12
+)
+
+// 挂起策略
+const (
+	SUSPEND_NONE         = 0
+	SUSPEND_EVENT_THREAD = 1
+	SUSPEND_ALL          = 2
+)
+
+// 单步执行的粒度和深度
+const (
+	STEP_MIN  = 0
+	STEP_LINE = 1
+	STEP_INTO = 0
+	STEP_OVER = 1
+	STEP_OUT  = 2
+)
+
+const (
+	TYPE_TAG_CLASS     = 1
+	TYPE_TAG_INTERFACE = 2
+	TYPE_TAG_ARRAY     = 3
+)
+
+const (
+	CLASS_STATUS_VERIFIED    = 1
+	CLASS_STATUS_PREPARED    = 2
+	CLASS_STATUS_INITIALIZED = 4
+)
+
+const (
+	THREAD_STATUS_RUNNING  = 1
+	THREAD_STATUS_MONITOR  = 3
+	THREAD_STATUS_WAIT     = 4
+	SUSPEND_STATUS_SUSPEND = 1
+)
+
+// 值的类型标记
+const (
+	TAG_ARRAY        = '['
+	TAG_BYTE         = 'B'
+	TAG_CHAR         = 'C'
+	TAG_OBJECT       = 'L'
+	TAG_FLOAT        = 'F'
+	TAG_DOUBLE       = 'D'
+	TAG_INT          = 'I'
+	TAG_LONG         = 'J'
+	TAG_SHORT        = 'S'
+	TAG_VOID         = 'V'
+	TAG_BOOLEAN      = 'Z'
+	TAG_STRING       = 's'
+	TAG_THREAD       = 't'
+	TAG_THREAD_GROUP = 'g'
+	TAG_CLASS_OBJECT = 'c'
+)
@@ -0,0 +1,305 @@
+package jdwp
+
+import (
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"strings"
+)
+
+// 事件请求：断点、单步和类准备事件会被发送，其他种类的请求被接受但是不会产生事件
+
+type eventRequest struct {
+	id            int32
+	eventKind     uint8
+	suspendPolicy uint8
+	count         int32 // Count修饰符，0表示没有
+	thread        *rtda.Thread
+	class         *heap.Class
+	classMatch    []string
+	classExclude  []string
+	sourceMatch   []string
+	method        *heap.Method // LocationOnly修饰符
+	index         int64
+	step          *stepState
+}
+
+// stepState 单步请求创建时线程的状态
+type stepState struct {
+	thread      *rtda.Thread
+	size        int32
+	depth       int32
+	startDepth  uint
+	startMethod *heap.Method
+	startPC     int
+	startLine   int
+}
+
+// EventRequest.Set
+func (self *Agent) eventRequestSet(r *packetReader, w *packetWriter) {
+	req := &eventRequest{
+		eventKind:     r.readByte(),
+		suspendPolicy: r.readByte(),
+	}
+	modifiers := r.readInt()
+	for i := int32(0); i < modifiers; i++ {
+		switch kind := r.readByte(); kind {
+		case MOD_COUNT:
+			req.count = r.readInt()
+		case MOD_CONDITIONAL:
+			r.readInt()
+		case MOD_THREAD_ONLY:
+			req.thread = self.readThread(r)
+		case MOD_CLASS_ONLY:
+			req.class = self.readClass(r)
+		case MOD_CLASS_MATCH:
+			req.classMatch = append(req.classMatch, r.readString())
+		case MOD_CLASS_EXCLUDE:
+			req.classExclude = append(req.classExclude, r.readString())
+		case MOD_LOCATION_ONLY:
+			r.readByte() // type tag
+			req.class = self.readClass(r)
+			req.method = self.readMethod(r)
+			req.index = r.readLong()
+		case MOD_EXCEPTION_ONLY:
+			r.readID()
+			r.readBoolean()
+			r.readBoolean()
+		case MOD_FIELD_ONLY:
+			r.readID()
+			r.readID()
+		case MOD_STEP:
+			req.step = self.newStepState(self.readThread(r), r.readInt(), r.readInt())
+		case MOD_INSTANCE_ONLY:
+			r.readID()
+		case MOD_SOURCE_NAME_MATCH:
+			req.sourceMatch = append(req.sourceMatch, r.readString())
+		default:
+			panic(jdwpError(ERROR_NOT_IMPLEMENTED))
+		}
+	}
+	if req.eventKind == EVENT_BREAKPOINT && req.method == nil ||
+		req.eventKind == EVENT_SINGLE_STEP && req.step == nil {
+		panic(jdwpError(ERROR_INVALID_EVENT_TYPE))
+	}
+	self.nextRequestID++
+	req.id = self.nextRequestID
+	self.requests = append(self.requests, req)
+	w.writeInt(req.id)
+}
+
+// EventRequest.Clear
+func (self *Agent) eventRequestClear(r *packetReader, w *packetWriter) {
+	eventKind := r.readByte()
+	id := r.readInt()
+	for i, req := range self.requests {
+		if req.id == id && req.eventKind == eventKind {
+			self.requests = append(self.requests[:i], self.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+// EventRequest.ClearAllBreakpoints
+func (self *Agent) eventRequestClearAllBreakpoints(r *packetReader, w *packetWriter) {
+	requests := self.requests[:0]
+	for _, req := range self.requests {
+		if req.eventKind != EVENT_BREAKPOINT {
+			requests = append(requests, req)
+		}
+	}
+	self.requests = requests
+}
+
+func (self *Agent) newStepState(thread *rtda.Thread, size, depth int32) *stepState {
+	step := &stepState{thread: thread, size: size, depth: depth, startDepth: thread.StackDepth()}
+	if !thread.IsStackEmpty() {
+		top := thread.TopFrame()
+		step.reset(top, int(self.frameLocation(top)))
+	}
+	return step
+}
+
+// reset 没有Count修饰符的单步请求会一直有效，每次产生事件之后从当前位置重新开始
+func (self *stepState) reset(frame *rtda.Frame, pc int) {
+	self.startDepth = frame.Thread().StackDepth()
+	self.startMethod = frame.Method()
+	self.startPC = pc
+	self.startLine = self.startMethod.GetLineNumber(pc)
+}
+
+// done 线程执行到frame的当前指令时，单步执行是否结束
+func (self *stepState) done(frame *rtda.Frame) bool {
+	thread := frame.Thread()
+	if thread != self.thread {
+		return false
+	}
+	method := frame.Method()
+	pc := thread.PC()
+	depth := thread.StackDepth()
+	switch {
+	case depth < self.startDepth:
+		return true //方法已经返回
+	case depth > self.startDepth:
+		//进入被调用的方法，按行单步时跳过没有行号信息的方法
+		return self.depth == STEP_INTO && (self.size == STEP_MIN || method.GetLineNumber(pc) >= 0)
+	case self.depth == STEP_OUT:
+		return false
+	case method != self.startMethod || self.size == STEP_MIN:
+		return true
+	}
+	line := method.GetLineNumber(pc)
+	return line != self.startLine || pc < self.startPC //到了新的一行，或者跳回到这一行的开头(循环)
+}
+
+// matches 检查除了位置和单步之外的修饰符
+func (self *eventRequest) matches(thread *rtda.Thread, class *heap.Class) bool {
+	if self.thread != nil && self.thread != thread {
+		return false
+	}
+	if self.class != nil && !self.class.IsAssignableFrom(class) {
+		return false
+	}
+	javaName := class.JavaName()
+	for _, pattern := range self.classMatch {
+		if !matchPattern(pattern, javaName) {
+			return false
+		}
+	}
+	for _, pattern := range self.classExclude {
+		if matchPattern(pattern, javaName) {
+			return false
+		}
+	}
+	for _, pattern := range self.sourceMatch {
+		if !matchPattern(pattern, class.SourceFile()) {
+			return false
+		}
+	}
+	return true
+}
+
+// countDown Count修饰符：第count次满足条件时才产生事件，之后请求失效
+func (self *eventRequest) countDown() (fire, expired bool) {
+	if self.count == 0 {
+		return true, false
+	}
+	self.count--
+	return self.count == 0, self.count == 0
+}
+
+// matchPattern 类名模式可以以*开头或者结尾
+func matchPattern(pattern, name string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(name, pattern[1:])
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	}
+	return pattern == name
+}
+
+// checkLocationEvents 在执行frame的当前指令之前检查断点和单步请求
+func (self *Agent) checkLocationEvents(frame *rtda.Frame) {
+	if len(self.requests) == 0 {
+		return
+	}
+	thread := frame.Thread()
+	method := frame.Method()
+	pc := int64(thread.PC())
+	var fired []*eventRequest
+	for _, req := range self.requests {
+		switch req.eventKind {
+		case EVENT_BREAKPOINT:
+			if req.method != method || req.index != pc {
+				continue
+			}
+		case EVENT_SINGLE_STEP:
+			if !req.step.done(frame) {
+				continue
+			}
+			req.step.reset(frame, thread.PC())
+		default:
+			continue
+		}
+		if req.matches(thread, method.Class()) {
+			fired = append(fired, req)
+		}
+	}
+	self.sendEvents(fired, func(w *packetWriter) {
+		w.writeID(self.ids.id(thread))
+		self.writeLocation(w, method, pc)
+	})
+}
+
+// classLoaded 类加载完成后发送ClassPrepare事件
+func (self *Agent) classLoaded(class *heap.Class) {
+	thread := self.thread
+	if self.conn == nil || !self.started || thread == nil {
+		return //还没有开始执行Java代码
+	}
+	var fired []*eventRequest
+	for _, req := range self.requests {
+		if req.eventKind == EVENT_CLASS_PREPARE && req.matches(thread, class) {
+			fired = append(fired, req)
+		}
+	}
+	self.sendEvents(fired, func(w *packetWriter) {
+		w.writeID(self.ids.id(thread))
+		w.writeByte(typeTag(class))
+		w.writeID(self.ids.id(class))
+		w.writeString(signature(class))
+		w.writeInt(classStatus(class))
+	})
+	if len(fired) > 0 {
+		self.waitWhileSuspended(nil)
+	}
+}
+
+/*
+sendEvents 把同一个位置触发的事件放在一个Event.Composite中发送
+挂起策略取所有请求中最严格的，不是SUSPEND_NONE时挂起虚拟机
+*/
+func (self *Agent) sendEvents(fired []*eventRequest, writeEventData func(w *packetWriter)) {
+	var events []*eventRequest
+	for _, req := range fired {
+		fire, expired := req.countDown()
+		if expired {
+			self.removeRequest(req)
+		}
+		if fire {
+			events = append(events, req)
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+	policy := uint8(SUSPEND_NONE)
+	for _, req := range events {
+		if req.suspendPolicy > policy {
+			policy = req.suspendPolicy
+		}
+	}
+	w := &packetWriter{}
+	w.writeByte(policy)
+	w.writeInt(int32(len(events)))
+	for _, req := range events {
+		w.writeByte(req.eventKind)
+		w.writeInt(req.id)
+		writeEventData(w)
+	}
+	if policy != SUSPEND_NONE {
+		self.suspendCount++
+	}
+	self.sendEvent(w)
+}
+
+func (self *Agent) removeRequest(req *eventRequest) {
+	for i, r := range self.requests {
+		if r == req {
+			self.requests = append(self.requests[:i], self.requests[i+1:]...)
+			return
+		}
+	}
+}
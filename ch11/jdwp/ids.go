@@ -0,0 +1,143 @@
+package jdwp
+
+import (
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+)
+
+/*
+ids 给调试器看到的对象、类、方法、字段、线程、线程组和帧分配ID，0表示null
+所有种类共用一个ID空间，ID一旦分配就不会回收，对应的对象也不会被回收(-XX:+UseJvmgoGC时作为根，见objects())
+*/
+type ids struct {
+	next uint64
+	byID map[uint64]interface{}
+	toID map[interface{}]uint64
+}
+
+// 虚拟机没有线程组，所有线程都属于这个线程组
+type threadGroup struct {
+	name string
+}
+
+var mainThreadGroup = &threadGroup{name: "main"}
+
+func newIDs() *ids {
+	return &ids{
+		next: 1,
+		byID: map[uint64]interface{}{},
+		toID: map[interface{}]uint64{},
+	}
+}
+
+func (self *ids) id(x interface{}) uint64 {
+	switch v := x.(type) {
+	case nil:
+		return 0
+	case *heap.Object:
+		if v == nil {
+			return 0
+		}
+		if thread, ok := v.Extra().(*rtda.Thread); ok {
+			x = thread //Thread对象和线程使用同一个ID
+		}
+	case *heap.Class:
+		if v == nil {
+			return 0
+		}
+	}
+	if id, ok := self.toID[x]; ok {
+		return id
+	}
+	id := self.next
+	self.next++
+	self.byID[id] = x
+	self.toID[x] = id
+	return id
+}
+
+func (self *ids) get(id uint64) interface{} {
+	return self.byID[id]
+}
+
+// objects 分配了ID的所有对象，垃圾回收时作为根
+func (self *ids) objects() []*heap.Object {
+	var objs []*heap.Object
+	for _, x := range self.byID {
+		if obj, ok := x.(*heap.Object); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+/*
+下面从命令参数中读取ID并转换成对应的值，ID无效时panic
+*/
+
+func (self *Agent) readObject(r *packetReader) *heap.Object {
+	id := r.readID()
+	if id == 0 {
+		return nil
+	}
+	switch x := self.ids.get(id).(type) {
+	case *heap.Object:
+		return x
+	case *rtda.Thread:
+		if x.JThread() != nil {
+			return x.JThread()
+		}
+	case *heap.Class:
+		return x.JClass()
+	}
+	panic(jdwpError(ERROR_INVALID_OBJECT))
+}
+
+func (self *Agent) readClass(r *packetReader) *heap.Class {
+	if class, ok := self.ids.get(r.readID()).(*heap.Class); ok {
+		return class
+	}
+	panic(jdwpError(ERROR_INVALID_CLASS))
+}
+
+func (self *Agent) readMethod(r *packetReader) *heap.Method {
+	if method, ok := self.ids.get(r.readID()).(*heap.Method); ok {
+		return method
+	}
+	panic(jdwpError(ERROR_INVALID_METHODID))
+}
+
+func (self *Agent) readField(r *packetReader) *heap.Field {
+	if field, ok := self.ids.get(r.readID()).(*heap.Field); ok {
+		return field
+	}
+	panic(jdwpError(ERROR_INVALID_FIELDID))
+}
+
+func (self *Agent) readThread(r *packetReader) *rtda.Thread {
+	if thread, ok := self.ids.get(r.readID()).(*rtda.Thread); ok {
+		return thread
+	}
+	panic(jdwpError(ERROR_INVALID_THREAD))
+}
+
+func (self *Agent) readThreadGroup(r *packetReader) *threadGroup {
+	if group, ok := self.ids.get(r.readID()).(*threadGroup); ok {
+		return group
+	}
+	panic(jdwpError(ERROR_INVALID_THREAD_GROUP))
+}
+
+func (self *Agent) readFrame(r *packetReader) *rtda.Frame {
+	if frame, ok := self.ids.get(r.readID()).(*rtda.Frame); ok {
+		return frame
+	}
+	panic(jdwpError(ERROR_INVALID_FRAMEID))
+}
+
+func (self *Agent) readNonNullObject(r *packetReader) *heap.Object {
+	if obj := self.readObject(r); obj != nil {
+		return obj
+	}
+	panic(jdwpError(ERROR_INVALID_OBJECT))
+}
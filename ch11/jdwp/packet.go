@@ -0,0 +1,145 @@
+package jdwp
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+/*
+JDWP的数据包，命令包和回复包有相同的11字节头部：长度(4)、id(4)、flags(1)
+命令包接着是命令集(1)和命令(1)，回复包接着是错误码(2)
+*/
+type packet struct {
+	id         uint32
+	flags      uint8
+	commandSet uint8
+	command    uint8
+	errorCode  uint16
+	data       []byte
+}
+
+func readPacket(r io.Reader) (*packet, error) {
+	var header [11]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < 11 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := &packet{
+		id:    binary.BigEndian.Uint32(header[4:8]),
+		flags: header[8],
+		data:  make([]byte, length-11),
+	}
+	if p.flags&FLAG_REPLY != 0 {
+		p.errorCode = binary.BigEndian.Uint16(header[9:11])
+	} else {
+		p.commandSet = header[9]
+		p.command = header[10]
+	}
+	if _, err := io.ReadFull(r, p.data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (self *packet) bytes() []byte {
+	w := &packetWriter{}
+	w.writeInt(int32(11 + len(self.data)))
+	w.writeInt(int32(self.id))
+	w.writeByte(self.flags)
+	if self.flags&FLAG_REPLY != 0 {
+		w.writeShort(int16(self.errorCode))
+	} else {
+		w.writeByte(self.commandSet)
+		w.writeByte(self.command)
+	}
+	w.data = append(w.data, self.data...)
+	return w.data
+}
+
+// jdwpError 命令处理过程中出错时panic(jdwpError(code))，由dispatch()转换成回复的错误码
+type jdwpError uint16
+
+// packetReader 按大端序读取命令的参数，数据不够时panic
+type packetReader struct {
+	data []byte
+}
+
+func (self *packetReader) read(n int) []byte {
+	if len(self.data) < n {
+		panic(jdwpError(ERROR_INVALID_LENGTH))
+	}
+	b := self.data[:n]
+	self.data = self.data[n:]
+	return b
+}
+
+func (self *packetReader) readByte() uint8 {
+	return self.read(1)[0]
+}
+func (self *packetReader) readBoolean() bool {
+	return self.readByte() != 0
+}
+func (self *packetReader) readShort() int16 {
+	return int16(binary.BigEndian.Uint16(self.read(2)))
+}
+func (self *packetReader) readInt() int32 {
+	return int32(binary.BigEndian.Uint32(self.read(4)))
+}
+func (self *packetReader) readLong() int64 {
+	return int64(binary.BigEndian.Uint64(self.read(8)))
+}
+func (self *packetReader) readID() uint64 {
+	return binary.BigEndian.Uint64(self.read(ID_SIZE))
+}
+func (self *packetReader) readString() string {
+	n := self.readInt()
+	return string(self.read(int(n)))
+}
+
+// packetWriter 按大端序写回复和事件的数据
+type packetWriter struct {
+	data []byte
+}
+
+func (self *packetWriter) writeByte(val uint8) {
+	self.data = append(self.data, val)
+}
+func (self *packetWriter) writeBoolean(val bool) {
+	if val {
+		self.writeByte(1)
+	} else {
+		self.writeByte(0)
+	}
+}
+func (self *packetWriter) writeShort(val int16) {
+	self.data = append(self.data, byte(val>>8), byte(val))
+}
+func (self *packetWriter) writeInt(val int32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(val))
+	self.data = append(self.data, buf[:]...)
+}
+func (self *packetWriter) writeLong(val int64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(val))
+	self.data = append(self.data, buf[:]...)
+}
+func (self *packetWriter) writeFloat(val float32) {
+	self.writeInt(int32(math.Float32bits(val)))
+}
+func (self *packetWriter) writeDouble(val float64) {
+	self.writeLong(int64(math.Float64bits(val)))
+}
+func (self *packetWriter) writeID(id uint64) {
+	self.writeLong(int64(id))
+}
+
+// writeString JDWP的字符串是4字节长度加UTF-8编码
+func (self *packetWriter) writeString(s string) {
+	self.writeInt(int32(len(s)))
+	self.data = append(self.data, s...)
+}
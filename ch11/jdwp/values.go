@@ -0,0 +1,160 @@
+package jdwp
+
+import (
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+)
+
+// slots 局部变量表(rtda.LocalVars)、静态变量和实例变量(heap.Slots)
+type slots interface {
+	GetInt(index uint) int32
+	GetLong(index uint) int64
+	GetFloat(index uint) float32
+	GetDouble(index uint) float64
+	GetRef(index uint) *heap.Object
+}
+
+// writeValue 写入带类型标记的值，descriptor是变量的描述符，也可以是StackFrame.GetValues中的类型标记
+func (self *Agent) writeValue(w *packetWriter, vars slots, index uint, descriptor string) {
+	switch descriptor[0] {
+	case 'Z', 'B', 'C', 'S', 'I':
+		w.writeByte(descriptor[0])
+		writePrimitive(w, descriptor[0], int64(vars.GetInt(index)))
+	case 'J':
+		w.writeByte(TAG_LONG)
+		w.writeLong(vars.GetLong(index))
+	case 'F':
+		w.writeByte(TAG_FLOAT)
+		w.writeFloat(vars.GetFloat(index))
+	case 'D':
+		w.writeByte(TAG_DOUBLE)
+		w.writeDouble(vars.GetDouble(index))
+	default:
+		self.writeTaggedObject(w, vars.GetRef(index))
+	}
+}
+
+// writePrimitive 按类型写入int、short、char、byte或者boolean，不带类型标记
+func writePrimitive(w *packetWriter, tag uint8, val int64) {
+	switch tag {
+	case TAG_BOOLEAN:
+		w.writeBoolean(val != 0)
+	case TAG_BYTE:
+		w.writeByte(uint8(val))
+	case TAG_CHAR, TAG_SHORT:
+		w.writeShort(int16(val))
+	case TAG_INT:
+		w.writeInt(int32(val))
+	case TAG_LONG:
+		w.writeLong(val)
+	}
+}
+
+func (self *Agent) writeTaggedObject(w *packetWriter, obj *heap.Object) {
+	w.writeByte(objectTag(obj))
+	w.writeID(self.ids.id(obj))
+}
+
+// objectTag 根据对象的类型选择标记，调试器据此决定用哪个命令集访问对象
+func objectTag(obj *heap.Object) uint8 {
+	if obj == nil {
+		return TAG_OBJECT
+	}
+	if obj.Class().IsArray() {
+		return TAG_ARRAY
+	}
+	if obj.Class().Name() == "java/lang/String" {
+		return TAG_STRING
+	}
+	switch obj.Extra().(type) {
+	case *heap.Class:
+		return TAG_CLASS_OBJECT
+	case *rtda.Thread:
+		return TAG_THREAD
+	}
+	return TAG_OBJECT
+}
+
+/*
+ObjectReference命令集
+*/
+
+func (self *Agent) orReferenceType(r *packetReader, w *packetWriter) {
+	class := self.readNonNullObject(r).Class()
+	w.writeByte(typeTag(class))
+	w.writeID(self.ids.id(class))
+}
+
+func (self *Agent) orGetValues(r *packetReader, w *packetWriter) {
+	obj := self.readNonNullObject(r)
+	n := r.readInt()
+	w.writeInt(n)
+	for i := int32(0); i < n; i++ {
+		field := self.readField(r)
+		if field.IsStatic() {
+			self.writeValue(w, field.Class().StaticVars(), field.SlotId(), field.Descriptor())
+		} else if obj.IsInstanceOf(field.Class()) {
+			self.writeValue(w, obj.Fields(), field.SlotId(), field.Descriptor())
+		} else {
+			panic(jdwpError(ERROR_INVALID_FIELDID))
+		}
+	}
+}
+
+// orIsCollected 调试器引用的对象不会被回收
+func (self *Agent) orIsCollected(r *packetReader, w *packetWriter) {
+	self.readNonNullObject(r)
+	w.writeBoolean(false)
+}
+
+/*
+ArrayReference命令集
+*/
+
+func (self *Agent) arLength(r *packetReader, w *packetWriter) {
+	w.writeInt(self.readArray(r).ArrayLength())
+}
+
+// arGetValues 基本类型的元素不带类型标记，引用类型的元素带类型标记
+func (self *Agent) arGetValues(r *packetReader, w *packetWriter) {
+	arr := self.readArray(r)
+	first := r.readInt()
+	length := r.readInt()
+	if first < 0 || length < 0 || first+length > arr.ArrayLength() {
+		panic(jdwpError(ERROR_INVALID_LENGTH))
+	}
+	tag := arr.Class().Name()[1]
+	if tag == 'L' {
+		tag = TAG_OBJECT
+	}
+	w.writeByte(tag)
+	w.writeInt(length)
+	for i := first; i < first+length; i++ {
+		switch tag {
+		case TAG_BOOLEAN, TAG_BYTE:
+			writePrimitive(w, tag, int64(arr.Bytes()[i]))
+		case TAG_CHAR:
+			writePrimitive(w, tag, int64(arr.CharAt(i)))
+		case TAG_SHORT:
+			writePrimitive(w, tag, int64(arr.Shorts()[i]))
+		case TAG_INT:
+			writePrimitive(w, tag, int64(arr.Ints()[i]))
+		case TAG_LONG:
+			w.writeLong(arr.Longs()[i])
+		case TAG_FLOAT:
+			w.writeFloat(arr.Floats()[i])
+		case TAG_DOUBLE:
+			w.writeDouble(arr.Doubles()[i])
+		default:
+			self.writeTaggedObject(w, arr.Refs()[i])
+		}
+	}
+}
+
+func (self *Agent) readArray(r *packetReader) *heap.Object {
+	obj := self.readNonNullObject(r)
+	if !obj.Class().IsArray() {
+		panic(jdwpError(ERROR_INVALID_OBJECT))
+	}
+	return obj
+}
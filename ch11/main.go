@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
+	"jvmgo/ch11/classfile"
 	"jvmgo/ch11/classpath"
-	"jvmgo/ch11/rtda/heap"
+	"jvmgo/ch11/javap"
+	"jvmgo/ch11/jdwp"
+	"jvmgo/ch11/vm"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -13,22 +20,132 @@ func main() {
 		fmt.Println("version 0.0.1")
 	} else if cmd.helpFlag || cmd.class == "" {
 		printUsage()
+	} else if cmd.javapFlag {
+		os.Exit(printClassFile(cmd))
 	} else {
-		startJVM(cmd)
+		os.Exit(startJVM(cmd))
 	}
 }
 
-func startJVM(cmd *Cmd) {
-	cp := classpath.Parse(cmd.XjreOption, cmd.cpOption)
-	classLoader := heap.NewClassLoader(cp, cmd.verboseClassFlag)
-	className := strings.Replace(cmd.class, ".", "/", -1)
-	mainClass := classLoader.LoadClass(className)
+func startJVM(cmd *Cmd) int {
+	var trace *vm.TraceOptions
+	if cmd.Xtrace.enabled {
+		var err error
+		if trace, err = vm.ParseTraceOptions(cmd.Xtrace.options); err == nil && trace.File != "" {
+			var f *os.File
+			if f, err = os.Create(trace.File); err == nil {
+				w := bufio.NewWriter(f)
+				defer func() {
+					w.Flush()
+					f.Close()
+				}()
+				trace.Output = w
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	jvm, err := vm.New(vm.Options{
+		JreOption:    cmd.XjreOption,
+		CpOption:     cmd.cpOption,
+		VerboseClass: cmd.verboseClassFlag,
+		VerboseInst:  cmd.verboseInstFlag,
+		Debug:        cmd.XdebugFlag,
+		Prof:         cmd.XprofFlag || cmd.XprofExactFlag,
+		ProfExact:    cmd.XprofExactFlag,
+		ProfInterval: cmd.XprofInterval,
+		Trace:        trace,
+		InitialHeap:  int64(cmd.Xms),
+		MaxHeap:      int64(cmd.Xmx),
+		StackSize:    int64(cmd.Xss),
 
-	mainMethod := mainClass.GetMainMethod() //获得Main方法
+		HeapDumpOnOutOfMemoryError: cmd.XXheapDumpOnOOM,
+		HeapDumpPath:               cmd.XXheapDumpPath,
+		ThreadDumpPath:             cmd.XXthreadDumpPath,
+		UseJvmgoGC:                 cmd.XXuseJvmgoGC,
+		VerboseGC:                  cmd.verboseGCFlag,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	jvm.SetHashCodeMode(cmd.XXhashCode)
+	if cmd.jdwpOption != "" {
+		options, err := jdwp.ParseOptions(cmd.jdwpOption)
+		if err == nil {
+			var agent *jdwp.Agent
+			if agent, err = jdwp.Start(jvm, options); err == nil {
+				defer agent.Close() //发送VMDeath事件
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	stopSignals := notifySignals(jvm) //收到SIGQUIT时转储线程，收到SIGUSR1时转储堆
+	defer stopSignals()
+	status := jvm.RunMain(cmd.class, cmd.args) //让解释器执行main方法
+	if profiler := jvm.Profiler(); profiler != nil {
+		writeProfile(profiler, cmd.XprofFile)
+	}
+	if cmd.XXstrTableStats {
+		jvm.WriteStringTableStatistics(os.Stdout)
+	}
+	return status
+}
 
-	if mainMethod != nil {
-		interpret(mainMethod, cmd.verboseInstFlag, cmd.args) //让解释器执行方法
+// writeProfile 把折叠栈写到文件中，把每个方法的耗时和指令直方图打印到标准错误
+func writeProfile(profiler *vm.Profiler, file string) {
+	profiler.Stop()
+	if file != "" {
+		f, err := os.Create(file)
+		if err == nil {
+			err = profiler.WriteCollapsed(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Collapsed stacks written to %s\n", file)
+		}
+	}
+	profiler.WriteReport(os.Stderr)
+}
+
+// printClassFile 以javap的格式打印class文件，参数可以是class文件的路径，也可以是classpath中的类名
+func printClassFile(cmd *Cmd) int {
+	var data []byte
+	var source string
+	if strings.HasSuffix(cmd.class, ".class") {
+		fileData, err := ioutil.ReadFile(cmd.class)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		data = fileData
+		source, _ = filepath.Abs(cmd.class)
 	} else {
-		fmt.Printf("Main method not found in class %s\n", cmd.class)
+		cp := classpath.Parse(cmd.XjreOption, cmd.cpOption)
+		className := strings.Replace(cmd.class, ".", "/", -1)
+		classData, entry, err := cp.ReadClass(className)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: class not found: %s\n", cmd.class)
+			return 1
+		}
+		data = classData
+		source = fmt.Sprintf("%s.class (in %s)", className, entry)
+	}
+
+	cf, err := classfile.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
 	}
+	javap.Print(os.Stdout, cf, source)
+	return 0
 }
@@ -0,0 +1,256 @@
+package native
+
+import (
+	"fmt"
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"reflect"
+	"strings"
+)
+
+/*
+用普通的Go函数实现本地方法，参数和返回值根据方法描述符自动转换，不需要自己计算局部变量表的索引
+
+	native.Bind("Foo", "scale", "(JLjava/lang/String;)D",
+		func(this *heap.Object, x int64, s string) (float64, error) { ... })
+
+Go函数的参数依次是：可选的*rtda.Frame，实例方法的this(*heap.Object)，然后是方法的参数
+Go函数可以返回方法的返回值(void方法没有)，最后还可以多返回一个error
+描述符中的类型和Go类型的对应关系：
+	Z bool   B int8   C uint16   S int16   I int32   J int64   F float32   D float64
+	Ljava/lang/String;  string或者*heap.Object(string参数是null时抛出NullPointerException，不调用Go函数)
+	其他引用类型         *heap.Object
+返回的error不为nil时本地方法抛出异常，用Throw()可以指定异常类，其他error抛出java.lang.RuntimeException
+函数的类型和描述符不一致时，Bind()在注册时就panic
+*/
+
+const jlStringDescriptor = "Ljava/lang/String;"
+
+var (
+	frameType  = reflect.TypeOf((*rtda.Frame)(nil))
+	objectType = reflect.TypeOf((*heap.Object)(nil))
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	stringType = reflect.TypeOf("")
+)
+
+// 基本类型的描述符对应的Go类型
+var primitiveGoTypes = map[string]reflect.Type{
+	"Z": reflect.TypeOf(false),
+	"B": reflect.TypeOf(int8(0)),
+	"C": reflect.TypeOf(uint16(0)),
+	"S": reflect.TypeOf(int16(0)),
+	"I": reflect.TypeOf(int32(0)),
+	"J": reflect.TypeOf(int64(0)),
+	"F": reflect.TypeOf(float32(0)),
+	"D": reflect.TypeOf(float64(0)),
+}
+
+// Exception 本地方法返回这个error时抛出指定的Java异常
+type Exception struct {
+	ClassName string // 比如java/lang/IllegalArgumentException，.和/都可以
+	Message   string
+}
+
+func (self *Exception) Error() string {
+	return strings.Replace(self.ClassName, "/", ".", -1) + ": " + self.Message
+}
+
+// Throw 返回一个使本地方法抛出className异常的error
+func Throw(className, message string) error {
+	return &Exception{ClassName: className, Message: message}
+}
+
+// Bind 把Go函数注册为实例方法的实现，函数在this之后接收方法的参数
+func Bind(className, methodName, methodDescriptor string, fn interface{}) {
+	Register(className, methodName, methodDescriptor, Wrap(methodDescriptor, false, fn))
+}
+
+// BindStatic 把Go函数注册为静态方法的实现
+func BindStatic(className, methodName, methodDescriptor string, fn interface{}) {
+	Register(className, methodName, methodDescriptor, Wrap(methodDescriptor, true, fn))
+}
+
+// Wrap 把Go函数转换成NativeMethod，可以用来给单个虚拟机注册本地方法(见vm.RegisterNative)
+func Wrap(methodDescriptor string, isStatic bool, fn interface{}) NativeMethod {
+	b := newBinding(methodDescriptor, isStatic, fn)
+	return b.invoke
+}
+
+type binding struct {
+	fn             reflect.Value
+	withFrame      bool // 第一个参数是*rtda.Frame
+	isStatic       bool
+	parameterTypes []string
+	returnType     string
+	returnsError   bool // 最后一个返回值是error
+}
+
+func newBinding(descriptor string, isStatic bool, fn interface{}) *binding {
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		panic(fmt.Sprintf("native: %s: want a func, got %T", descriptor, fn))
+	}
+	parameterTypes, returnType := heap.ParseMethodDescriptor(descriptor)
+	b := &binding{
+		fn:             reflect.ValueOf(fn),
+		isStatic:       isStatic,
+		parameterTypes: parameterTypes,
+		returnType:     returnType,
+	}
+
+	// 检查参数
+	in := 0
+	if fnType.NumIn() > 0 && fnType.In(0) == frameType {
+		b.withFrame = true
+		in++
+	}
+	if !isStatic {
+		if fnType.NumIn() <= in || fnType.In(in) != objectType {
+			b.mismatch(fnType, "missing this *heap.Object")
+		}
+		in++
+	}
+	if fnType.NumIn()-in != len(parameterTypes) {
+		b.mismatch(fnType, fmt.Sprintf("want %d parameters", len(parameterTypes)))
+	}
+	if fnType.IsVariadic() {
+		b.mismatch(fnType, "variadic func")
+	}
+	for i, t := range parameterTypes {
+		if !goTypeMatches(fnType.In(in+i), t) {
+			b.mismatch(fnType, fmt.Sprintf("parameter %d is %s", i, t))
+		}
+	}
+
+	// 检查返回值
+	out := fnType.NumOut()
+	if out > 0 && fnType.Out(out-1) == errorType {
+		b.returnsError = true
+		out--
+	}
+	if returnType == "V" {
+		if out != 0 {
+			b.mismatch(fnType, "void method")
+		}
+	} else if out != 1 || !goTypeMatches(fnType.Out(0), returnType) {
+		b.mismatch(fnType, "return type is "+returnType)
+	}
+	return b
+}
+
+func (self *binding) mismatch(fnType reflect.Type, reason string) {
+	desc := "(" + strings.Join(self.parameterTypes, "") + ")" + self.returnType
+	panic(fmt.Sprintf("native: %v does not match %s: %s", fnType, desc, reason))
+}
+
+func goTypeMatches(goType reflect.Type, descriptor string) bool {
+	if t, ok := primitiveGoTypes[descriptor]; ok {
+		return goType == t
+	}
+	if descriptor == jlStringDescriptor && goType == stringType {
+		return true
+	}
+	return goType == objectType
+}
+
+// invoke 从局部变量表中取出参数，调用Go函数，把返回值推入操作数栈
+func (self *binding) invoke(frame *rtda.Frame) {
+	fnType := self.fn.Type()
+	vars := frame.LocalVars()
+	in := make([]reflect.Value, 0, fnType.NumIn())
+	if self.withFrame {
+		in = append(in, reflect.ValueOf(frame))
+	}
+	index := uint(0)
+	if !self.isStatic {
+		in = append(in, reflect.ValueOf(vars.GetThis()))
+		index++
+	}
+	for _, t := range self.parameterTypes {
+		arg, ok := getArg(vars, index, t, fnType.In(len(in)))
+		if !ok { //string参数是null，不调用Go函数
+			base.ThrowException(frame, "java/lang/NullPointerException", "")
+			return
+		}
+		in = append(in, arg)
+		if t == "J" || t == "D" {
+			index += 2 // long和double占两个slot
+		} else {
+			index++
+		}
+	}
+
+	out := self.fn.Call(in)
+	if self.returnsError {
+		if err := out[len(out)-1]; !err.IsNil() {
+			throw(frame, err.Interface().(error))
+			return
+		}
+	}
+	if self.returnType != "V" {
+		pushResult(frame, self.returnType, out[0])
+	}
+}
+
+// getArg 取出一个参数并转换成Go类型，Go类型是string而参数是null时返回false
+func getArg(vars rtda.LocalVars, index uint, descriptor string, goType reflect.Type) (reflect.Value, bool) {
+	switch descriptor {
+	case "Z":
+		return reflect.ValueOf(vars.GetInt(index) != 0), true
+	case "B":
+		return reflect.ValueOf(int8(vars.GetInt(index))), true
+	case "C":
+		return reflect.ValueOf(uint16(vars.GetInt(index))), true
+	case "S":
+		return reflect.ValueOf(int16(vars.GetInt(index))), true
+	case "I":
+		return reflect.ValueOf(vars.GetInt(index)), true
+	case "J":
+		return reflect.ValueOf(vars.GetLong(index)), true
+	case "F":
+		return reflect.ValueOf(vars.GetFloat(index)), true
+	case "D":
+		return reflect.ValueOf(vars.GetDouble(index)), true
+	}
+	ref := vars.GetRef(index)
+	if goType == stringType {
+		if ref == nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(heap.GoString(ref)), true
+	}
+	return reflect.ValueOf(ref), true
+}
+
+func pushResult(frame *rtda.Frame, descriptor string, result reflect.Value) {
+	stack := frame.OperandStack()
+	switch descriptor {
+	case "Z":
+		stack.PushBoolean(result.Bool())
+	case "B", "C", "S", "I":
+		stack.PushInt(int32(result.Convert(primitiveGoTypes["I"]).Int()))
+	case "J":
+		stack.PushLong(result.Int())
+	case "F":
+		stack.PushFloat(float32(result.Float()))
+	case "D":
+		stack.PushDouble(result.Float())
+	default:
+		if result.Type() == stringType {
+			loader := frame.Thread().Runtime().Loader() //绑定的方法可能在没有类加载器的类上
+			stack.PushRef(heap.JString(loader, result.String()))
+		} else {
+			stack.PushRef(result.Interface().(*heap.Object))
+		}
+	}
+}
+
+func throw(frame *rtda.Frame, err error) {
+	if ex, ok := err.(*Exception); ok {
+		className := strings.Replace(ex.ClassName, ".", "/", -1)
+		base.ThrowException(frame, className, ex.Message)
+	} else {
+		base.ThrowException(frame, "java/lang/RuntimeException", err.Error())
+	}
+}
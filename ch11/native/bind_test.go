@@ -0,0 +1,136 @@
+package native_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda/heap"
+	"jvmgo/ch11/vm"
+)
+
+// newBinderVM Binder类的本地方法由测试用native.Wrap注册
+func newBinderVM(t *testing.T) *vm.VM {
+	binder := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Binder", "java/lang/Object")
+	init := binder.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.RETURN)
+	binder.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "nativeLength", "(Ljava/lang/String;)I")
+	binder.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "mix", "(JIDLjava/lang/String;)Ljava/lang/String;")
+	binder.AddMethod(asm.ACC_PUBLIC|asm.ACC_NATIVE, "scale", "(DJF)D")
+	binder.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC|asm.ACC_NATIVE, "fail", "(Z)V")
+
+	// static int length(String s) { try { return nativeLength(s); } catch (NullPointerException e) { return -1; } }
+	mb := binder.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "length", "(Ljava/lang/String;)I")
+	start, end, handler := mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+	mb.Mark(start)
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.MethodInsn(asm.INVOKESTATIC, "Binder", "nativeLength", "(Ljava/lang/String;)I")
+	mb.Mark(end)
+	mb.Insn(asm.IRETURN)
+	mb.Mark(handler)
+	mb.Insn(asm.POP)
+	mb.Insn(asm.ICONST_M1)
+	mb.Insn(asm.IRETURN)
+	mb.TryCatch(start, end, handler, "java/lang/NullPointerException")
+
+	return asmtest.NewVM(t, vm.Options{}, binder,
+		asmtest.Exception("java/lang/NullPointerException"),
+		asmtest.Exception("java/lang/IllegalArgumentException"),
+		asmtest.Exception("java/lang/RuntimeException"))
+}
+
+// 绑定到Go string的参数是null时抛出Java程序能捕获的NullPointerException，不调用Go函数
+func TestBindNullStringThrowsNPE(t *testing.T) {
+	jvm := newBinderVM(t)
+	called := false
+	jvm.RegisterNative("Binder", "nativeLength", "(Ljava/lang/String;)I",
+		native.Wrap("(Ljava/lang/String;)I", true, func(s string) int32 {
+			called = true
+			return int32(len(s))
+		}))
+
+	result, err := jvm.InvokeStatic("Binder", "length", "(Ljava/lang/String;)I", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != int32(-1) {
+		t.Errorf("length(null) = %v, want -1 from the NullPointerException handler", result)
+	}
+	if called {
+		t.Error("Go function was called with a null string")
+	}
+	if result, _ := jvm.InvokeStatic("Binder", "length", "(Ljava/lang/String;)I", "hello"); result != int32(5) {
+		t.Errorf("length(hello) = %v, want 5", result)
+	}
+}
+
+// long和double占两个slot，后面的参数的索引要跳过一个slot
+func TestBindTwoSlotArguments(t *testing.T) {
+	jvm := newBinderVM(t)
+	jvm.RegisterNative("Binder", "mix", "(JIDLjava/lang/String;)Ljava/lang/String;",
+		native.Wrap("(JIDLjava/lang/String;)Ljava/lang/String;", true, func(j int64, i int32, d float64, s string) string {
+			return fmt.Sprintf("%d %d %g %s", j, i, d, s)
+		}))
+	jvm.RegisterNative("Binder", "scale", "(DJF)D",
+		native.Wrap("(DJF)D", false, func(this *heap.Object, d float64, j int64, f float32) float64 {
+			if this == nil {
+				t.Error("this is null")
+			}
+			return d*float64(j) + float64(f)
+		}))
+
+	result, err := jvm.InvokeStatic("Binder", "mix", "(JIDLjava/lang/String;)Ljava/lang/String;",
+		int64(1)<<40, 7, 2.5, "end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := heap.GoString(result.(*heap.Object)); s != "1099511627776 7 2.5 end" {
+		t.Errorf("mix = %q", s)
+	}
+
+	obj, err := jvm.NewObject("Binder", "()V")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = jvm.Invoke(obj, "scale", "(DJF)D", 1.5, int64(1)<<33, float32(0.25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1.5*float64(int64(1)<<33) + 0.25; result != want {
+		t.Errorf("scale = %v, want %v", result, want)
+	}
+}
+
+// Go函数返回的error转换成Java异常，native.Throw()指定异常类，其他error是RuntimeException
+func TestBindErrorThrowsException(t *testing.T) {
+	jvm := newBinderVM(t)
+	jvm.RegisterNative("Binder", "fail", "(Z)V",
+		native.Wrap("(Z)V", true, func(typed bool) error {
+			if typed {
+				return native.Throw("java.lang.IllegalArgumentException", "bad argument")
+			}
+			return errors.New("plain error")
+		}))
+
+	for _, tt := range []struct {
+		typed bool
+		want  string
+	}{
+		{true, "java.lang.IllegalArgumentException: bad argument"},
+		{false, "java.lang.RuntimeException: plain error"},
+	} {
+		_, err := jvm.InvokeStatic("Binder", "fail", "(Z)V", tt.typed)
+		var ex *vm.JavaException
+		if !errors.As(err, &ex) {
+			t.Fatalf("fail(%v): err = %v, want a Java exception", tt.typed, err)
+		}
+		if ex.Error() != tt.want {
+			t.Errorf("fail(%v) threw %q, want %q", tt.typed, ex.Error(), tt.want)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package lang
 
 import (
+	"fmt"
+	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/native"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
+	"strings"
 )
 
 const jlClass = "java/lang/Class"
@@ -12,27 +15,206 @@ func init() {
 	native.Register(jlClass, "getPrimitiveClass", "(Ljava/lang/String;)Ljava/lang/Class;", getPrimitiveClass)
 	native.Register(jlClass, "getName0", "()Ljava/lang/String;", getName0)
 	native.Register(jlClass, "desiredAssertionStatus0", "(Ljava/lang/Class;)Z", desiredAssertionStatus0)
+	native.Register(jlClass, "forName0", "(Ljava/lang/String;ZLjava/lang/ClassLoader;Ljava/lang/Class;)Ljava/lang/Class;", forName0)
+	native.Register(jlClass, "newInstance", "()Ljava/lang/Object;", newInstance)
+	native.Register(jlClass, "isInterface", "()Z", isInterface)
+	native.Register(jlClass, "isPrimitive", "()Z", isPrimitive)
+	native.Register(jlClass, "isArray", "()Z", isArray)
+	native.Register(jlClass, "isInstance", "(Ljava/lang/Object;)Z", isInstance)
+	native.Register(jlClass, "isAssignableFrom", "(Ljava/lang/Class;)Z", isAssignableFrom)
+	native.Register(jlClass, "getSuperclass", "()Ljava/lang/Class;", getSuperclass)
+	native.Register(jlClass, "getComponentType", "()Ljava/lang/Class;", getComponentType)
+	native.Register(jlClass, "getModifiers", "()I", getModifiers)
 }
 
-//static native Class<?> getPrimitiveClass(String name);
+// static native Class<?> getPrimitiveClass(String name);
 func getPrimitiveClass(frame *rtda.Frame) {
 	nameObj := frame.LocalVars().GetRef(0) //从局部变量表中拿到类名，这是个Java字符串，需要转为Go字符串
 	name := heap.GoString(nameObj)
 	loader := frame.Method().Class().Loader()
-	class := loader.LoadClass(name).JClass() //记载基本类型的类
-	frame.OperandStack().PushRef(class)      //把类对象引用推入操作数栈顶
+	class := loader.FindLoadedClass(name) //基本类型的类在类加载器创建时就已经加载
+	if class == nil || !class.IsPrimitive() {
+		frame.OperandStack().PushRef(nil) //和HotSpot一样，不是基本类型时返回null
+		return
+	}
+	frame.OperandStack().PushRef(class.JClass()) //把类对象引用推入操作数栈顶
 }
 
-//private native String getName0()
+// private native String getName0()
 func getName0(frame *rtda.Frame) {
 	this := frame.LocalVars().GetThis()
 	class := this.Extra().(*heap.Class)
-	name := class.JavaName()                      //类名
-	nameObj := heap.JString(class.Loader(), name) //转换为JAVA字符串
-	frame.OperandStack().PushRef(nameObj)         //放入操作数栈中
+	name := class.JavaName()                              //类名
+	nameObj := heap.InternedJString(class.Loader(), name) //转换为JAVA字符串，和HotSpot一样放在字符串池中
+	frame.OperandStack().PushRef(nameObj)                 //放入操作数栈中
 }
 
 // private static native boolean desiredAssertionStatus0(Class<?> clazz);
 func desiredAssertionStatus0(frame *rtda.Frame) {
 	frame.OperandStack().PushBoolean(false)
 }
+
+// private static native Class<?> forName0(String name, boolean initialize, ClassLoader loader, Class<?> caller)
+// (Ljava/lang/String;ZLjava/lang/ClassLoader;Ljava/lang/Class;)Ljava/lang/Class;
+func forName0(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	jName := vars.GetRef(0)
+	initialize := vars.GetInt(1) == 1
+	if jName == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+
+	// 只有一个类加载器，loader和caller参数被忽略
+	name := heap.GoString(jName)
+	loader := frame.Method().Class().Loader()
+	var class *heap.Class
+	if !strings.Contains(name, "/") { //类名必须是java.lang.String或者[Ljava.lang.String;的形式
+		class = loader.FindClass(strings.Replace(name, ".", "/", -1))
+	}
+	if class == nil {
+		base.ThrowException(frame, "java/lang/ClassNotFoundException", name)
+		return
+	}
+
+	if initialize && !class.InitStarted() {
+		// 先执行<clinit>，然后重新执行forName0()
+		frame.RevertNextPC()
+		base.InitClass(frame.Thread(), class)
+		return
+	}
+	frame.OperandStack().PushRef(class.JClass())
+}
+
+// public T newInstance()
+// ()Ljava/lang/Object;
+func newInstance(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	class := this.Extra().(*heap.Class)
+	if class.Name() == jlClass {
+		base.ThrowException(frame, "java/lang/IllegalAccessException",
+			"Can not call newInstance() on the Class for java.lang.Class")
+		return
+	}
+	if class.IsInterface() || class.IsAbstract() || class.IsArray() || class.IsPrimitive() {
+		base.ThrowException(frame, "java/lang/InstantiationException", class.JavaName())
+		return
+	}
+	constructor := class.GetConstructor("()V")
+	if constructor == nil {
+		base.ThrowException(frame, "java/lang/InstantiationException", class.JavaName())
+		return
+	}
+
+	callerClass := frame.Thread().GetFrames()[1].Method().Class()
+	if !class.IsAccessibleTo(callerClass) || !constructor.IsAccessibleTo(callerClass) {
+		msg := fmt.Sprintf("Class %s can not access a member of class %s with modifiers \"%s\"",
+			callerClass.JavaName(), class.JavaName(), memberModifiers(constructor))
+		base.ThrowException(frame, "java/lang/IllegalAccessException", msg)
+		return
+	}
+
+	if !class.InitStarted() {
+		frame.RevertNextPC()
+		base.InitClass(frame.Thread(), class)
+		return
+	}
+
+	// 对象留在操作数栈上，构造函数返回之后由areturn指令返回
+	obj := class.NewObject()
+	stack := frame.OperandStack()
+	stack.PushRef(obj)
+	stack.PushRef(obj)
+	base.InvokeMethod(frame, constructor)
+}
+
+func memberModifiers(method *heap.Method) string {
+	switch {
+	case method.IsPublic():
+		return "public"
+	case method.IsProtected():
+		return "protected"
+	case method.IsPrivate():
+		return "private"
+	default:
+		return ""
+	}
+}
+
+// public native boolean isInterface();
+// ()Z
+func isInterface(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	frame.OperandStack().PushBoolean(class.IsInterface())
+}
+
+// public native boolean isPrimitive();
+// ()Z
+func isPrimitive(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	frame.OperandStack().PushBoolean(class.IsPrimitive())
+}
+
+// public native boolean isArray();
+// ()Z
+func isArray(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	frame.OperandStack().PushBoolean(class.IsArray())
+}
+
+// public native boolean isInstance(Object obj);
+// (Ljava/lang/Object;)Z
+func isInstance(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	class := vars.GetThis().Extra().(*heap.Class)
+	obj := vars.GetRef(1)
+	frame.OperandStack().PushBoolean(obj != nil && obj.IsInstanceOf(class))
+}
+
+// public native boolean isAssignableFrom(Class<?> cls);
+// (Ljava/lang/Class;)Z
+func isAssignableFrom(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	class := vars.GetThis().Extra().(*heap.Class)
+	jCls := vars.GetRef(1)
+	if jCls == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	cls := jCls.Extra().(*heap.Class)
+	frame.OperandStack().PushBoolean(class.IsAssignableFrom(cls))
+}
+
+// public native Class<? super T> getSuperclass();
+// ()Ljava/lang/Class;
+func getSuperclass(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	superClass := class.SuperClass()
+	if class.IsInterface() || superClass == nil { //接口和基本类型的超类为null
+		frame.OperandStack().PushRef(nil)
+		return
+	}
+	frame.OperandStack().PushRef(superClass.JClass())
+}
+
+// public native Class<?> getComponentType();
+// ()Ljava/lang/Class;
+func getComponentType(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	if !class.IsArray() {
+		frame.OperandStack().PushRef(nil)
+		return
+	}
+	frame.OperandStack().PushRef(class.ComponentClass().JClass())
+}
+
+// public native int getModifiers();
+// ()I
+func getModifiers(frame *rtda.Frame) {
+	class := frame.LocalVars().GetThis().Extra().(*heap.Class)
+	modifiers := class.AccessFlags() &^ heap.ACC_SUPER //ACC_SUPER不是Java语言的修饰符
+	if class.IsArray() || class.IsPrimitive() {
+		modifiers = heap.ACC_PUBLIC | heap.ACC_FINAL | heap.ACC_ABSTRACT
+	}
+	frame.OperandStack().PushInt(int32(modifiers))
+}
@@ -1,15 +1,18 @@
 package lang
 
 import (
+	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/native"
 	"jvmgo/ch11/rtda"
-	"unsafe"
 )
 
 func init() {
 	native.Register("java/lang/Object", "getClass", "()Ljava/lang/Class;", getClass)
 	native.Register("java/lang/Object", "hashCode", "()I", hashCode) //Object的HashCode方法，实现为本地方法
 	native.Register("java/lang/Object", "clone", "()Ljava/lang/Object;", clone)
+	native.Register("java/lang/Object", "wait", "(J)V", wait)
+	native.Register("java/lang/Object", "notify", "()V", notify)
+	native.Register("java/lang/Object", "notifyAll", "()V", notifyAll)
 }
 
 //public final native Class<?> getClass();
@@ -22,7 +25,7 @@ func getClass(frame *rtda.Frame) {
 //public native int hashCode()
 func hashCode(frame *rtda.Frame) {
 	this := frame.LocalVars().GetThis()
-	hash := int32(uintptr(unsafe.Pointer(this))) //把对象引用(Object结构体指针)转换成uintptr(类似于void*)类型，然后强转换成int32推入操作数栈顶
+	hash := this.IdentityHashCode(frame.Thread().HashState()) //哈希值懒生成并保存在对象头中，不再依赖对象的Go地址
 	frame.OperandStack().PushInt(hash)
 }
 func clone(frame *rtda.Frame) {
@@ -33,3 +36,47 @@ func clone(frame *rtda.Frame) {
 	}
 	frame.OperandStack().PushRef(this.Clone()) //调用object的克隆函数
 }
+
+// public final native void wait(long timeout) throws InterruptedException;
+// (J)V
+func wait(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	this := vars.GetThis()
+	timeout := vars.GetLong(1)
+	if timeout < 0 {
+		base.ThrowException(frame, "java/lang/IllegalArgumentException", "timeout value is negative")
+		return
+	}
+	thread := frame.Thread()
+	if !thread.HoldsLock(this) {
+		base.ThrowException(frame, "java/lang/IllegalMonitorStateException", "current thread is not owner")
+		return
+	}
+	if thread.IsInterrupted(true) { //已经被中断了，不再等待
+		base.ThrowException(frame, "java/lang/InterruptedException", "")
+		return
+	}
+	thread.Wait(this, timeout) //释放监视器并让出执行权，直到被notify、中断或者超时，然后重新进入监视器
+}
+
+// public final native void notify();
+// ()V
+func notify(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	if !frame.Thread().HoldsLock(this) {
+		base.ThrowException(frame, "java/lang/IllegalMonitorStateException", "current thread is not owner")
+		return
+	}
+	frame.Thread().Runtime().Notify(this)
+}
+
+// public final native void notifyAll();
+// ()V
+func notifyAll(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	if !frame.Thread().HoldsLock(this) {
+		base.ThrowException(frame, "java/lang/IllegalMonitorStateException", "current thread is not owner")
+		return
+	}
+	frame.Thread().Runtime().NotifyAll(this)
+}
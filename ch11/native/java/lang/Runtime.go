@@ -0,0 +1,53 @@
+package lang
+
+import (
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+)
+
+const jlRuntime = "java/lang/Runtime"
+
+func init() {
+	native.Register(jlRuntime, "freeMemory", "()J", freeMemory)
+	native.Register(jlRuntime, "totalMemory", "()J", totalMemory)
+	native.Register(jlRuntime, "maxMemory", "()J", maxMemory)
+	native.Register(jlRuntime, "gc", "()V", gc)
+	native.Register(jlRuntime, "runFinalization0", "()V", runFinalization0)
+}
+
+// public native long freeMemory();
+// ()J
+func freeMemory(frame *rtda.Frame) {
+	allocator := frame.Method().Class().Loader().Allocator()
+	frame.OperandStack().PushLong(allocator.FreeMemory())
+}
+
+// public native long totalMemory();
+// ()J
+func totalMemory(frame *rtda.Frame) {
+	allocator := frame.Method().Class().Loader().Allocator()
+	frame.OperandStack().PushLong(allocator.TotalMemory())
+}
+
+// public native long maxMemory();
+// ()J
+func maxMemory(frame *rtda.Frame) {
+	allocator := frame.Method().Class().Loader().Allocator()
+	frame.OperandStack().PushLong(allocator.MaxMemory())
+}
+
+// public native void gc();
+// ()V
+// 回收之后让出执行权，让ReferenceHandler线程把被清除的引用对象放到队列中
+func gc(frame *rtda.Frame) {
+	frame.Method().Class().Loader().Allocator().Collect()
+	frame.Thread().Yield()
+}
+
+// private static native void runFinalization0();
+// ()V
+// 等到Finalizer线程处理完队列中的对象
+func runFinalization0(frame *rtda.Frame) {
+	thread := frame.Thread()
+	thread.Runtime().RunFinalization(thread)
+}
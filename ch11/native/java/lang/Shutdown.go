@@ -0,0 +1,37 @@
+package lang
+
+import (
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+)
+
+const jlShutdown = "java/lang/Shutdown"
+
+/*
+System.exit()和Runtime.exit()都由Java代码实现：先在Shutdown.sequence()中运行关闭钩子，最后调用halt0()
+*/
+
+func init() {
+	native.Register(jlShutdown, "halt0", "(I)V", halt0)
+	native.Register(jlShutdown, "beforeHalt", "()V", beforeHalt)
+	native.Register(jlShutdown, "runAllFinalizers", "()V", runAllFinalizers)
+}
+
+// static native void halt0(int status);
+// (I)V
+func halt0(frame *rtda.Frame) {
+	status := frame.LocalVars().GetInt(0)
+	frame.Thread().Runtime().Halt(int(status)) //停止所有线程，进程以status退出
+}
+
+// static native void beforeHalt();
+// ()V
+func beforeHalt(frame *rtda.Frame) {
+	// do nothing
+}
+
+// private static native void runAllFinalizers();
+// ()V
+func runAllFinalizers(frame *rtda.Frame) {
+	// todo
+}
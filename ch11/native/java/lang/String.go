@@ -1,6 +1,7 @@
 package lang
 
 import (
+	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/native"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
@@ -10,6 +11,13 @@ const jlString = "java/lang/String"
 
 func init() {
 	native.Register(jlString, "intern", "()Ljava/lang/String;", intern)
+	// 内建函数，见heap/intrinsics.go
+	native.RegisterIntrinsic(jlString, "equals", "(Ljava/lang/Object;)Z", stringEquals)
+	native.RegisterIntrinsic(jlString, "compareTo", "(Ljava/lang/String;)I", compareTo)
+	native.RegisterIntrinsic(jlString, "indexOf", "(I)I", indexOfChar)
+	native.RegisterIntrinsic(jlString, "indexOf", "(II)I", indexOfChar)
+	native.RegisterIntrinsic(jlString, "indexOf", "(Ljava/lang/String;)I", indexOfString)
+	native.RegisterIntrinsic(jlString, "indexOf", "(Ljava/lang/String;I)I", indexOfString)
 }
 
 //public native String intern()
@@ -19,3 +27,129 @@ func intern(frame *rtda.Frame) {
 	interned := heap.InternString(this)
 	frame.OperandStack().PushRef(interned)
 }
+
+func stringValue(jStr *heap.Object) *heap.Object {
+	return jStr.GetRefVar("value", "[C")
+}
+
+// public boolean equals(Object anObject)
+// (Ljava/lang/Object;)Z
+func stringEquals(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	this := vars.GetThis()
+	other := vars.GetRef(1)
+	result := this == other
+	if !result && other != nil && other.Class() == this.Class() { //String是final的
+		result = heap.CharsEqual(stringValue(this), stringValue(other))
+	}
+	frame.OperandStack().PushBoolean(result)
+}
+
+// public int compareTo(String anotherString)
+// (Ljava/lang/String;)I
+func compareTo(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	this := vars.GetThis()
+	other := vars.GetRef(1)
+	if other == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	v1, v2 := stringValue(this), stringValue(other)
+	len1, len2 := v1.ArrayLength(), v2.ArrayLength()
+	for i := int32(0); i < len1 && i < len2; i++ {
+		if c1, c2 := v1.CharAt(i), v2.CharAt(i); c1 != c2 {
+			frame.OperandStack().PushInt(int32(c1) - int32(c2))
+			return
+		}
+	}
+	frame.OperandStack().PushInt(len1 - len2)
+}
+
+// public int indexOf(int ch) 和 public int indexOf(int ch, int fromIndex)
+// (I)I 和 (II)I
+func indexOfChar(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	value := stringValue(vars.GetThis())
+	ch := vars.GetInt(1)
+	fromIndex := int32(0)
+	if frame.Method().Descriptor() == "(II)I" {
+		fromIndex = vars.GetInt(2)
+	}
+	frame.OperandStack().PushInt(indexOfCodePoint(value, ch, fromIndex))
+}
+
+// indexOfCodePoint 增补字符按代理对查找，和String.indexOf(int, int)一样
+func indexOfCodePoint(value *heap.Object, ch, fromIndex int32) int32 {
+	length := value.ArrayLength()
+	if fromIndex < 0 {
+		fromIndex = 0
+	}
+	if ch >= 0 && ch < 0x10000 {
+		for i := fromIndex; i < length; i++ {
+			if int32(value.CharAt(i)) == ch {
+				return i
+			}
+		}
+		return -1
+	}
+	if ch < 0x10000 || ch > 0x10ffff { //不是合法的码点
+		return -1
+	}
+	hi := uint16((ch-0x10000)>>10 + 0xd800)
+	lo := uint16((ch-0x10000)&0x3ff + 0xdc00)
+	for i := fromIndex; i < length-1; i++ {
+		if value.CharAt(i) == hi && value.CharAt(i+1) == lo {
+			return i
+		}
+	}
+	return -1
+}
+
+// public int indexOf(String str) 和 public int indexOf(String str, int fromIndex)
+// (Ljava/lang/String;)I 和 (Ljava/lang/String;I)I
+func indexOfString(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	value := stringValue(vars.GetThis())
+	str := vars.GetRef(1)
+	if str == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	fromIndex := int32(0)
+	if frame.Method().Descriptor() == "(Ljava/lang/String;I)I" {
+		fromIndex = vars.GetInt(2)
+	}
+	frame.OperandStack().PushInt(indexOfChars(value, stringValue(str), fromIndex))
+}
+
+// indexOfChars 和String.indexOf(char[], int, int, char[], int, int, int)一样
+func indexOfChars(source, target *heap.Object, fromIndex int32) int32 {
+	sourceCount, targetCount := source.ArrayLength(), target.ArrayLength()
+	if fromIndex >= sourceCount {
+		if targetCount == 0 {
+			return sourceCount
+		}
+		return -1
+	}
+	if fromIndex < 0 {
+		fromIndex = 0
+	}
+	if targetCount == 0 {
+		return fromIndex
+	}
+	first := target.CharAt(0)
+	for i := fromIndex; i <= sourceCount-targetCount; i++ {
+		if source.CharAt(i) != first {
+			continue
+		}
+		j := int32(1)
+		for j < targetCount && source.CharAt(i+j) == target.CharAt(j) {
+			j++
+		}
+		if j == targetCount {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,15 +1,21 @@
 package lang
 
 import (
+	"fmt"
+	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/native"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
+	"time"
 )
 
 const jlSystem = "java/lang/System"
 
 func init() {
 	native.Register(jlSystem, "arraycopy", "(Ljava/lang/Object;ILjava/lang/Object;II)V", arraycopy)
+	native.Register(jlSystem, "identityHashCode", "(Ljava/lang/Object;)I", identityHashCode)
+	native.Register(jlSystem, "currentTimeMillis", "()J", currentTimeMillis)
+	native.Register(jlSystem, "nanoTime", "()J", nanoTime)
 }
 
 // public static native void arraycopy(Object src, int srcPos, Object dest, int destPos, int length)
@@ -31,32 +37,103 @@ func arraycopy(frame *rtda.Frame) {
 
 	//源数组和目的数组都不能为nil
 	if src == nil || dest == nil {
-		panic("java.lang.NullPointerException")
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
 	}
-	if !checkArrayCopy(src, dest) {
-		panic("java.lang.ArrayStoreException")
+	if msg := checkArrayCopy(src, dest); msg != "" {
+		base.ThrowException(frame, "java/lang/ArrayStoreException", msg)
+		return
 	}
-	if srcPos < 0 || destPos < 0 || length < 0 ||
-		srcPos+length > src.ArrayLength() ||
-		destPos+length > dest.ArrayLength() {
-		panic("java.lang.IndexOutOfBoundsException")
+	if msg := checkArrayCopyRange(src, dest, srcPos, destPos, length); msg != "" {
+		base.ThrowException(frame, "java/lang/ArrayIndexOutOfBoundsException", msg)
+		return
 	}
 
-	heap.ArrayCopy(src, dest, srcPos, destPos, length)
-
+	srcComponent := src.Class().ComponentClass()
+	destComponent := dest.Class().ComponentClass()
+	if srcComponent.IsPrimitive() || src == dest || destComponent.IsAssignableFrom(srcComponent) {
+		heap.ArrayCopy(src, dest, srcPos, destPos, length) //和memmove一样，源和目标重叠时也是正确的
+		return
+	}
+	//比如Object[]复制到String[]，逐个检查元素的类型，遇到不能存放的元素时，之前的元素已经复制了
+	srcRefs := src.Refs()[srcPos : srcPos+length]
+	destRefs := dest.Refs()[destPos : destPos+length]
+	for i, ref := range srcRefs {
+		if ref != nil && !ref.IsInstanceOf(destComponent) {
+			base.ThrowException(frame, "java/lang/ArrayStoreException", fmt.Sprintf(
+				"arraycopy: element type mismatch: can not cast one of the elements of %s[] to the type of the destination array, %s",
+				srcComponent.JavaName(), destComponent.JavaName()))
+			return
+		}
+		destRefs[i] = ref
+	}
 }
 
-//源数组和目标数组的合法性检查
-func checkArrayCopy(src, dest *heap.Object) bool {
+// checkArrayCopy 源数组和目标数组的合法性检查，不合法时返回ArrayStoreException的消息
+func checkArrayCopy(src, dest *heap.Object) string {
 	srcClass := src.Class()
 	destClass := dest.Class()
 
-	if !srcClass.IsArray() || !destClass.IsArray() {
-		return false
+	if !srcClass.IsArray() {
+		return "arraycopy: source type " + srcClass.JavaName() + " is not an array"
+	}
+	if !destClass.IsArray() {
+		return "arraycopy: destination type " + destClass.JavaName() + " is not an array"
 	}
 	if srcClass.ComponentClass().IsPrimitive() ||
 		destClass.ComponentClass().IsPrimitive() {
-		return srcClass == destClass //相同的基本类型才可以转换
+		if srcClass != destClass { //相同的基本类型才可以转换
+			return "arraycopy: type mismatch: can not copy " + srcClass.ComponentClass().JavaName() +
+				"[] into " + destClass.ComponentClass().JavaName() + "[]"
+		}
 	}
-	return true
+	return ""
+}
+
+// checkArrayCopyRange 下标的检查，用int64计算，srcPos+length不会溢出，越界时返回ArrayIndexOutOfBoundsException的消息
+func checkArrayCopyRange(src, dest *heap.Object, srcPos, destPos, length int32) string {
+	switch {
+	case srcPos < 0:
+		return fmt.Sprintf("arraycopy: source index %d out of bounds for length %d", srcPos, src.ArrayLength())
+	case destPos < 0:
+		return fmt.Sprintf("arraycopy: destination index %d out of bounds for length %d", destPos, dest.ArrayLength())
+	case length < 0:
+		return fmt.Sprintf("arraycopy: length %d is negative", length)
+	case int64(srcPos)+int64(length) > int64(src.ArrayLength()):
+		return fmt.Sprintf("arraycopy: last source index %d out of bounds for length %d",
+			int64(srcPos)+int64(length), src.ArrayLength())
+	case int64(destPos)+int64(length) > int64(dest.ArrayLength()):
+		return fmt.Sprintf("arraycopy: last destination index %d out of bounds for length %d",
+			int64(destPos)+int64(length), dest.ArrayLength())
+	}
+	return ""
+}
+
+// public static native int identityHashCode(Object x)
+// (Ljava/lang/Object;)I
+func identityHashCode(frame *rtda.Frame) {
+	ref := frame.LocalVars().GetRef(0)
+	hash := int32(0) //null的哈希值为0
+	if ref != nil {
+		hash = ref.IdentityHashCode(frame.Thread().HashState())
+	}
+	frame.OperandStack().PushInt(hash)
+}
+
+// public static native long currentTimeMillis();
+// ()J
+func currentTimeMillis(frame *rtda.Frame) {
+	millis := time.Now().UnixNano() / int64(time.Millisecond)
+	frame.OperandStack().PushLong(millis)
+}
+
+// nanoTime的起点，和HotSpot一样是任意的，只能用来计算时间间隔
+var nanoTimeOrigin = time.Now()
+
+// public static native long nanoTime();
+// ()J
+// time.Since()使用单调时钟，不受系统时间调整的影响
+func nanoTime(frame *rtda.Frame) {
+	nanos := int64(time.Since(nanoTimeOrigin))
+	frame.OperandStack().PushLong(nanos)
 }
@@ -0,0 +1,112 @@
+package lang
+
+import (
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+)
+
+const jlThread = "java/lang/Thread"
+
+func init() {
+	native.Register(jlThread, "currentThread", "()Ljava/lang/Thread;", currentThread)
+	native.Register(jlThread, "start0", "()V", start0)
+	native.Register(jlThread, "isAlive", "()Z", isAlive)
+	native.Register(jlThread, "setPriority0", "(I)V", setPriority0)
+	native.Register(jlThread, "holdsLock", "(Ljava/lang/Object;)Z", holdsLock)
+	native.Register(jlThread, "yield", "()V", yield)
+	native.Register(jlThread, "sleep", "(J)V", sleep)
+	native.Register(jlThread, "interrupt0", "()V", interrupt0)
+	native.Register(jlThread, "isInterrupted", "(Z)Z", isInterrupted)
+}
+
+// public static native Thread currentThread();
+// ()Ljava/lang/Thread;
+func currentThread(frame *rtda.Frame) {
+	loader := frame.Method().Class().Loader()
+	jThread := frame.Thread().EnsureJThread(loader)
+	frame.OperandStack().PushRef(jThread)
+}
+
+// private native void start0();
+// ()V
+// 创建新线程执行run()方法，新线程在当前线程等待或者结束时才开始执行
+func start0(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	newThread := frame.Thread().Runtime().NewThread()
+	newThread.SetJThread(this)
+	this.SetExtra(newThread) //Thread对象的extra指向对应的rtda.Thread
+	this.SetIntVar("threadStatus", "I", rtda.THREAD_STATUS_RUNNABLE)
+
+	runMethod := heap.LookupMethodInClass(this.Class(), "run", "()V")
+	runFrame := newThread.NewFrame(runMethod)
+	runFrame.LocalVars().SetRef(0, this)
+	newThread.PushFrame(runFrame)
+	newThread.Start()
+}
+
+// public final native boolean isAlive();
+// ()Z
+func isAlive(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	thread, ok := this.Extra().(*rtda.Thread)
+	frame.OperandStack().PushBoolean(ok && thread.IsAlive())
+}
+
+// private native void setPriority0(int newPriority);
+// (I)V
+func setPriority0(frame *rtda.Frame) {
+	// 线程不会被抢占，优先级没有意义
+}
+
+// public static native boolean holdsLock(Object obj);
+// (Ljava/lang/Object;)Z
+func holdsLock(frame *rtda.Frame) {
+	obj := frame.LocalVars().GetRef(0)
+	if obj == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	frame.OperandStack().PushBoolean(frame.Thread().HoldsLock(obj))
+}
+
+// public static native void yield();
+// ()V
+func yield(frame *rtda.Frame) {
+	frame.Thread().Yield()
+}
+
+// public static native void sleep(long millis) throws InterruptedException;
+// (J)V
+func sleep(frame *rtda.Frame) {
+	millis := frame.LocalVars().GetLong(0)
+	if millis < 0 {
+		base.ThrowException(frame, "java/lang/IllegalArgumentException", "timeout value is negative")
+		return
+	}
+	if frame.Thread().IsInterrupted(true) { //已经被中断了，不再睡眠
+		base.ThrowException(frame, "java/lang/InterruptedException", "sleep interrupted")
+		return
+	}
+	frame.Thread().Sleep(millis)
+}
+
+// private native void interrupt0();
+// ()V
+func interrupt0(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	if thread, ok := this.Extra().(*rtda.Thread); ok {
+		thread.Interrupt()
+	}
+}
+
+// private native boolean isInterrupted(boolean ClearInterrupted);
+// (Z)Z
+func isInterrupted(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	this := vars.GetThis()
+	clear := vars.GetInt(1) == 1
+	thread, ok := this.Extra().(*rtda.Thread)
+	frame.OperandStack().PushBoolean(ok && thread.IsInterrupted(clear))
+}
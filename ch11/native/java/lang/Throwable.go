@@ -2,11 +2,14 @@ package lang
 
 import (
 	"fmt"
+	"jvmgo/ch11/instructions/base"
 	"jvmgo/ch11/native"
 	"jvmgo/ch11/rtda"
 	"jvmgo/ch11/rtda/heap"
 )
 
+const jlThrowable = "java/lang/Throwable"
+
 type StackTraceElement struct {
 	fileName   string //给出类所在的文件名
 	className  string //给出声明方法的类名
@@ -15,7 +18,9 @@ type StackTraceElement struct {
 }
 
 func init() {
-	native.Register("java/lang/Throwable", "fillInStackTrace", "(I)Ljava/lang/Throwable;", fillInStackTrace)
+	native.Register(jlThrowable, "fillInStackTrace", "(I)Ljava/lang/Throwable;", fillInStackTrace)
+	native.Register(jlThrowable, "getStackTraceDepth", "()I", getStackTraceDepth)
+	native.Register(jlThrowable, "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", getStackTraceElement)
 }
 
 //private native Throwable fillInStackTrace(int dummy);
@@ -26,12 +31,53 @@ func fillInStackTrace(frame *rtda.Frame) {
 	this.SetExtra(stes)
 }
 
+// native int getStackTraceDepth();
+// ()I
+func getStackTraceDepth(frame *rtda.Frame) {
+	this := frame.LocalVars().GetThis()
+	stes := getStackTraceElements(this)
+	frame.OperandStack().PushInt(int32(len(stes)))
+}
+
+// native StackTraceElement getStackTraceElement(int index);
+// (I)Ljava/lang/StackTraceElement;
+func getStackTraceElement(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	this := vars.GetThis()
+	index := vars.GetInt(1)
+
+	stes := getStackTraceElements(this)
+	if index < 0 || int(index) >= len(stes) {
+		base.ThrowException(frame, "java/lang/IndexOutOfBoundsException", "")
+		return
+	}
+	loader := frame.Method().Class().Loader()
+	jSte := stes[index].toJObject(loader)
+	frame.OperandStack().PushRef(jSte)
+}
+
+// fillInStackTrace()保存在extra中的栈信息，没有调用过时为空
+func getStackTraceElements(tObj *heap.Object) []*StackTraceElement {
+	if stes, ok := tObj.Extra().([]*StackTraceElement); ok {
+		return stes
+	}
+	return nil
+}
+
+// 和HotSpot的-XX:MaxJavaStackTraceDepth一样，栈很深时(比如StackOverflowError)只保留栈顶的1024帧
+const maxStackTraceDepth = 1024
+
 func createStackTraceElements(tObj *heap.Object, thread *rtda.Thread) []*StackTraceElement {
 	skip := distanceToObject(tObj.Class()) + 2 //掉过fillInStackTrace(int)和fillInStackTrace()
 	frames := thread.GetFrames()[skip:]
-	stes := make([]*StackTraceElement, len(frames))
-	for i, frame := range frames {
-		stes[i] = createStackTraceElement(frame)
+	stes := make([]*StackTraceElement, 0, len(frames))
+	for _, frame := range frames {
+		if len(stes) == maxStackTraceDepth {
+			break
+		}
+		if !frame.Method().IsShim() { //跳过虚拟机内部的shim帧
+			stes = append(stes, createStackTraceElement(frame))
+		}
 	}
 	return stes
 }
@@ -56,7 +102,32 @@ func createStackTraceElement(frame *rtda.Frame) *StackTraceElement {
 	}
 }
 
+// toJObject 把Go这边的栈信息转换成java.lang.StackTraceElement对象
+func (self *StackTraceElement) toJObject(loader *heap.ClassLoader) *heap.Object {
+	jSte := loader.LoadClass("java/lang/StackTraceElement").NewObject()
+	jSte.SetRefVar("declaringClass", "Ljava/lang/String;", heap.InternedJString(loader, self.className))
+	jSte.SetRefVar("methodName", "Ljava/lang/String;", heap.InternedJString(loader, self.methodName))
+	if self.fileName != "" { //没有源文件信息时fileName为null
+		jSte.SetRefVar("fileName", "Ljava/lang/String;", heap.InternedJString(loader, self.fileName))
+	}
+	jSte.SetIntVar("lineNumber", "I", int32(self.lineNumber))
+	return jSte
+}
+
+// String 和StackTraceElement.toString()的格式保持一致
 func (self *StackTraceElement) String() string {
-	return fmt.Sprintf("%s.%s(%s:%d)",
-		self.className, self.methodName, self.fileName, self.lineNumber)
+	return fmt.Sprintf("%s.%s(%s)", self.className, self.methodName, self.location())
+}
+
+func (self *StackTraceElement) location() string {
+	switch {
+	case self.lineNumber == -2:
+		return "Native Method"
+	case self.fileName != "" && self.lineNumber >= 0:
+		return fmt.Sprintf("%s:%d", self.fileName, self.lineNumber)
+	case self.fileName != "":
+		return self.fileName
+	default:
+		return "Unknown Source"
+	}
 }
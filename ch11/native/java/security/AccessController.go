@@ -0,0 +1,17 @@
+package security
+
+import (
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+)
+
+func init() {
+	native.Register("java/security/AccessController", "getStackAccessControlContext",
+		"()Ljava/security/AccessControlContext;", getStackAccessControlContext)
+}
+
+// private static native AccessControlContext getStackAccessControlContext();
+// ()Ljava/security/AccessControlContext;
+func getStackAccessControlContext(frame *rtda.Frame) {
+	frame.OperandStack().PushRef(nil) //没有安全管理器，栈上也没有特权帧
+}
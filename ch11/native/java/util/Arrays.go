@@ -0,0 +1,260 @@
+package util
+
+import (
+	"fmt"
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"math"
+)
+
+const juArrays = "java/util/Arrays"
+
+// Arrays中基本类型数组的fill()、equals()和hashCode()是内建函数，见heap/intrinsics.go
+func init() {
+	for _, t := range []string{"Z", "B", "C", "S", "I", "J", "F", "D"} {
+		native.RegisterIntrinsic(juArrays, "fill", "(["+t+t+")V", fill)
+		native.RegisterIntrinsic(juArrays, "fill", "(["+t+"II"+t+")V", fillRange)
+		native.RegisterIntrinsic(juArrays, "equals", "(["+t+"["+t+")Z", equals)
+		native.RegisterIntrinsic(juArrays, "hashCode", "(["+t+")I", hashCode)
+	}
+	native.RegisterIntrinsic(juArrays, "fill", "([Ljava/lang/Object;Ljava/lang/Object;)V", fill)
+	native.RegisterIntrinsic(juArrays, "fill", "([Ljava/lang/Object;IILjava/lang/Object;)V", fillRange)
+}
+
+// public static void fill(int[] a, int val)
+// ([II)V，其他基本类型和Object[]一样
+func fill(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	arr := vars.GetRef(0)
+	if arr == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	fillArray(frame, arr, 0, arr.ArrayLength(), 1)
+}
+
+// public static void fill(int[] a, int fromIndex, int toIndex, int val)
+// ([IIII)V，其他基本类型和Object[]一样
+func fillRange(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	arr := vars.GetRef(0)
+	from := vars.GetInt(1)
+	to := vars.GetInt(2)
+	if arr == nil {
+		base.ThrowException(frame, "java/lang/NullPointerException", "")
+		return
+	}
+	if rangeCheck(frame, arr.ArrayLength(), from, to) {
+		fillArray(frame, arr, from, to, 3)
+	}
+}
+
+// rangeCheck 和Arrays.rangeCheck()一样，下标不合法时抛出异常并返回false
+func rangeCheck(frame *rtda.Frame, length, from, to int32) bool {
+	switch {
+	case from > to:
+		base.ThrowException(frame, "java/lang/IllegalArgumentException",
+			fmt.Sprintf("fromIndex(%d) > toIndex(%d)", from, to))
+	case from < 0:
+		base.ThrowException(frame, "java/lang/ArrayIndexOutOfBoundsException",
+			fmt.Sprintf("Array index out of range: %d", from))
+	case to > length:
+		base.ThrowException(frame, "java/lang/ArrayIndexOutOfBoundsException",
+			fmt.Sprintf("Array index out of range: %d", to))
+	default:
+		return true
+	}
+	return false
+}
+
+// fillArray 把数组中[from, to)的元素设置为局部变量表中index处的值
+func fillArray(frame *rtda.Frame, arr *heap.Object, from, to int32, index uint) {
+	vars := frame.LocalVars()
+	switch arr.Class().Name() {
+	case "[Z", "[B":
+		val := int8(vars.GetInt(index))
+		bytes := arr.Bytes()[from:to]
+		for i := range bytes {
+			bytes[i] = val
+		}
+	case "[C":
+		arr.FillChars(from, to, uint16(vars.GetInt(index)))
+	case "[S":
+		val := int16(vars.GetInt(index))
+		shorts := arr.Shorts()[from:to]
+		for i := range shorts {
+			shorts[i] = val
+		}
+	case "[I":
+		val := vars.GetInt(index)
+		ints := arr.Ints()[from:to]
+		for i := range ints {
+			ints[i] = val
+		}
+	case "[J":
+		val := vars.GetLong(index)
+		longs := arr.Longs()[from:to]
+		for i := range longs {
+			longs[i] = val
+		}
+	case "[F":
+		val := vars.GetFloat(index)
+		floats := arr.Floats()[from:to]
+		for i := range floats {
+			floats[i] = val
+		}
+	case "[D":
+		val := vars.GetDouble(index)
+		doubles := arr.Doubles()[from:to]
+		for i := range doubles {
+			doubles[i] = val
+		}
+	default:
+		val := vars.GetRef(index)
+		if from < to && val != nil && !val.IsInstanceOf(arr.Class().ComponentClass()) {
+			base.ThrowException(frame, "java/lang/ArrayStoreException", val.Class().JavaName())
+			return
+		}
+		refs := arr.Refs()[from:to]
+		for i := range refs {
+			refs[i] = val
+		}
+	}
+}
+
+// public static boolean equals(int[] a, int[] a2)
+// ([I[I)Z，其他基本类型一样，float和double按floatToIntBits()和doubleToLongBits()比较
+func equals(frame *rtda.Frame) {
+	vars := frame.LocalVars()
+	a := vars.GetRef(0)
+	a2 := vars.GetRef(1)
+	frame.OperandStack().PushBoolean(arraysEqual(a, a2))
+}
+
+func arraysEqual(a, a2 *heap.Object) bool {
+	if a == a2 {
+		return true
+	}
+	if a == nil || a2 == nil || a.ArrayLength() != a2.ArrayLength() {
+		return false
+	}
+	switch a.Class().Name() {
+	case "[Z", "[B":
+		x, y := a.Bytes(), a2.Bytes()
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+	case "[C":
+		return heap.CharsEqual(a, a2)
+	case "[S":
+		x, y := a.Shorts(), a2.Shorts()
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+	case "[I":
+		x, y := a.Ints(), a2.Ints()
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+	case "[J":
+		x, y := a.Longs(), a2.Longs()
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+	case "[F":
+		x, y := a.Floats(), a2.Floats()
+		for i := range x {
+			if floatToIntBits(x[i]) != floatToIntBits(y[i]) {
+				return false
+			}
+		}
+	case "[D":
+		x, y := a.Doubles(), a2.Doubles()
+		for i := range x {
+			if doubleToLongBits(x[i]) != doubleToLongBits(y[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// public static int hashCode(int[] a)
+// ([I)I，其他基本类型一样，结果和Java实现完全相同
+func hashCode(frame *rtda.Frame) {
+	arr := frame.LocalVars().GetRef(0)
+	frame.OperandStack().PushInt(arrayHashCode(arr))
+}
+
+func arrayHashCode(arr *heap.Object) int32 {
+	if arr == nil {
+		return 0
+	}
+	result := int32(1)
+	switch arr.Class().Name() {
+	case "[Z":
+		for _, e := range arr.Bytes() {
+			if e != 0 {
+				result = 31*result + 1231
+			} else {
+				result = 31*result + 1237
+			}
+		}
+	case "[B":
+		for _, e := range arr.Bytes() {
+			result = 31*result + int32(e)
+		}
+	case "[C":
+		for i := int32(0); i < arr.ArrayLength(); i++ {
+			result = 31*result + int32(arr.CharAt(i))
+		}
+	case "[S":
+		for _, e := range arr.Shorts() {
+			result = 31*result + int32(e)
+		}
+	case "[I":
+		for _, e := range arr.Ints() {
+			result = 31*result + e
+		}
+	case "[J":
+		for _, e := range arr.Longs() {
+			result = 31*result + int32(e^int64(uint64(e)>>32))
+		}
+	case "[F":
+		for _, e := range arr.Floats() {
+			result = 31*result + int32(floatToIntBits(e))
+		}
+	case "[D":
+		for _, e := range arr.Doubles() {
+			bits := doubleToLongBits(e)
+			result = 31*result + int32(bits^bits>>32)
+		}
+	}
+	return result
+}
+
+// floatToIntBits 和Float.floatToIntBits()一样，所有的NaN都是0x7fc00000
+func floatToIntBits(f float32) uint32 {
+	if f != f {
+		return 0x7fc00000
+	}
+	return math.Float32bits(f)
+}
+
+// doubleToLongBits 和Double.doubleToLongBits()一样，所有的NaN都是0x7ff8000000000000
+func doubleToLongBits(d float64) uint64 {
+	if d != d {
+		return 0x7ff8000000000000
+	}
+	return math.Float64bits(d)
+}
@@ -2,32 +2,39 @@ package native
 
 import (
 	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
 )
 
 // NativeMethod 本地方法定义为一个函数，参数是Frame结构体指针
 type NativeMethod func(frame *rtda.Frame)
 
-//key为string，value为NativeMethod()本地方法
-var registry = map[string]NativeMethod{}
-
-func emptyNativeMethod(
-	frame *rtda.Frame) {
-	// do nothing
+type nativeEntry struct {
+	className        string
+	methodName       string
+	methodDescriptor string
+	method           NativeMethod
 }
 
-// Register 注册方法
+/*
+内置的本地方法，由各个包的init()函数注册，之后不再修改
+每个虚拟机创建时通过Install()复制一份到自己的本地方法表中，虚拟机之间互不影响
+*/
+var builtins []nativeEntry
+
+// Register 注册内置的本地方法
 func Register(className, methodName, methodDescriptor string, method NativeMethod) {
-	key := className + "~" + methodName + "~" + methodDescriptor //类名，方法名和方法描述符唯一性地确定一个方法，作为注册表的key，value为其对应的方法
-	registry[key] = method
+	builtins = append(builtins, nativeEntry{className, methodName, methodDescriptor, method})
 }
 
-func FindNativeMethod(className, methodName, methodDescriptor string) NativeMethod {
-	key := className + "~" + methodName + "~" + methodDescriptor
-	if method, ok := registry[key]; ok {
-		return method
-	}
-	if methodDescriptor == "()V" && methodName == "registerNatives" {
-		return emptyNativeMethod
+// RegisterIntrinsic 用Go实现替换JDK中用Java实现的方法，见heap/intrinsics.go
+func RegisterIntrinsic(className, methodName, methodDescriptor string, method NativeMethod) {
+	heap.RegisterIntrinsic(className, methodName, methodDescriptor)
+	Register(className, methodName, methodDescriptor, method)
+}
+
+// Install 把所有内置的本地方法注册到虚拟机的本地方法表中
+func Install(runtime *rtda.Runtime) {
+	for _, entry := range builtins {
+		runtime.RegisterNative(entry.className, entry.methodName, entry.methodDescriptor, entry.method)
 	}
-	return nil
 }
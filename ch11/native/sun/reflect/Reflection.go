@@ -0,0 +1,31 @@
+package reflect
+
+import (
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+)
+
+func init() {
+	native.Register("sun/reflect/Reflection", "getCallerClass", "()Ljava/lang/Class;", getCallerClass)
+	native.Register("sun/reflect/Reflection", "getClassAccessFlags", "(Ljava/lang/Class;)I", getClassAccessFlags)
+}
+
+// public static native Class<?> getCallerClass();
+// ()Ljava/lang/Class;
+func getCallerClass(frame *rtda.Frame) {
+	// top0 is sun/reflect/Reflection
+	// top1 is the caller of getCallerClass()
+	// top2 is the caller of method
+	callerFrame := frame.Thread().GetFrames()[2]
+	callerClass := callerFrame.Method().Class().JClass()
+	frame.OperandStack().PushRef(callerClass)
+}
+
+// public static native int getClassAccessFlags(Class<?> c);
+// (Ljava/lang/Class;)I
+func getClassAccessFlags(frame *rtda.Frame) {
+	jClass := frame.LocalVars().GetRef(0)
+	class := jClass.Extra().(*heap.Class)
+	frame.OperandStack().PushInt(int32(class.AccessFlags()))
+}
@@ -0,0 +1,60 @@
+package rtda
+
+import "jvmgo/ch11/rtda/heap"
+
+/*
+Finalizer线程
+回收时发现的需要终结的对象放到队列中，由虚拟机内部的守护线程Finalizer依次调用它们的finalize()
+Finalizer线程的栈底是shim帧(见heap.ShimFinalizerMethod())，它循环调用runFinalizer：
+队列不为空时推入下一个对象的finalize()的帧，否则线程等待，直到回收时又发现了需要终结的对象
+*/
+
+// enqueueFinalizers 由collect调用，第一次发现需要终结的对象时启动Finalizer线程
+func (self *Runtime) enqueueFinalizers(finalizees []*heap.Object) {
+	if len(finalizees) == 0 {
+		return
+	}
+	self.finalizees = append(self.finalizees, finalizees...)
+	if self.finalizer == nil {
+		self.finalizer = self.NewThread()
+		self.finalizer.name = "Finalizer"
+		self.finalizer.daemon = true
+		self.finalizer.PushFrame(self.finalizer.NewFrame(heap.ShimFinalizerMethod()))
+		self.finalizer.Start()
+	} else if self.finalizerIdle {
+		self.finalizerIdle = false
+		self.finalizer.wake()
+	}
+}
+
+// runFinalizer Finalizer线程的shim帧调用的本地方法
+func runFinalizer(frame *Frame) {
+	thread := frame.Thread()
+	self := thread.runtime
+	if len(self.finalizees) == 0 {
+		for _, t := range self.finalizationWaiters {
+			t.wake()
+		}
+		self.finalizationWaiters = nil
+		self.finalizerIdle = true
+		thread.block(nil, 0)
+		return
+	}
+
+	obj := self.finalizees[0]
+	self.finalizees[0] = nil
+	self.finalizees = self.finalizees[1:]
+	method := heap.LookupMethodInClass(obj.Class(), "finalize", "()V")
+	finalizeFrame := thread.NewFrame(method)
+	finalizeFrame.LocalVars().SetRef(0, obj)
+	thread.PushFrame(finalizeFrame)
+}
+
+// RunFinalization 对应Runtime.runFinalization()，thread等待，直到队列中的对象都终结了
+func (self *Runtime) RunFinalization(thread *Thread) {
+	if self.finalizer == nil || self.finalizerIdle {
+		return
+	}
+	self.finalizationWaiters = append(self.finalizationWaiters, thread)
+	thread.block(nil, 0)
+}
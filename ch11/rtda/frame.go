@@ -7,8 +7,11 @@ type Frame struct {
 	localVars    LocalVars     //保存局部变量表，每一栈帧都有一个局部变量表
 	operandStack *OperandStack //保存操作数栈指针，每一个栈帧都有一个操作数栈
 	thread       *Thread
-	method       *heap.Method //为了通过frame变量拿到当前类的运行时常量池，需要添加method字段
-	nextPC       int          //the next instruction after the call
+	method       *heap.Method   //为了通过frame变量拿到当前类的运行时常量池，需要添加method字段
+	nextPC       int            //the next instruction after the call
+	monitors     []*heap.Object //monitorenter进入并且还没有退出的监视器，见monitor.go
+	syncLock     *heap.Object   //同步方法持有的监视器
+	entered      bool           //已经执行过EnterMethod()
 }
 
 /*func NewFrame(thread *Thread, maxLocals, maxStack uint) *Frame {
@@ -54,3 +57,8 @@ func (self *Frame) SetNextPC(nextPC int) {
 func (self *Frame) RevertNextPC() {
 	self.nextPC = self.thread.pc
 }
+
+// size 帧在栈中占用的字节数，用于计算-Xss
+func (self *Frame) size() uint {
+	return (self.method.MaxLocals()+self.method.MaxStack())*slotBytes + frameOverhead
+}
@@ -0,0 +1,77 @@
+package rtda
+
+import (
+	"fmt"
+	"jvmgo/ch11/rtda/heap"
+	"time"
+)
+
+/*
+垃圾回收
+默认情况下对象由Go的垃圾回收器回收，这里只是从根出发计算存活对象的大小，供-Xmx记账使用
+EnableJvmgoGC()之后对象分配在虚拟机自己的堆中，同样从根出发标记，没有标记的对象被回收，见heap/arena.go
+两种情况下，referent不可达的引用对象都会被清除，然后和HotSpot一样放到Reference.pending链表中，
+由Reference的<clinit>启动的ReferenceHandler线程放到各自的ReferenceQueue中
+不可达的可终结对象交给Finalizer线程，见finalizer.go
+*/
+
+// EnableJvmgoGC 使用虚拟机自己的垃圾回收器，对应-XX:+UseJvmgoGC，必须在执行Java代码之前调用
+func (self *Runtime) EnableJvmgoGC() {
+	self.loader.Allocator().EnableArena()
+}
+
+// SetVerboseGC 每次回收时在标准输出打印一行日志，对应-verbose:gc
+func (self *Runtime) SetVerboseGC(verbose bool) {
+	self.verboseGC = verbose
+}
+
+// AddRootSource 添加虚拟机之外的根，比如调试器持有的对象，每次回收时调用source
+func (self *Runtime) AddRootSource(source func() []*heap.Object) {
+	self.rootSources = append(self.rootSources, source)
+}
+
+// collect 由heap.Allocator在堆空间不够用或者调用System.gc()时调用，返回存活对象的大小
+func (self *Runtime) collect(cause string) int64 {
+	start := time.Now()
+	allocator := self.loader.Allocator()
+	before, total := allocator.UsedMemory(), allocator.TotalMemory()
+
+	var roots []*heap.Object
+	for _, root := range self.Roots() {
+		roots = append(roots, root.Object)
+	}
+	for _, source := range self.rootSources {
+		roots = append(roots, source()...)
+	}
+	roots = append(roots, self.finalizees...)
+	live, cleared, finalizees := allocator.MarkSweep(roots)
+	self.enqueueReferences(cleared)
+	self.enqueueFinalizers(finalizees)
+
+	if self.verboseGC {
+		kind := "GC"
+		if cause == "System.gc()" {
+			kind = "Full GC"
+		}
+		fmt.Fprintf(self.stdout, "[%s (%s)  %dK->%dK(%dK), %.7f secs]\n",
+			kind, cause, before>>10, live>>10, total>>10, time.Since(start).Seconds())
+	}
+	return live
+}
+
+// enqueueReferences 把被清除的引用对象通过discovered字段加到Reference.pending链表中，唤醒在Reference.lock上等待的ReferenceHandler线程
+func (self *Runtime) enqueueReferences(cleared []*heap.Object) {
+	refClass := self.loader.FindLoadedClass("java/lang/ref/Reference")
+	if len(cleared) == 0 || refClass == nil {
+		return
+	}
+	pending := refClass.GetRefVar("pending", "Ljava/lang/ref/Reference;")
+	for _, ref := range cleared {
+		ref.SetRefVar("discovered", "Ljava/lang/ref/Reference;", pending)
+		pending = ref
+	}
+	refClass.SetRefVar("pending", "Ljava/lang/ref/Reference;", pending)
+	if lock := refClass.GetRefVar("lock", "Ljava/lang/ref/Reference$Lock;"); lock != nil {
+		self.NotifyAll(lock)
+	}
+}
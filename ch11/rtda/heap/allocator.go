@@ -0,0 +1,279 @@
+package heap
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+Allocator 记录Java堆的大小和已经使用的字节数，对应-Xms和-Xmx
+默认情况下对象分配在Go的堆上，由Go的垃圾回收器回收，这里只做记账：
+每次分配对象或者数组时加上对象的大小，超过当前堆的大小时，在下一个安全点通过collector从根出发重新计算存活对象的大小
+如果仍然放不下，堆增长到最多max，再放不下就抛出OutOfMemoryError
+-XX:+UseJvmgoGC时对象分配在arena中，collector同时回收不可达的对象，见arena.go
+
+对象的大小按照HotSpot在64位平台上开启压缩指针时的布局估算：对象头12字节(数组16字节)，引用4字节，按8字节对齐
+*/
+
+const (
+	objectHeaderSize = 12
+	arrayHeaderSize  = 16
+	refSize          = 4
+	slotSize         = 4             //一个Slot对应4字节，long和double占两个
+	defaultHeapSize  = 16 << 20      //没有指定-Xms时堆的初始大小
+	oomReserve       = 1 << 20       //抛出OutOfMemoryError时用来创建异常对象的空间
+	unlimitedHeap    = math.MaxInt64 //没有指定-Xmx时Runtime.maxMemory()的返回值
+	maxArrayLength   = math.MaxInt32 //数组的最大长度
+	heapGrowthFactor = 2             //堆不够用时增长的倍数
+	heapMinFreeRatio = 0.3           //回收之后空闲空间少于这个比例时增长堆，避免频繁回收
+)
+
+// ErrOutOfMemory 分配对象时堆空间不足，解释器把它转换成java.lang.OutOfMemoryError
+var ErrOutOfMemory = errors.New("java.lang.OutOfMemoryError: Java heap space")
+
+type Allocator struct {
+	max       int64 //-Xmx，0表示不限制
+	total     int64 //当前堆的大小，对应Runtime.totalMemory()
+	used      int64 //上次回收时存活对象的大小加上之后分配的字节数
+	throwing  bool  //刚刚抛出了OutOfMemoryError，允许使用oomReserve
+	collector func(cause string) int64
+	arena     *arena //为nil表示对象分配在Go的堆上
+	gcPending bool   //堆中放不下了，等到安全点回收
+	clearSoft bool   //这次回收清除软引用
+	//登记过的可终结对象，见finalizer.go
+	finalizers []*Object
+}
+
+func newAllocator() *Allocator {
+	return &Allocator{total: defaultHeapSize}
+}
+
+// SetLimits 设置堆的初始大小和最大大小，0表示使用默认值
+func (self *Allocator) SetLimits(initial, max int64) {
+	if initial <= 0 {
+		initial = defaultHeapSize
+	}
+	if max > 0 && initial > max {
+		initial = max
+	}
+	self.max = max
+	if self.total < initial {
+		self.total = initial
+	}
+	if max > 0 && self.total > max {
+		self.total = max
+	}
+}
+
+/*
+SetCollector 设置回收函数，它计算存活对象的大小，启用arena时还要调用MarkSweep()回收不可达的对象
+cause是回收的原因："Allocation Failure"或者"System.gc()"，没有设置时认为所有对象都存活
+*/
+func (self *Allocator) SetCollector(collector func(cause string) int64) {
+	self.collector = collector
+}
+
+// EnableArena 之后的对象分配在arena中，由MarkSweep()回收，必须在执行Java代码之前调用
+func (self *Allocator) EnableArena() {
+	if self.arena == nil {
+		self.arena = newArena()
+	}
+}
+
+func (self *Allocator) ArenaEnabled() bool {
+	return self.arena != nil
+}
+
+/*
+MarkSweep 标记从roots可达的对象，启用arena时回收其余的对象
+返回存活对象的大小、被清除的引用对象以及需要调用finalize()的对象
+roots必须包括Java程序能访问到的所有对象，Go代码中持有的对象如果不在roots中会被回收
+
+和HotSpot一样，软引用和弱引用在终结之前清除，虚引用在终结之后清除：
+需要终结的对象和它们引用的对象在这次回收中仍然存活，虚引用的referent如果在这里被重新标记了就不清除
+*/
+func (self *Allocator) MarkSweep(roots []*Object) (live int64, cleared, finalizees []*Object) {
+	marked, discovered, live := mark(roots, self.clearSoft)
+	cleared = clearReferences(discovered, self.clearSoft, false)
+	if finalizees = self.takeFinalizees(); len(finalizees) > 0 {
+		marked2, discovered2, live2 := mark(finalizees, self.clearSoft)
+		marked = append(marked, marked2...)
+		discovered = append(discovered, discovered2...)
+		live += live2
+	}
+	cleared = append(cleared, clearReferences(discovered, self.clearSoft, true)...)
+	if self.arena != nil {
+		self.arena.sweep()
+	}
+	for _, obj := range marked {
+		obj.marked = false
+	}
+	return live, cleared, finalizees
+}
+
+// UsedMemory 已经使用的字节数，对应totalMemory()-freeMemory()
+func (self *Allocator) UsedMemory() int64 {
+	return self.used
+}
+
+func (self *Allocator) TotalMemory() int64 {
+	return self.total
+}
+
+func (self *Allocator) FreeMemory() int64 {
+	if self.used >= self.total {
+		return 0
+	}
+	return self.total - self.used
+}
+
+// MaxMemory 没有限制时和HotSpot一样返回Long.MAX_VALUE
+func (self *Allocator) MaxMemory() int64 {
+	if self.max == 0 {
+		return unlimitedHeap
+	}
+	return self.max
+}
+
+// Collect 重新计算存活对象的大小，对应System.gc()
+func (self *Allocator) Collect() {
+	self.collect("System.gc()")
+}
+
+func (self *Allocator) collect(cause string) {
+	if self.collector != nil {
+		self.used = self.collector(cause)
+	}
+}
+
+/*
+alloc 分配size字节，size本身就超过了堆的最大大小时panic(ErrOutOfMemory)
+空间不够时不在这里回收，只记下来由Safepoint()处理：
+本地方法手里可能拿着刚创建、还没有放到栈或者字段中的对象，这时回收会清除它们的引用对象，甚至把它们交给finalize()
+*/
+func (self *Allocator) alloc(size int64) {
+	if self.used+size <= self.total {
+		self.used += size
+		return
+	}
+	limit := self.MaxMemory()
+	if self.throwing && self.used+size-oomReserve <= limit {
+		self.used += size //正在创建OutOfMemoryError，暂时不回收
+		return
+	}
+	if size > limit {
+		self.throwing = true
+		panic(ErrOutOfMemory)
+	}
+	self.used += size
+	self.gcPending = true
+}
+
+/*
+Safepoint 解释器在执行每条指令之前调用，这时所有对象都在根中，可以回收
+回收之后仍然超过堆的最大大小时返回ErrOutOfMemory
+*/
+func (self *Allocator) Safepoint() error {
+	if !self.gcPending {
+		return nil
+	}
+	self.gcPending = false
+	if self.throwing && self.used-oomReserve <= self.MaxMemory() {
+		return nil
+	}
+	if !self.reclaim(0) {
+		self.throwing = true
+		return ErrOutOfMemory
+	}
+	self.throwing = false
+	return nil
+}
+
+// reclaim 回收之后仍然放不下size字节时，清除所有软引用再回收一次，还是放不下返回false
+func (self *Allocator) reclaim(size int64) bool {
+	self.collect("Allocation Failure")
+	if self.grow(size) {
+		return true
+	}
+	self.clearSoft = true
+	self.collect("Allocation Failure")
+	self.clearSoft = false
+	return self.grow(size)
+}
+
+// grow 回收之后，如果放不下size字节或者空闲空间太少，堆增长到最多max，超过max时返回false
+func (self *Allocator) grow(size int64) bool {
+	limit := self.MaxMemory()
+	needed := self.used + size
+	if needed > limit {
+		return false
+	}
+	if needed > self.total || float64(self.total-needed) < float64(self.total)*heapMinFreeRatio {
+		total := self.total * heapGrowthFactor
+		if total < needed {
+			total = needed
+		}
+		if total > limit {
+			total = limit
+		}
+		self.total = total
+	}
+	return true
+}
+
+// place 创建对象，alloc已经记过账了，普通对象的data为nil，数组的fields为空
+func (self *Allocator) place(class *Class, fields Slots, data interface{}) *Object {
+	var obj *Object
+	if self.arena != nil {
+		obj = self.arena.take()
+	} else {
+		obj = &Object{}
+	}
+	obj.class = class
+	obj.fields = fields
+	obj.data = data
+	return obj
+}
+
+// arraySize 长度为count的数组的大小，count超出范围时panic(ErrOutOfMemory)
+func arraySize(className string, count uint) int64 {
+	if count > maxArrayLength {
+		panic(ErrOutOfMemory)
+	}
+	elemSize := int64(refSize)
+	switch className {
+	case "[Z", "[B":
+		elemSize = 1
+	case "[C", "[S":
+		elemSize = 2
+	case "[I", "[F":
+		elemSize = 4
+	case "[J", "[D":
+		elemSize = 8
+	}
+	return align8(arrayHeaderSize + elemSize*int64(count))
+}
+
+func instanceSize(class *Class) int64 {
+	return align8(objectHeaderSize + slotSize*int64(class.instanceSlotCount))
+}
+
+func align8(size int64) int64 {
+	return (size + 7) &^ 7
+}
+
+// ShallowSize 对象本身的大小，不包括它引用的对象
+func (self *Object) ShallowSize() int64 {
+	if self.IsLatin1() {
+		return arraySize("[B", uint(self.ArrayLength()))
+	}
+	if self.class.IsArray() {
+		return arraySize(self.class.name, uint(self.ArrayLength()))
+	}
+	return instanceSize(self.class)
+}
+
+// Allocator 返回虚拟机的堆
+func (self *ClassLoader) Allocator() *Allocator {
+	return self.allocator
+}
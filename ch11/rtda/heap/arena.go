@@ -0,0 +1,90 @@
+package heap
+
+/*
+arena 是-XX:+UseJvmgoGC时的Java堆：对象头(Object结构体)按块分配，由虚拟机自己回收
+回收用的是标记-清除：从根出发标记所有可达的对象，然后把arena中没有被标记的对象放回空闲链表
+被回收的对象清空之后，它的数组或者实例变量不再被引用，由Go的垃圾回收器释放
+
+根必须是精确的，只有Java程序能看到的地方才算：线程的局部变量表和操作数栈、类的静态变量、字符串池等等
+所以回收不能在分配对象的时候进行(这时本地方法手里可能拿着还没有放到任何地方的对象)，
+而是推迟到解释器执行下一条指令之前，见Allocator.Safepoint()
+*/
+
+const arenaChunkSize = 4096 //每块的对象个数
+
+type arena struct {
+	chunks [][]Object
+	next   int       //最后一块中下一个没有用过的位置
+	free   []*Object //被回收的对象
+}
+
+func newArena() *arena {
+	return &arena{next: arenaChunkSize}
+}
+
+// take 取出一个空的对象，优先复用被回收的对象
+func (self *arena) take() *Object {
+	if n := len(self.free); n > 0 {
+		obj := self.free[n-1]
+		self.free = self.free[:n-1]
+		return obj
+	}
+	if self.next == arenaChunkSize {
+		self.chunks = append(self.chunks, make([]Object, arenaChunkSize))
+		self.next = 0
+	}
+	chunk := self.chunks[len(self.chunks)-1]
+	obj := &chunk[self.next]
+	self.next++
+	return obj
+}
+
+// sweep 回收arena中所有没有标记的对象
+func (self *arena) sweep() {
+	for i, chunk := range self.chunks {
+		if i == len(self.chunks)-1 {
+			chunk = chunk[:self.next]
+		}
+		for j := range chunk {
+			if obj := &chunk[j]; !obj.marked && obj.class != nil {
+				*obj = Object{}
+				self.free = append(self.free, obj)
+			}
+		}
+	}
+}
+
+/*
+mark 从roots出发标记所有可达的对象，返回它们的大小之和
+返回的marked用来在清除之后去掉标记，其中包括不在arena中的对象(启用arena之前创建的对象)
+discovered是标记时跳过了referent字段的引用对象，见reference.go
+*/
+func mark(roots []*Object, clearSoft bool) (marked, discovered []*Object, live int64) {
+	pending := make([]*Object, 0, len(roots))
+	visit := func(obj *Object) {
+		if !obj.marked {
+			obj.marked = true
+			pending = append(pending, obj)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	for len(pending) > 0 {
+		obj := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		marked = append(marked, obj)
+		live += obj.ShallowSize()
+		if slotId, ok := obj.weakReferent(clearSoft); ok {
+			discovered = append(discovered, obj)
+			for i, ref := range obj.fields.refs {
+				if uint(i) != slotId && ref != nil {
+					visit(ref)
+				}
+			}
+		} else {
+			obj.References(visit)
+		}
+	}
+	return marked, discovered, live
+}
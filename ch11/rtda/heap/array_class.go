@@ -8,25 +8,26 @@ func (self *Class) NewArray(count uint) *Object {
 	if !self.IsArray() {
 		panic("Not array class: " + self.name)
 	}
+	self.loader.allocator.alloc(arraySize(self.name, count)) //先记账，堆不够时不会真的分配
 	switch self.Name() {
 	case "[Z":
-		return &Object{self, make([]int8, count), nil} //Boolean类型数组?
+		return self.loader.allocator.place(self, Slots{}, make([]int8, count)) //Boolean类型数组?
 	case "[B":
-		return &Object{self, make([]int8, count), nil} //int8[]数组来表示Bytes数组
+		return self.loader.allocator.place(self, Slots{}, make([]int8, count)) //int8[]数组来表示Bytes数组
 	case "[C":
-		return &Object{self, make([]uint16, count), nil} //Char[]字符
+		return self.loader.allocator.place(self, Slots{}, make([]uint16, count)) //Char[]字符
 	case "[S":
-		return &Object{self, make([]int16, count), nil} //Short数组
+		return self.loader.allocator.place(self, Slots{}, make([]int16, count)) //Short数组
 	case "[I":
-		return &Object{self, make([]int32, count), nil} //int 数组
+		return self.loader.allocator.place(self, Slots{}, make([]int32, count)) //int 数组
 	case "[J":
-		return &Object{self, make([]int64, count), nil} //long数组
+		return self.loader.allocator.place(self, Slots{}, make([]int64, count)) //long数组
 	case "[F":
-		return &Object{self, make([]float32, count), nil} //float数组
+		return self.loader.allocator.place(self, Slots{}, make([]float32, count)) //float数组
 	case "[D":
-		return &Object{self, make([]float64, count), nil} //double数组
+		return self.loader.allocator.place(self, Slots{}, make([]float64, count)) //double数组
 	default:
-		return &Object{self, make([]*Object, count), nil} //对象数组
+		return self.loader.allocator.place(self, Slots{}, make([]*Object, count)) //对象数组
 	}
 }
 
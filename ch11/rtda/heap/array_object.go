@@ -12,7 +12,12 @@ func (self *Object) Ints() []int32 {
 func (self *Object) Longs() []int64 {
 	return self.data.([]int64)
 }
+
+// Chars 返回char[]的所有元素，紧凑存储的数组先膨胀成UTF-16，只读取元素时用CharAt()
 func (self *Object) Chars() []uint16 {
+	if bytes, ok := self.data.(latin1); ok {
+		self.inflate(bytes)
+	}
 	return self.data.([]uint16)
 }
 func (self *Object) Floats() []float32 {
@@ -37,6 +42,8 @@ func (self *Object) ArrayLength() int32 {
 		return int32(len(self.data.([]int64)))
 	case []uint16:
 		return int32(len(self.data.([]uint16)))
+	case latin1:
+		return int32(len(self.data.(latin1)))
 	case []float32:
 		return int32(len(self.data.([]float32)))
 	case []float64:
@@ -68,8 +75,18 @@ func ArrayCopy(src, dst *Object, srcPos, dstPos, length int32) {
 		copy(_dst, _src)
 	case []uint16:
 		_src := src.data.([]uint16)[srcPos : srcPos+length]
-		_dst := dst.data.([]uint16)[dstPos : dstPos+length]
+		_dst := dst.Chars()[dstPos : dstPos+length]
 		copy(_dst, _src)
+	case latin1:
+		_src := src.data.(latin1)[srcPos : srcPos+length]
+		if bytes, ok := dst.data.(latin1); ok {
+			copy(bytes[dstPos:dstPos+length], _src)
+		} else {
+			_dst := dst.data.([]uint16)[dstPos : dstPos+length]
+			for i, b := range _src {
+				_dst[i] = uint16(b)
+			}
+		}
 	case []float32:
 		_src := src.data.([]float32)[srcPos : srcPos+length]
 		_dst := dst.data.([]float32)[dstPos : dstPos+length]
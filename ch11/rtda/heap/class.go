@@ -16,12 +16,17 @@ type Class struct {
 	loader            *ClassLoader
 	superClass        *Class   //真正的超类，不是超类名了
 	interfaces        []*Class //所实现的接口集合
-	instanceSlotCount uint     //实例变量占据的空间大小
-	staticSlotCount   uint     //类变量占据的空间大小
+	instanceSlotCount uint     //实例变量占据的空间大小，long和double占两个slot
+	instanceNumCount  uint     //基本类型实例变量的个数，见slots.go
+	instanceRefCount  uint     //引用类型实例变量的个数
+	staticNumCount    uint     //基本类型类变量的个数
+	staticRefCount    uint     //引用类型类变量的个数
 	staticVars        Slots    //存放静态变量
 	initStarted       bool     //类是否已经初始化
 	jClass            *Object  //java.lang.Class实例，类也是对象
 	sourceFile        string
+	refKind           uint8 //java.lang.ref.Reference子类的种类，见reference.go
+	finalizable       bool  //覆盖了Object.finalize()，见finalizer.go
 }
 
 /*
@@ -44,7 +49,7 @@ func getSourceFile(cf *classfile.ClassFile) string {
 	if sfAttr := cf.SourceFileAttribute(); sfAttr != nil {
 		return sfAttr.FileName()
 	}
-	return "Unknown" // todo
+	return "" //没有SourceFile属性，对应Java里的null
 }
 
 func (self *Class) isJlObject() bool {
@@ -90,6 +95,9 @@ func (self *Class) IsEnum() bool {
 }
 
 //getter
+func (self *Class) AccessFlags() uint16 {
+	return self.accessFlags
+}
 func (self *Class) ConstantPool() *ConstantPool {
 	return self.constantPool
 }
@@ -109,6 +117,9 @@ func (self *Class) Loader() *ClassLoader {
 	return self.loader
 }
 
+func (self *Class) Interfaces() []*Class {
+	return self.interfaces
+}
 func (self *Class) SuperClass() *Class {
 	return self.superClass
 }
@@ -116,6 +127,11 @@ func (self *Class) StaticVars() Slots {
 	return self.staticVars
 }
 
+// IsAccessibleTo 类other是否可以访问这个类
+func (self *Class) IsAccessibleTo(other *Class) bool {
+	return self.isAccessibleTo(other)
+}
+
 func (self *Class) isAccessibleTo(other *Class) bool {
 	return self.IsPublic() || self.GetPackageName() == other.GetPackageName() //要么类是公有的，要么两个类属于同个包下，才有访问权限
 }
@@ -136,6 +152,11 @@ func (self *Class) GetMainMethod() *Method {
 	return self.getStaticMethod("main", "([Ljava/lang/String;)V")
 }
 
+// GetStaticMethod 在当前类中查找静态方法，找不到返回nil
+func (self *Class) GetStaticMethod(name, descriptor string) *Method {
+	return self.getStaticMethod(name, descriptor)
+}
+
 func (self *Class) getStaticMethod(name, descriptor string) *Method {
 	for _, method := range self.methods {
 		if method.IsStatic() &&
@@ -209,6 +230,21 @@ func (self *Class) GetInstanceMethod(name, descriptor string) *Method {
 	return self.getMethod(name, descriptor, false)
 }
 
+// GetConstructor 查找当前类自己声明的构造函数，找不到返回nil
+func (self *Class) GetConstructor(descriptor string) *Method {
+	for _, method := range self.methods {
+		if method.name == "<init>" && method.descriptor == descriptor {
+			return method
+		}
+	}
+	return nil
+}
+
+// GetInstanceField 按名字和描述符查找实例字段(包括继承的字段)，找不到返回nil
+func (self *Class) GetInstanceField(name, descriptor string) *Field {
+	return self.getField(name, descriptor, false)
+}
+
 func (self *Class) GetRefVar(fieldName, fieldDescriptor string) *Object {
 	field := self.getField(fieldName, fieldDescriptor, true)
 	return self.staticVars.GetRef(field.slotId)
@@ -73,6 +73,11 @@ func (self *Class) isAssignableFrom(other *Class) bool {
 //	}
 //}
 
+// IsAssignableFrom 对应Class.isAssignableFrom()
+func (self *Class) IsAssignableFrom(other *Class) bool {
+	return self.isAssignableFrom(other)
+}
+
 //判断S是否为T的子类，实际上也就是判断T是否为S的直接或间接超类
 func (self *Class) IsSubClassOf(other *Class) bool {
 	for c := self.superClass; c != nil; c = c.superClass { //一直往祖先上找
@@ -1,13 +1,22 @@
 package heap
 
 import "fmt"
+import "strings"
 import "jvmgo/ch11/classfile"
 import "jvmgo/ch11/classpath"
 
+/*
+ClassLoader 除了已经加载的类，还保存着字符串池等属于整个虚拟机的状态
+每个虚拟机有自己的类加载器，同一个进程中的多个虚拟机互不影响
+*/
 type ClassLoader struct {
 	cp          *classpath.Classpath
 	verboseFlag bool
 	classMap    map[string]*Class // loaded classes
+	stringTable *StringTable      // 字符串池，见string_table.go
+	hashCodes   *hashCodes
+	loadHooks   []*func(class *Class) //类加载完成后调用，JDWP代理和-Xtrace用它们得到类加载事件
+	allocator   *Allocator            //Java堆的记账，见allocator.go
 }
 
 func NewClassLoader(cp *classpath.Classpath, verboseFlag bool) *ClassLoader {
@@ -15,6 +24,9 @@ func NewClassLoader(cp *classpath.Classpath, verboseFlag bool) *ClassLoader {
 		cp:          cp,
 		verboseFlag: verboseFlag,
 		classMap:    make(map[string]*Class),
+		stringTable: newStringTable(),
+		hashCodes:   newHashCodes(),
+		allocator:   newAllocator(),
 	}
 	loader.loadBasicClasses()
 	loader.loadPrimitiveClasses()
@@ -66,9 +78,87 @@ func (self *ClassLoader) LoadClass(name string) *Class {
 		class.jClass = jlClassClass.NewObject()
 		class.jClass.extra = class
 	}
+	if name[0] != '[' {
+		for _, hook := range self.loadHooks {
+			(*hook)(class)
+		}
+	}
 	return class
 }
 
+// AddLoadHook 添加类加载完成后的回调，返回的函数用来删除这个回调
+func (self *ClassLoader) AddLoadHook(hook func(class *Class)) (remove func()) {
+	h := &hook
+	self.loadHooks = append(self.loadHooks, h)
+	return func() {
+		for i, x := range self.loadHooks {
+			if x == h {
+				//不修改原来的数组，回调可以在执行时删除自己
+				self.loadHooks = append(self.loadHooks[:i:i], self.loadHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// LoadedClasses 返回所有已经加载的类，包括数组类和基本类型的类
+func (self *ClassLoader) LoadedClasses() []*Class {
+	classes := make([]*Class, 0, len(self.classMap))
+	for _, class := range self.classMap {
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// FindLoadedClass 返回已经加载的类，类还没有加载时返回nil(不会触发加载)
+func (self *ClassLoader) FindLoadedClass(name string) *Class {
+	return self.classMap[name]
+}
+
+/*
+FindClass 按Class.forName()的规则加载类，找不到类时返回nil而不是panic
+基本类型不能通过名字找到，数组类的元素类型也必须存在
+*/
+func (self *ClassLoader) FindClass(name string) *Class {
+	if name == "" {
+		return nil
+	}
+	if name[0] == '[' {
+		if !self.isValidArrayClassName(name) {
+			return nil
+		}
+		return self.LoadClass(name)
+	}
+	if _, ok := primitiveTypes[name]; ok {
+		return nil
+	}
+	if class, ok := self.classMap[name]; ok {
+		return class
+	}
+	if _, _, err := self.cp.ReadClass(name); err != nil {
+		return nil
+	}
+	return self.LoadClass(name)
+}
+
+// 数组类名就是数组的描述符，最多255维
+func (self *ClassLoader) isValidArrayClassName(name string) bool {
+	dimensions := strings.LastIndex(name, "[") + 1
+	if dimensions > 255 {
+		return false
+	}
+	descriptor := name[dimensions:]
+	switch {
+	case len(descriptor) == 1:
+		return descriptor != "V" && strings.Contains("ZBSIJCFD", descriptor)
+	case len(descriptor) > 2 && descriptor[0] == 'L' && descriptor[len(descriptor)-1] == ';':
+		elementName := descriptor[1 : len(descriptor)-1]
+		return elementName[0] != '[' && self.FindClass(elementName) != nil
+	default:
+		return false
+	}
+}
+
 func (self *ClassLoader) loadArrayClass(name string) *Class {
 	class := &Class{
 		accessFlags: ACC_PUBLIC,
@@ -111,6 +201,7 @@ func (self *ClassLoader) defineClass(data []byte) *Class {
 	class.loader = self
 	resolveSuperClass(class)
 	resolveInterfaces(class)
+	class.refKind = referenceKind(class)
 	self.classMap[class.name] = class
 	return class
 }
@@ -143,6 +234,7 @@ func resolveInterfaces(class *Class) {
 func link(class *Class) {
 	verify(class)
 	prepare(class)
+	class.finalizable = isFinalizable(class)
 }
 
 func verify(class *Class) {
@@ -157,39 +249,48 @@ func prepare(class *Class) {
 	allocAndInitStaticVars(class)
 }
 
+// 基本类型和引用类型的字段分别编号，子类的字段排在超类的后面
 func calcInstanceFieldSlotIds(class *Class) {
-	slotId := uint(0)
+	slotCount, numCount, refCount := uint(0), uint(0), uint(0)
 	if class.superClass != nil {
-		slotId = class.superClass.instanceSlotCount
+		slotCount = class.superClass.instanceSlotCount
+		numCount = class.superClass.instanceNumCount
+		refCount = class.superClass.instanceRefCount
 	}
 	for _, field := range class.fields {
 		if !field.IsStatic() {
-			field.slotId = slotId
-			slotId++
+			field.slotId, numCount, refCount = nextSlotId(field, numCount, refCount)
+			slotCount++
 			if field.isLongOrDouble() {
-				slotId++
+				slotCount++
 			}
 		}
 	}
-	class.instanceSlotCount = slotId
+	class.instanceSlotCount = slotCount
+	class.instanceNumCount = numCount
+	class.instanceRefCount = refCount
 }
 
 func calcStaticFieldSlotIds(class *Class) {
-	slotId := uint(0)
+	numCount, refCount := uint(0), uint(0)
 	for _, field := range class.fields {
 		if field.IsStatic() {
-			field.slotId = slotId
-			slotId++
-			if field.isLongOrDouble() {
-				slotId++
-			}
+			field.slotId, numCount, refCount = nextSlotId(field, numCount, refCount)
 		}
 	}
-	class.staticSlotCount = slotId
+	class.staticNumCount = numCount
+	class.staticRefCount = refCount
+}
+
+func nextSlotId(field *Field, numCount, refCount uint) (slotId, newNumCount, newRefCount uint) {
+	if field.isRef() {
+		return refCount, numCount, refCount + 1
+	}
+	return numCount, numCount + 1, refCount
 }
 
 func allocAndInitStaticVars(class *Class) {
-	class.staticVars = newSlots(class.staticSlotCount)
+	class.staticVars = newSlots(class.staticNumCount, class.staticRefCount)
 	for _, field := range class.fields {
 		if field.IsStatic() && field.IsFinal() {
 			initStaticFinalVar(class, field)
@@ -219,7 +320,7 @@ func initStaticFinalVar(class *Class, field *Field) {
 			vars.SetDouble(slotId, val)
 		case "Ljava/lang/String;":
 			goStr := cp.GetConstant(cpIndex).(string)
-			jStr := JString(class.Loader(), goStr)
+			jStr := InternedJString(class.Loader(), goStr)
 			vars.SetRef(slotId, jStr)
 		}
 	}
@@ -18,6 +18,9 @@ func (self *ClassMember) copyMemberInfo(memberInfo *classfile.MemberInfo) {
 	self.descriptor = memberInfo.Descriptor()
 }
 
+func (self *ClassMember) AccessFlags() uint16 {
+	return self.accessFlags
+}
 func (self *ClassMember) IsPublic() bool {
 	return 0 != self.accessFlags&ACC_PUBLIC
 }
@@ -48,6 +51,11 @@ func (self *ClassMember) Class() *Class {
 	return self.class
 }
 
+// IsAccessibleTo 类d是否可以访问这个字段或方法
+func (self *ClassMember) IsAccessibleTo(d *Class) bool {
+	return self.isAccessibleTo(d)
+}
+
 func (self *ClassMember) isAccessibleTo(d *Class) bool {
 	if self.IsPublic() { //字段是public 则任何类都可以访问
 		return true
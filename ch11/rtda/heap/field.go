@@ -5,7 +5,7 @@ import "jvmgo/ch11/classfile"
 type Field struct {
 	ClassMember          //继承ClassMember
 	constValueIndex uint //常量值索引，主要用于给类变量赋初始值
-	slotId          uint //字段编号，属于某个实例对象的第几个字段
+	slotId          uint //字段编号，基本类型和引用类型的字段分别编号，见slots.go
 }
 
 //newFields()根据class文件的字段信息创建字段表，代码如下
@@ -42,6 +42,9 @@ func (self *Field) SlotId() uint {
 func (self *Field) isLongOrDouble() bool { //通过描述符来判断
 	return self.descriptor == "J" || self.descriptor == "D"
 }
+func (self *Field) isRef() bool { //引用类型的字段放在Slots.refs中
+	return self.descriptor[0] == 'L' || self.descriptor[0] == '['
+}
 
 func (self *Field) copyAttributes(cfField *classfile.MemberInfo) {
 	if valAttr := cfField.ConstantValueAttribute(); valAttr != nil {
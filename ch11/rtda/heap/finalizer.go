@@ -0,0 +1,43 @@
+package heap
+
+/*
+终结
+覆盖了Object.finalize()并且不是空方法的类是可终结的，链接时确定
+NEW指令创建可终结类的实例之后调用RegisterFinalizer()登记，回收时如果登记过的对象只能通过登记表访问到，
+就把它从登记表中删除，连同它引用的对象一起重新标记，交给rtda在Finalizer线程中调用finalize()
+finalize()执行之后对象不再登记，下次不可达时直接回收，所以每个对象的finalize()最多执行一次
+*/
+
+func isFinalizable(class *Class) bool {
+	method := LookupMethodInClass(class, "finalize", "()V")
+	if method == nil || method.IsStatic() || method.class.isJlObject() {
+		return false
+	}
+	return len(method.code) != 1 || method.code[0] != 0xb1 //只有一条return指令的finalize()不需要调用
+}
+
+func (self *Class) IsFinalizable() bool {
+	return self.finalizable
+}
+
+// RegisterFinalizer 登记可终结类的实例，它不可达时调用它的finalize()
+func (self *Allocator) RegisterFinalizer(obj *Object) {
+	self.finalizers = append(self.finalizers, obj)
+}
+
+// takeFinalizees 从登记表中删除并返回标记之后仍然不可达的对象
+func (self *Allocator) takeFinalizees() (finalizees []*Object) {
+	live := self.finalizers[:0]
+	for _, obj := range self.finalizers {
+		if obj.marked {
+			live = append(live, obj)
+		} else {
+			finalizees = append(finalizees, obj)
+		}
+	}
+	for i := len(live); i < len(self.finalizers); i++ {
+		self.finalizers[i] = nil
+	}
+	self.finalizers = live
+	return finalizees
+}
@@ -0,0 +1,13 @@
+package heap
+
+/*
+内建函数(intrinsic)：JDK中用Java实现的方法，比如Arrays.fill()和String.indexOf()，逐个元素循环，解释执行很慢
+本地方法包在init()中用native.RegisterIntrinsic()注册它们的Go实现，加载类的时候把字节码换成调用本地方法表中的实现，和nativeHacks一样
+访问标志不变，getModifiers()和异常堆栈中看不出区别
+*/
+var intrinsics = map[string]bool{}
+
+// RegisterIntrinsic 把Java方法标记为内建函数，只能在init()中调用
+func RegisterIntrinsic(className, methodName, methodDescriptor string) {
+	intrinsics[className+"~"+methodName+"~"+methodDescriptor] = true
+}
@@ -0,0 +1,83 @@
+package heap
+
+/*
+latin1 是char[]的紧凑存储，每个元素一个字节，只有虚拟机根据Go字符串创建的value数组才这样存储
+读取元素不需要膨胀，用CharAt()；写入Latin-1之外的字符或者通过Chars()拿到整个数组时，先膨胀成[]uint16
+膨胀多出来的空间和分配对象一样记在堆上
+*/
+type latin1 []uint8
+
+// IsLatin1 char[]是不是紧凑存储的
+func (self *Object) IsLatin1() bool {
+	_, ok := self.data.(latin1)
+	return ok
+}
+
+// CharAt 返回char[]的第index个元素，不会膨胀紧凑存储的数组
+func (self *Object) CharAt(index int32) uint16 {
+	if bytes, ok := self.data.(latin1); ok {
+		return uint16(bytes[index])
+	}
+	return self.data.([]uint16)[index]
+}
+
+// SetCharAt 设置char[]的第index个元素，c不是Latin-1字符时膨胀数组
+func (self *Object) SetCharAt(index int32, c uint16) {
+	if bytes, ok := self.data.(latin1); ok && c <= 0xff {
+		bytes[index] = uint8(c)
+		return
+	}
+	self.Chars()[index] = c
+}
+
+// inflate 把紧凑存储的char[]膨胀成UTF-16
+func (self *Object) inflate(bytes latin1) {
+	count := uint(len(bytes))
+	self.class.loader.allocator.alloc(arraySize("[C", count) - arraySize("[B", count))
+	chars := make([]uint16, count)
+	for i, b := range bytes {
+		chars[i] = uint16(b)
+	}
+	self.data = chars
+}
+
+// FillChars 把char[]中[from, to)的元素设置为c
+func (self *Object) FillChars(from, to int32, c uint16) {
+	if bytes, ok := self.data.(latin1); ok && c <= 0xff {
+		for i := from; i < to; i++ {
+			bytes[i] = uint8(c)
+		}
+		return
+	}
+	chars := self.Chars()
+	for i := from; i < to; i++ {
+		chars[i] = c
+	}
+}
+
+// CharsEqual 两个char[]的元素是否全部相等，不会膨胀紧凑存储的数组
+func CharsEqual(a, b *Object) bool {
+	if a.ArrayLength() != b.ArrayLength() {
+		return false
+	}
+	bytesA, latin1A := a.data.(latin1)
+	bytesB, latin1B := b.data.(latin1)
+	switch {
+	case latin1A && latin1B:
+		return string(bytesA) == string(bytesB)
+	case !latin1A && !latin1B:
+		charsA, charsB := a.data.([]uint16), b.data.([]uint16)
+		for i := range charsA {
+			if charsA[i] != charsB[i] {
+				return false
+			}
+		}
+		return true
+	}
+	for i := int32(0); i < a.ArrayLength(); i++ {
+		if a.CharAt(i) != b.CharAt(i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package heap
+
+import "jvmgo/ch11/classfile"
+
+// LocalVariable LocalVariableTable中的一项，只在[startPc, startPc+length)范围内有效
+type LocalVariable struct {
+	startPc    int
+	length     int
+	name       string
+	descriptor string
+	index      uint //在局部变量表中的位置
+}
+
+func newLocalVariables(codeAttr *classfile.CodeAttribute) []*LocalVariable {
+	lvtAttr := codeAttr.LocalVariableTableAttribute()
+	if lvtAttr == nil {
+		return nil //编译时没有加-g选项
+	}
+	cp := codeAttr.ConstantPool()
+	entries := lvtAttr.LocalVariableTable()
+	vars := make([]*LocalVariable, len(entries))
+	for i, entry := range entries {
+		vars[i] = &LocalVariable{
+			startPc:    int(entry.StartPc()),
+			length:     int(entry.Length()),
+			name:       cp.GetUtf8(entry.NameIndex()),
+			descriptor: cp.GetUtf8(entry.DescriptorIndex()),
+			index:      uint(entry.Index()),
+		}
+	}
+	return vars
+}
+
+func (self *LocalVariable) Name() string {
+	return self.name
+}
+func (self *LocalVariable) Descriptor() string {
+	return self.descriptor
+}
+func (self *LocalVariable) Index() uint {
+	return self.index
+}
+func (self *LocalVariable) StartPC() int {
+	return self.startPc
+}
+func (self *LocalVariable) Length() int {
+	return self.length
+}
+
+// LocalVariableTable 返回LocalVariableTable中的所有项，没有这个属性时返回nil
+func (self *Method) LocalVariableTable() []*LocalVariable {
+	return self.localVariables
+}
+
+// LocalVariables 返回执行到pc时有效的局部变量，没有LocalVariableTable属性时返回nil
+func (self *Method) LocalVariables(pc int) []*LocalVariable {
+	var vars []*LocalVariable
+	for _, v := range self.localVariables {
+		if pc >= v.startPc && pc < v.startPc+v.length {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
@@ -12,6 +12,8 @@ type Method struct {
 	argSlotCount    uint           //方法参数在局部变量表中占据的位置
 	exceptionTable  ExceptionTable //方法对应的异常处理表
 	lineNumberTable *classfile.LineNumberTableAttribute
+	localVariables  []*LocalVariable //调试器根据LocalVariableTable显示局部变量的名字
+	intrinsic       bool             //用Go实现的Java方法，访问标志不变，见intrinsics.go
 }
 
 func (self *Method) copyAttributes(cfMethod *classfile.MemberInfo) {
@@ -20,6 +22,7 @@ func (self *Method) copyAttributes(cfMethod *classfile.MemberInfo) {
 		self.maxLocals = codeAttr.MaxLocals()
 		self.code = codeAttr.Code()
 		self.lineNumberTable = codeAttr.LineNumberTableAttribute()
+		self.localVariables = newLocalVariables(codeAttr)
 		self.exceptionTable = newExceptionTable(codeAttr.ExceptionTable(), self.class.constantPool)
 	}
 }
@@ -39,16 +42,26 @@ func newMethod(class *Class, cfMethod *classfile.MemberInfo) *Method {
 	method.copyAttributes(cfMethod)                //复制code属性和局部变量表大小和操作数栈大小
 	md := parseMethodDescriptor(method.descriptor) //解析出方法的描述符
 	method.calcArgSlotCount(md.parameterTypes)     //计算方法的argSlotCount
-	if method.IsNative() {
+	if key := class.name + "~" + method.name + "~" + method.descriptor; nativeHacks[key] || intrinsics[key] {
+		method.intrinsic = true
+	}
+	if method.IsNative() || method.intrinsic {
 		method.injectCodeAttribute(md.returnType)
 	}
 	return method
 }
 
-//注入字节码和其他信息
+// hack! 这些方法在JDK中用Java实现，但是依赖大量反射代码，这里和内建函数一样调用Go实现
+var nativeHacks = map[string]bool{
+	"java/lang/Class~newInstance~()Ljava/lang/Object;": true,
+}
+
+// 注入字节码和其他信息，内建函数原来的异常处理表和局部变量表不再对应新的字节码
 func (self *Method) injectCodeAttribute(returnType string) {
 	self.maxStack = 4
 	self.maxLocals = self.argSlotCount
+	self.exceptionTable = nil
+	self.localVariables = nil
 	switch returnType[0] {
 	case 'V':
 		self.code = []byte{0xfe, 0xb1} //return
@@ -105,6 +118,11 @@ func (self *Method) IsStrict() bool {
 	return 0 != self.accessFlags&ACC_STRICT
 }
 
+// IsIntrinsic 方法是否由Go实现代替字节码，见intrinsics.go
+func (self *Method) IsIntrinsic() bool {
+	return self.intrinsic
+}
+
 // getters
 func (self *Method) MaxStack() uint {
 	return self.maxStack
@@ -115,10 +133,25 @@ func (self *Method) MaxLocals() uint {
 func (self *Method) Code() []byte {
 	return self.code
 }
+
+// ParameterTypes 返回参数类型的描述符，例如(ILjava/lang/String;)V返回I和Ljava/lang/String;
+func (self *Method) ParameterTypes() []string {
+	return parseMethodDescriptor(self.descriptor).parameterTypes
+}
+
+func (self *Method) ReturnType() string {
+	return parseMethodDescriptor(self.descriptor).returnType
+}
+
 func (self *Method) ArgSlotCount() uint {
 	return self.argSlotCount
 }
 
+// LineNumberTable 没有LineNumberTable属性时返回nil
+func (self *Method) LineNumberTable() *classfile.LineNumberTableAttribute {
+	return self.lineNumberTable
+}
+
 func (self *Method) GetLineNumber(pc int) int {
 	if self.IsNative() {
 		return -2
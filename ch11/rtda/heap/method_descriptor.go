@@ -16,3 +16,9 @@ func (self *MethodDescriptor) addParameterType(t string) {
 
 	self.parameterTypes = append(self.parameterTypes, t) //append进新的，完成addParameterType
 }
+
+// ParseMethodDescriptor 返回参数类型和返回值类型的描述符，描述符格式错误时panic
+func ParseMethodDescriptor(descriptor string) (parameterTypes []string, returnType string) {
+	parsed := parseMethodDescriptor(descriptor)
+	return parsed.parameterTypes, parsed.returnType
+}
@@ -0,0 +1,79 @@
+package heap
+
+/*
+shim方法不属于任何class文件，由虚拟机在需要的时候推入栈中
+比如在本地方法中抛出Java异常：异常对象的构造函数执行完之后，由只有一条athrow指令的方法把异常抛出
+*/
+
+var _shimClass = &Class{name: "~shim"}
+
+var _athrowMethod = &Method{
+	ClassMember: ClassMember{
+		accessFlags: ACC_STATIC,
+		name:        "<athrow>",
+		class:       _shimClass,
+	},
+	maxStack: 1,
+	code:     []byte{0xbf}, // athrow
+}
+
+/*
+从Go代码调用Java方法时，先在栈底推入这个方法的帧(nextPC为1)，被调用方法的返回值留在它的操作数栈上
+它的异常处理项捕获所有异常，被调用方法抛出未捕获的异常时nextPC变为2，操作数栈上是异常对象
+这个方法的字节码永远不会被执行，调用者在栈中只剩下它的时候就停止解释
+*/
+var _callMethod = &Method{
+	ClassMember: ClassMember{
+		accessFlags: ACC_STATIC,
+		name:        "<call>",
+		class:       _shimClass,
+	},
+	maxStack: 2,
+	code:     []byte{0x00, 0x00, 0x00}, // nop
+	exceptionTable: ExceptionTable{
+		{startPc: 0, endPc: 3, handlerPc: 2}, // catch-all
+	},
+}
+
+/*
+Finalizer线程的栈底是这个方法的帧，它不停地调用本地方法，本地方法推入finalize()的帧或者让线程等待
+finalize()抛出的异常被捕获并丢弃，见rtda/finalizer.go
+*/
+var _finalizerMethod = &Method{
+	ClassMember: ClassMember{
+		accessFlags: ACC_STATIC,
+		name:        "<finalizer>",
+		descriptor:  "()V",
+		class:       _shimClass,
+	},
+	maxStack: 1,
+	code: []byte{
+		0xfe,             // 0: invokenative
+		0xa7, 0xff, 0xff, // 1: goto 0
+		0x57,             // 4: pop
+		0xa7, 0xff, 0xfb, // 5: goto 0
+	},
+	exceptionTable: ExceptionTable{
+		{startPc: 0, endPc: 4, handlerPc: 4}, // catch-all
+	},
+}
+
+// ShimCallMethod 见_callMethod
+func ShimCallMethod() *Method {
+	return _callMethod
+}
+
+// ShimFinalizerMethod 见_finalizerMethod
+func ShimFinalizerMethod() *Method {
+	return _finalizerMethod
+}
+
+// ShimAthrowMethod 操作数栈顶的异常对象由这个方法抛出
+func ShimAthrowMethod() *Method {
+	return _athrowMethod
+}
+
+// IsShim 是否为虚拟机内部使用的方法，这些方法不应该出现在异常的栈信息中
+func (self *Method) IsShim() bool {
+	return self.class == _shimClass
+}
@@ -6,10 +6,12 @@ package heap
 
 type Object struct {
 	//todo
-	class *Class //存放对象指针
-	//fields Slots  //存放实例变量
-	data  interface{}
-	extra interface{}
+	class  *Class      //存放对象指针
+	fields Slots       //存放实例变量
+	data   interface{} //数组的元素，见array_object.go
+	extra  interface{}
+	hash   int32 //identity hash code，0表示还没有生成，见object_hash.go
+	marked bool  //垃圾回收的标记，见arena.go
 }
 
 func (self *Object) Extra() interface{} {
@@ -21,10 +23,8 @@ func (self *Object) SetExtra(extra interface{}) {
 
 //创建普通的对象
 func newObject(class *Class) *Object {
-	return &Object{
-		class: class,
-		data:  newSlots(class.instanceSlotCount),
-	}
+	class.loader.allocator.alloc(instanceSize(class))
+	return class.loader.allocator.place(class, newSlots(class.instanceNumCount, class.instanceRefCount), nil)
 }
 
 func (self *Object) IsInstanceOf(class *Class) bool {
@@ -36,18 +36,26 @@ func (self *Object) Class() *Class {
 	return self.class
 }
 func (self *Object) Fields() Slots {
-	return self.data.(Slots)
+	return self.fields
 }
 
 // SetRefVar 给对象的引用类型实例变量赋值
 func (self *Object) SetRefVar(name, descriptor string, ref *Object) {
 	field := self.class.getField(name, descriptor, false) //查找字段
-	slots := self.data.(Slots)                            //对象的实例变量数组
-	slots.SetRef(field.slotId, ref)                       //对应的引用类型实例变量赋值
+	self.fields.SetRef(field.slotId, ref)                 //对应的引用类型实例变量赋值
 }
 
 func (self *Object) GetRefVar(name, descriptor string) *Object {
 	field := self.class.getField(name, descriptor, false)
-	slots := self.data.(Slots)
-	return slots.GetRef(field.slotId)
+	return self.fields.GetRef(field.slotId)
+}
+
+func (self *Object) SetIntVar(name, descriptor string, val int32) {
+	field := self.class.getField(name, descriptor, false)
+	self.fields.SetInt(field.slotId, val)
+}
+
+func (self *Object) GetIntVar(name, descriptor string) int32 {
+	field := self.class.getField(name, descriptor, false)
+	return self.fields.GetInt(field.slotId)
 }
@@ -1,10 +1,13 @@
 package heap
 
 func (self *Object) Clone() *Object {
-	return &Object{
-		class: self.class,
-		data:  self.cloneData(),
+	allocator := self.class.loader.allocator
+	allocator.alloc(self.ShallowSize())
+	clone := allocator.place(self.class, self.fields.clone(), self.cloneData())
+	if self.class.finalizable {
+		allocator.RegisterFinalizer(clone)
 	}
+	return clone
 }
 
 func (self *Object) cloneData() interface{} {
@@ -24,6 +27,11 @@ func (self *Object) cloneData() interface{} {
 		elements2 := make([]uint16, len(elements))
 		copy(elements2, elements)
 		return elements2
+	case latin1:
+		elements := self.data.(latin1)
+		elements2 := make(latin1, len(elements))
+		copy(elements2, elements)
+		return elements2
 	case []int32:
 		elements := self.data.([]int32)
 		elements2 := make([]int32, len(elements))
@@ -49,10 +57,7 @@ func (self *Object) cloneData() interface{} {
 		elements2 := make([]*Object, len(elements))
 		copy(elements2, elements)
 		return elements2
-	default: // []Slot
-		slots := self.data.(Slots)
-		slots2 := newSlots(uint(len(slots)))
-		copy(slots2, slots)
-		return slots2
+	default: // 不是数组，实例变量见Slots.clone()
+		return nil
 	}
 }
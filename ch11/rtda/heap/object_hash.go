@@ -0,0 +1,108 @@
+package heap
+
+import "unsafe"
+
+// identity hash code 的生成策略，编号与HotSpot的 -XX:hashCode=N 保持一致
+const (
+	HASH_CODE_GLOBAL_RANDOM = 0 // 全局的Park-Miller随机数
+	HASH_CODE_ADDRESS_MIXED = 1 // 对象地址和一个全局随机数混合
+	HASH_CODE_CONSTANT      = 2 // 恒为1，用来测试哈希冲突
+	HASH_CODE_SEQUENTIAL    = 3 // 全局自增序列
+	HASH_CODE_ADDRESS       = 4 // 直接使用对象地址
+	HASH_CODE_XOR_SHIFT     = 5 // 线程私有的xorshift随机数，默认策略
+)
+
+// 和HotSpot一样，哈希值只保留31位，0表示还没有生成过
+const hashMask = 0x7FFFFFFF
+
+// hashCodes 生成identity hash code用到的全局状态，每个虚拟机(类加载器)一份
+type hashCodes struct {
+	mode         int
+	randomSeed   uint32 // Park-Miller随机数的种子
+	stwRandom    uint32 // 策略1中和地址混合的随机数
+	hashSequence uint32 // 策略3使用的序列号
+}
+
+func newHashCodes() *hashCodes {
+	return &hashCodes{
+		mode:       HASH_CODE_XOR_SHIFT,
+		randomSeed: 1234567,
+		stwRandom:  0x5DEECE6,
+	}
+}
+
+// SetHashCodeMode 设置identity hash code的生成策略，取值见HASH_CODE_*常量
+// 和HotSpot一样，未知的取值都按xorshift处理
+func (self *ClassLoader) SetHashCodeMode(mode int) {
+	self.hashCodes.mode = mode
+}
+
+// HashState 每个线程私有的xorshift状态
+type HashState struct {
+	x, y, z, w uint32
+}
+
+func (self *ClassLoader) NewHashState() *HashState {
+	return &HashState{
+		x: self.hashCodes.nextRandom(),
+		y: 842502087,
+		z: 0x8767,
+		w: 273326509,
+	}
+}
+
+// Marsaglia的xorshift算法
+func (self *HashState) next() uint32 {
+	t := self.x
+	t ^= t << 11
+	self.x = self.y
+	self.y = self.z
+	self.z = self.w
+	v := self.w
+	v = (v ^ (v >> 19)) ^ (t ^ (t >> 8))
+	self.w = v
+	return v
+}
+
+// Park-Miller "minimal standard" 随机数，和HotSpot的os::random()一致
+func (self *hashCodes) nextRandom() uint32 {
+	const a = 16807
+	const m = 2147483647
+	self.randomSeed = uint32(uint64(self.randomSeed) * a % m)
+	return self.randomSeed
+}
+
+// IdentityHashCode 返回对象的identity hash code
+// 哈希值在第一次使用时生成并保存在对象头中，之后就不再变化
+func (self *Object) IdentityHashCode(state *HashState) int32 {
+	if self.hash == 0 {
+		self.hash = self.generateHash(state)
+	}
+	return self.hash
+}
+
+func (self *Object) generateHash(state *HashState) int32 {
+	codes := self.class.loader.hashCodes
+	var value uint32
+	switch codes.mode {
+	case HASH_CODE_GLOBAL_RANDOM:
+		value = codes.nextRandom()
+	case HASH_CODE_ADDRESS_MIXED:
+		addrBits := uint32(uintptr(unsafe.Pointer(self)) >> 3)
+		value = addrBits ^ (addrBits >> 5) ^ codes.stwRandom
+	case HASH_CODE_CONSTANT:
+		value = 1
+	case HASH_CODE_SEQUENTIAL:
+		codes.hashSequence++
+		value = codes.hashSequence
+	case HASH_CODE_ADDRESS:
+		value = uint32(uintptr(unsafe.Pointer(self)))
+	default:
+		value = state.next()
+	}
+	value &= hashMask
+	if value == 0 {
+		value = 0xBAD
+	}
+	return int32(value)
+}
@@ -0,0 +1,24 @@
+package heap
+
+// References 对对象直接引用的每个对象调用fn：引用类型的实例变量或者数组元素，类对象还包括类的静态变量
+func (self *Object) References(fn func(ref *Object)) {
+	if refs, ok := self.data.([]*Object); ok {
+		for _, ref := range refs {
+			if ref != nil {
+				fn(ref)
+			}
+		}
+	}
+	self.fields.references(fn)
+	if class, ok := self.extra.(*Class); ok {
+		class.staticVars.references(fn)
+	}
+}
+
+func (self Slots) references(fn func(ref *Object)) {
+	for _, ref := range self.refs {
+		if ref != nil {
+			fn(ref)
+		}
+	}
+}
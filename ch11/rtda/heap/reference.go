@@ -0,0 +1,61 @@
+package heap
+
+/*
+java.lang.ref.Reference的子类的实例是引用对象，标记时不经过它的referent字段
+标记结束之后referent没有被标记(只能通过引用对象访问到)的，清除referent，交给rtda放到Reference.pending链表中
+
+软引用平时和普通对象一样，只有回收之后仍然放不下时才清除，见Allocator.reclaim()
+虚引用在终结之后处理，和JDK 9之后一样在入队时清除referent，反正PhantomReference.get()总是返回null
+*/
+
+// 引用对象的种类，子类继承父类的种类
+const (
+	REF_NONE uint8 = iota
+	REF_SOFT
+	REF_WEAK
+	REF_PHANTOM
+)
+
+func referenceKind(class *Class) uint8 {
+	switch class.name {
+	case "java/lang/ref/SoftReference":
+		return REF_SOFT
+	case "java/lang/ref/WeakReference":
+		return REF_WEAK
+	case "java/lang/ref/PhantomReference":
+		return REF_PHANTOM
+	}
+	if class.superClass != nil {
+		return class.superClass.refKind
+	}
+	return REF_NONE
+}
+
+// weakReferent 标记时是否应该跳过对象的referent字段，是的话返回这个字段的slotId
+func (self *Object) weakReferent(clearSoft bool) (uint, bool) {
+	switch self.class.refKind {
+	case REF_NONE:
+		return 0, false
+	case REF_SOFT:
+		if !clearSoft {
+			return 0, false
+		}
+	}
+	return self.class.getField("referent", "Ljava/lang/Object;", false).slotId, true
+}
+
+// clearReferences 清除referent没有被标记的引用对象，phantom为false时不处理虚引用，返回被清除的引用对象
+func clearReferences(discovered []*Object, clearSoft, phantom bool) (cleared []*Object) {
+	for _, ref := range discovered {
+		if ref.class.refKind == REF_PHANTOM && !phantom {
+			continue
+		}
+		slotId, _ := ref.weakReferent(clearSoft)
+		fields := ref.Fields()
+		if referent := fields.GetRef(slotId); referent != nil && !referent.marked {
+			fields.SetRef(slotId, nil)
+			cleared = append(cleared, ref)
+		}
+	}
+	return cleared
+}
@@ -2,60 +2,74 @@ package heap
 
 import "math"
 
-type Slot struct {
-	num int32
-	ref *Object
+/*
+Slots 用来表示类变量和实例变量，基本类型和引用分开存放，访问时不需要类型断言
+基本类型的变量放在nums中，long和double也只占一个位置；引用类型的变量放在refs中
+字段的slotId是它在nums或者refs中的下标，由字段的类型决定是哪一个，见class_loader.go中的calcInstanceFieldSlotIds()
+*/
+type Slots struct {
+	nums []uint64
+	refs []*Object
 }
 
-type Slots []Slot //Slots数组，用该结构体来表示类变量和实例变量
+func newSlots(numCount, refCount uint) Slots {
+	var slots Slots
+	if numCount > 0 {
+		slots.nums = make([]uint64, numCount)
+	}
+	if refCount > 0 {
+		slots.refs = make([]*Object, refCount)
+	}
+	return slots
+}
 
-func newSlots(slotCount uint) Slots {
-	if slotCount > 0 {
-		return make([]Slot, slotCount)
+func (self Slots) clone() Slots {
+	var slots Slots
+	if self.nums != nil {
+		slots.nums = append([]uint64(nil), self.nums...)
 	}
-	return nil
+	if self.refs != nil {
+		slots.refs = append([]*Object(nil), self.refs...)
+	}
+	return slots
 }
 
 func (self Slots) SetInt(index uint, val int32) {
-	self[index].num = val
+	self.nums[index] = uint64(uint32(val))
 }
 func (self Slots) GetInt(index uint) int32 {
-	return self[index].num
+	return int32(self.nums[index])
 }
 
 func (self Slots) SetFloat(index uint, val float32) {
-	bits := math.Float32bits(val)
-	self[index].num = int32(bits)
+	self.nums[index] = uint64(math.Float32bits(val))
 }
 func (self Slots) GetFloat(index uint) float32 {
-	bits := uint32(self[index].num)
-	return math.Float32frombits(bits)
+	return math.Float32frombits(uint32(self.nums[index]))
 }
 
-// long consumes two slots
 func (self Slots) SetLong(index uint, val int64) {
-	self[index].num = int32(val)
-	self[index+1].num = int32(val >> 32)
+	self.nums[index] = uint64(val)
 }
 func (self Slots) GetLong(index uint) int64 {
-	low := uint32(self[index].num)
-	high := uint32(self[index+1].num)
-	return int64(high)<<32 | int64(low)
+	return int64(self.nums[index])
 }
 
-// double consumes two slots
 func (self Slots) SetDouble(index uint, val float64) {
-	bits := math.Float64bits(val)
-	self.SetLong(index, int64(bits))
+	self.nums[index] = math.Float64bits(val)
 }
 func (self Slots) GetDouble(index uint) float64 {
-	bits := uint64(self.GetLong(index))
-	return math.Float64frombits(bits)
+	return math.Float64frombits(self.nums[index])
 }
 
 func (self Slots) SetRef(index uint, ref *Object) {
-	self[index].ref = ref
+	self.refs[index] = ref
 }
 func (self Slots) GetRef(index uint) *Object {
-	return self[index].ref
+	return self.refs[index]
+}
+
+// Refs 所有引用类型的变量
+func (self Slots) Refs() []*Object {
+	return self.refs
 }
@@ -1,26 +1,47 @@
 package heap
 
-import "unicode/utf16"
+import (
+	"strings"
+	"unicode/utf16"
+)
 
-//用map来表示字符串池，key是Go字符串，value是Java字符串
-var internedStrings = map[string]*Object{}
+/*
+虚拟机创建的字符串(字符串常量、类名、线程名等等)如果只包含Latin-1字符，value数组是紧凑存储的，每个字符一个字节
+Java代码看到的仍然是char[]，写入Latin-1之外的字符时数组才膨胀成UTF-16，见latin1.go
+*/
 
-// JString 根据Go字符串返回相应的Java字符串
+// JString 根据Go字符串创建新的Java字符串，每次调用返回的都是不同的对象，相当于new String()
 func JString(loader *ClassLoader, goStr string) *Object {
-	if internedStr, ok := internedStrings[goStr]; ok {
-		return internedStr //如果Java字符串已经在池中了，直接返回即可
+	charArrClass := loader.LoadClass("[C")
+	var jChars *Object
+	if bytes, ok := stringToLatin1(goStr); ok {
+		loader.allocator.alloc(arraySize("[B", uint(len(bytes))))
+		jChars = loader.allocator.place(charArrClass, Slots{}, bytes)
+	} else {
+		chars := stringToUtf16(goStr) //先把Go字符串UTF格式转换成Java字符数组UTF16格式
+		loader.allocator.alloc(arraySize(charArrClass.name, uint(len(chars))))
+		jChars = loader.allocator.place(charArrClass, Slots{}, chars)
 	}
-	chars := stringToUtf16(goStr) //先把Go字符串UTF格式转换成Java字符数组UTF16格式
-	jChars := &Object{loader.LoadClass("[C"), chars, nil}
 	jStr := loader.LoadClass("java/lang/String").NewObject() //创建Java字符串实例
 	jStr.SetRefVar("value", "[C", jChars)                    //将字符串实例的value变量设置为刚刚转换来的字符数组
-	internedStrings[goStr] = jStr                            //放入字符串池
-	return jStr                                              //返回结果字符串
+	return jStr
+}
+
+// InternedJString 返回字符串池中和Go字符串相等的Java字符串，池中没有时创建一个放进去
+// ldc和ConstantValue属性中的字符串常量都是这样得到的，和HotSpot一样，类名和栈帧信息中的字符串也在池中
+func InternedJString(loader *ClassLoader, goStr string) *Object {
+	if internedStr := loader.stringTable.lookup(goStr); internedStr != nil {
+		return internedStr //如果Java字符串已经在池中了，直接返回即可
+	}
+	return loader.stringTable.add(goStr, JString(loader, goStr))
 }
 
 func GoString(jStr *Object) string {
 	charArr := jStr.GetRefVar("value", "[C") //拿到value变量值
-	return utf16ToString(charArr.Chars())    //转换成Go字符串
+	if bytes, ok := charArr.data.(latin1); ok {
+		return latin1ToString(bytes)
+	}
+	return utf16ToString(charArr.Chars()) //转换成Go字符串
 }
 
 func stringToUtf16(s string) []uint16 {
@@ -33,11 +54,39 @@ func utf16ToString(s []uint16) string {
 	return string(runes)
 }
 
+// stringToLatin1 字符串中有Latin-1之外的字符时返回false
+func stringToLatin1(s string) (latin1, bool) {
+	bytes := make(latin1, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, false
+		}
+		bytes = append(bytes, uint8(r))
+	}
+	return bytes, true
+}
+
+func latin1ToString(s latin1) string {
+	var builder strings.Builder
+	builder.Grow(len(s))
+	for _, b := range s {
+		builder.WriteRune(rune(b))
+	}
+	return builder.String()
+}
+
+// InternString 对应String.intern()，池中没有相等的字符串时把jStr放进去
 func InternString(jStr *Object) *Object {
 	goStr := GoString(jStr)
-	if internedStr, ok := internedStrings[goStr]; ok {
-		return internedStr
-	}
-	internedStrings[goStr] = jStr
-	return jStr
+	return jStr.Class().Loader().stringTable.add(goStr, jStr)
+}
+
+// StringTable 返回字符串池
+func (self *ClassLoader) StringTable() *StringTable {
+	return self.stringTable
+}
+
+// InternedStrings 返回字符串池中的所有字符串
+func (self *ClassLoader) InternedStrings() []*Object {
+	return self.stringTable.Strings()
 }
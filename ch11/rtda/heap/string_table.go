@@ -0,0 +1,130 @@
+package heap
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+/*
+StringTable 字符串池，ldc和ConstantValue属性中的字符串常量以及String.intern()的结果都在这里
+池按Go字符串的哈希值分成若干段，每段有自己的锁，不同段的查找互不影响
+
+段里的字符串在加锁之前创建：创建时可能触发回收，回收时要遍历整个池
+*/
+type StringTable struct {
+	shards [stringTableShards]stringTableShard
+}
+
+const stringTableShards = 16 //必须是2的幂
+
+type stringTableShard struct {
+	lock    sync.Mutex
+	strings map[string]*Object // key是Go字符串，value是Java字符串
+}
+
+func newStringTable() *StringTable {
+	table := &StringTable{}
+	for i := range table.shards {
+		table.shards[i].strings = make(map[string]*Object)
+	}
+	return table
+}
+
+// shard 返回goStr所在的段，哈希用的是FNV-1a
+func (self *StringTable) shard(goStr string) *stringTableShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(goStr); i++ {
+		hash ^= uint32(goStr[i])
+		hash *= 16777619
+	}
+	return &self.shards[hash&(stringTableShards-1)]
+}
+
+func (self *StringTable) lookup(goStr string) *Object {
+	shard := self.shard(goStr)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	return shard.strings[goStr]
+}
+
+// add 池中没有goStr时放入jStr，否则返回池中已有的字符串
+func (self *StringTable) add(goStr string, jStr *Object) *Object {
+	shard := self.shard(goStr)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	if interned, ok := shard.strings[goStr]; ok {
+		return interned
+	}
+	shard.strings[goStr] = jStr
+	return jStr
+}
+
+// Strings 返回池中的所有字符串
+func (self *StringTable) Strings() []*Object {
+	var strs []*Object
+	for i := range self.shards {
+		shard := &self.shards[i]
+		shard.lock.Lock()
+		for _, jStr := range shard.strings {
+			strs = append(strs, jStr)
+		}
+		shard.lock.Unlock()
+	}
+	return strs
+}
+
+/*
+WriteStatistics 按HotSpot的-XX:+PrintStringTableStatistics的格式打印字符串池的统计信息
+这里的桶就是段，literals是字符串的字符数组占用的空间
+*/
+func (self *StringTable) WriteStatistics(w io.Writer) {
+	var entries, entryBytes, literals, latin1Literals, literalBytes, maxSize int64
+	var sizes [stringTableShards]int64
+	for i := range self.shards {
+		shard := &self.shards[i]
+		shard.lock.Lock()
+		for _, jStr := range shard.strings {
+			entryBytes += jStr.ShallowSize()
+			if value := jStr.GetRefVar("value", "[C"); value != nil {
+				literals++
+				literalBytes += value.ShallowSize()
+				if value.IsLatin1() {
+					latin1Literals++
+				}
+			}
+		}
+		sizes[i] = int64(len(shard.strings))
+		shard.lock.Unlock()
+		entries += sizes[i]
+		if sizes[i] > maxSize {
+			maxSize = sizes[i]
+		}
+	}
+
+	avg := float64(entries) / stringTableShards
+	variance := 0.0
+	for _, size := range sizes {
+		variance += (float64(size) - avg) * (float64(size) - avg)
+	}
+	variance /= stringTableShards
+	entrySize, avgLiteral := int64(0), 0.0
+	if entries > 0 {
+		entrySize = entryBytes / entries //String对象的大小都一样
+	}
+	if literals > 0 {
+		avgLiteral = float64(literalBytes) / float64(literals)
+	}
+	bucketBytes := int64(stringTableShards * refSize)
+	fmt.Fprintf(w, "StringTable statistics:\n")
+	fmt.Fprintf(w, "Number of buckets       : %9d = %9d bytes, each %d\n", stringTableShards, bucketBytes, refSize)
+	fmt.Fprintf(w, "Number of entries       : %9d = %9d bytes, each %d\n", entries, entryBytes, entrySize)
+	fmt.Fprintf(w, "Number of literals      : %9d = %9d bytes, avg %7.3f\n", literals, literalBytes, avgLiteral)
+	fmt.Fprintf(w, "Number of Latin-1       : %9d\n", latin1Literals)
+	fmt.Fprintf(w, "Total footprint         : %9s = %9d bytes\n", "", bucketBytes+entryBytes+literalBytes)
+	fmt.Fprintf(w, "Average bucket size     : %9.3f\n", avg)
+	fmt.Fprintf(w, "Variance of bucket size : %9.3f\n", variance)
+	fmt.Fprintf(w, "Std. dev. of bucket size: %9.3f\n", math.Sqrt(variance))
+	fmt.Fprintf(w, "Maximum bucket size     : %9d\n", maxSize)
+}
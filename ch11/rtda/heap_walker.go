@@ -0,0 +1,73 @@
+package rtda
+
+import "jvmgo/ch11/rtda/heap"
+
+/*
+对象没有统一的登记表，要找到Java程序持有的所有对象，只能从根出发沿着引用遍历
+根包括：所有线程的帧(局部变量表和操作数栈)、Thread对象、所有已加载类的类对象(通过它找到静态变量)以及字符串池
+*/
+
+type RootKind int
+
+const (
+	ROOT_FRAME  RootKind = iota // 局部变量或者操作数栈中的引用
+	ROOT_THREAD                 // 线程的Thread对象
+	ROOT_CLASS                  // 已加载类的类对象
+	ROOT_STRING                 // 字符串池中的字符串
+)
+
+type Root struct {
+	Kind   RootKind
+	Object *heap.Object
+	Thread *Thread // ROOT_FRAME和ROOT_THREAD
+	Frame  int     // ROOT_FRAME：帧在栈中的位置，栈顶是0
+}
+
+// Roots 返回所有的根，同一个对象可以出现多次
+func (self *Runtime) Roots() []Root {
+	var roots []Root
+	for _, thread := range self.threads {
+		if thread.jThread != nil {
+			roots = append(roots, Root{Kind: ROOT_THREAD, Object: thread.jThread, Thread: thread})
+		}
+		for i, frame := range thread.GetFrames() {
+			for _, refs := range [][]*heap.Object{frame.localVars.refs, frame.operandStack.liveRefs()} {
+				for _, ref := range refs {
+					if ref != nil {
+						roots = append(roots, Root{Kind: ROOT_FRAME, Object: ref, Thread: thread, Frame: i})
+					}
+				}
+			}
+		}
+	}
+	for _, class := range self.loader.LoadedClasses() {
+		if class.JClass() != nil {
+			roots = append(roots, Root{Kind: ROOT_CLASS, Object: class.JClass()})
+		}
+	}
+	for _, jStr := range self.loader.InternedStrings() {
+		roots = append(roots, Root{Kind: ROOT_STRING, Object: jStr})
+	}
+	return roots
+}
+
+// WalkHeap 从根出发访问所有可达的对象，每个对象只访问一次
+func (self *Runtime) WalkHeap(visit func(obj *heap.Object)) {
+	visited := map[*heap.Object]bool{}
+	var pending []*heap.Object
+	mark := func(obj *heap.Object) {
+		if !visited[obj] {
+			visited[obj] = true
+			pending = append(pending, obj)
+		}
+	}
+	for _, root := range self.Roots() {
+		mark(root.Object)
+	}
+	for len(pending) > 0 {
+		obj := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		visit(obj)
+		obj.References(mark)
+	}
+}
@@ -1,29 +1,59 @@
 package rtda
 
+import "errors"
+
 /*
 虚拟机栈
+栈的容量和HotSpot的-Xss一样按字节计算，每一帧的大小由局部变量表和操作数栈的大小决定，见Frame.size()
+栈满时panic(ErrStackOverflow)，由解释器转换成StackOverflowError
+之后还可以使用stackReserve字节的空间，用来创建异常对象和执行异常处理代码，栈回到容量以内时恢复
+在预留空间中又溢出时panic(ErrStackReserveExhausted)，这时已经无法抛出异常，由解释器报告致命错误
 */
 
+const (
+	DefaultStackSize = 1 << 20  //没有指定-Xss时的栈大小，和64位HotSpot一样是1MB
+	MinStackSize     = 64 << 10 //栈至少要能容纳创建StackOverflowError时的帧
+	stackReserve     = 64 << 10
+	frameOverhead    = 96 //每一帧除了局部变量表和操作数栈以外的开销，大约是HotSpot解释器帧的大小
+	slotBytes        = 8
+)
+
+// ErrStackOverflow 栈空间不足，解释器把它转换成java.lang.StackOverflowError
+var ErrStackOverflow = errors.New("java.lang.StackOverflowError")
+
+// ErrStackReserveExhausted 创建或者处理StackOverflowError时又溢出了
+var ErrStackReserveExhausted = errors.New("stack overflow while handling java.lang.StackOverflowError")
+
 type Stack struct {
-	maxSize uint   //栈的容量，最多可以容纳多少帧
-	size    uint   //当前栈的大小
-	_top    *Frame //_top保存栈顶指针
+	maxBytes uint   //栈的容量，单位是字节
+	bytes    uint   //所有帧的大小
+	size     uint   //当前栈的帧数
+	reserved bool   //已经抛出了StackOverflowError，正在使用stackReserve
+	_top     *Frame //_top保存栈顶指针
 }
 
-func newStack(maxSize uint) *Stack {
+func newStack(maxBytes uint) *Stack {
 	return &Stack{
-		maxSize: maxSize,
+		maxBytes: maxBytes,
 	}
 }
 
 func (self *Stack) push(frame *Frame) {
-	if self.size >= self.maxSize {
-		panic("java.lang.StackOverflowError")
+	bytes := self.bytes + frame.size()
+	if bytes > self.maxBytes {
+		if !self.reserved {
+			self.reserved = true
+			panic(ErrStackOverflow)
+		}
+		if bytes > self.maxBytes+stackReserve {
+			panic(ErrStackReserveExhausted)
+		}
 	}
 	if self._top != nil {
 		frame.lower = self._top //frame称为新的栈顶
 	}
 	self._top = frame
+	self.bytes = bytes
 	self.size++
 }
 
@@ -32,9 +62,14 @@ func (self *Stack) pop() *Frame {
 		panic("jvm stack is empty")
 	}
 	top := self._top
+	top.exitMonitors()
 	self._top = top.lower
 	top.lower = nil
+	self.bytes -= top.size()
 	self.size--
+	if self.bytes <= self.maxBytes {
+		self.reserved = false
+	}
 	return top
 }
 
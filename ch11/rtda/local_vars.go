@@ -5,13 +5,23 @@ import (
 	"math"
 )
 
-type LocalVars []Slot //局部变量表就是Slot数组
+/*
+LocalVars 局部变量表，整数和引用分别放在nums和refs中，访问时不需要拆分和拼接
+long和double仍然占两个位置(和字节码中的索引一致)，值整个放在第一个位置
+*/
+type LocalVars struct {
+	nums []uint64
+	refs []*heap.Object
+}
 
 func newLocalVars(maxLocals uint) LocalVars {
 	if maxLocals > 0 {
-		return make([]Slot, maxLocals+1)
+		return LocalVars{
+			nums: make([]uint64, maxLocals),
+			refs: make([]*heap.Object, maxLocals),
+		}
 	}
-	return nil
+	return LocalVars{}
 }
 
 /*
@@ -19,51 +29,41 @@ func newLocalVars(maxLocals uint) LocalVars {
 */
 
 func (self LocalVars) SetInt(index uint, val int32) {
-	self[index].num = val
+	self.nums[index] = uint64(uint32(val))
+	self.refs[index] = nil //旧的引用不能再作为GC根
 }
 
 func (self LocalVars) GetInt(index uint) int32 {
-	return self[index].num
+	return int32(self.nums[index])
 }
 
 func (self LocalVars) SetFloat(index uint, val float32) {
-	bits := math.Float32bits(val)
-	self[index].num = int32(bits)
+	self.nums[index] = uint64(math.Float32bits(val))
+	self.refs[index] = nil
 }
 
 func (self LocalVars) GetFloat(index uint) float32 {
-	bits := uint32(self[index].num)
-	return math.Float32frombits(bits)
+	return math.Float32frombits(uint32(self.nums[index]))
 }
 
-/*
-long变量则需要拆成两个int变量
-*/
-
 func (self LocalVars) SetLong(index uint, val int64) {
-	self[index].num = int32(val)
-	self[index+1].num = int32(val >> 32)
+	self.nums[index] = uint64(val)
+	self.refs[index] = nil
+	self.refs[index+1] = nil
 }
 
 func (self LocalVars) GetLong(index uint) int64 {
-	low := uint32(self[index].num)
-
-	high := uint32(self[index+1].num)
-	return int64(high)<<32 | int64(low)
+	return int64(self.nums[index])
 }
 
-/*
-double变量可以先转型成long类型，然后按照long变量来处理
-*/
-
 func (self LocalVars) SetDouble(index uint, val float64) {
-	bits := math.Float64bits(val)
-	self.SetLong(index, int64(bits))
+	self.nums[index] = math.Float64bits(val)
+	self.refs[index] = nil
+	self.refs[index+1] = nil
 }
 
 func (self LocalVars) GetDouble(index uint) float64 {
-	bits := uint64(self.GetLong(index))
-	return math.Float64frombits(bits)
+	return math.Float64frombits(self.nums[index])
 }
 
 /*
@@ -71,15 +71,20 @@ func (self LocalVars) GetDouble(index uint) float64 {
 */
 
 func (self LocalVars) SetRef(index uint, ref *heap.Object) {
-	self[index].ref = ref
+	self.refs[index] = ref
 }
 
 func (self LocalVars) GetRef(index uint) *heap.Object {
-	return self[index].ref
+	return self.refs[index]
 }
 
 func (self LocalVars) SetSlot(index uint, slot Slot) {
-	self[index] = slot
+	self.nums[index] = slot.num
+	self.refs[index] = slot.ref
+}
+
+func (self LocalVars) GetSlot(index uint) Slot {
+	return Slot{num: self.nums[index], ref: self.refs[index]}
 }
 
 // GetThis 封装了GetRef(0) 返回当前对象
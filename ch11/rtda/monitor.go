@@ -0,0 +1,134 @@
+package rtda
+
+import "jvmgo/ch11/rtda/heap"
+
+/*
+监视器
+线程不会被抢占，但是在synchronized中wait/sleep/yield时其他线程会执行，所以仍然要真正地加锁
+被锁住的对象在Runtime.monitors中有一项，记录持有锁的线程和重入次数，锁完全释放时删除
+得不到锁的线程停止执行，锁被释放后由调度器交给它，见Thread.runnable()
+*/
+
+type monitor struct {
+	owner *Thread
+	count int //重入次数
+}
+
+// MonitorEnter 进入obj的监视器，锁被其他线程持有时当前线程停止执行，直到得到锁为止
+func (self *Thread) MonitorEnter(obj *heap.Object) {
+	if !self.acquire(obj, 1) {
+		self.entering = obj
+		self.enterCount = 1
+	}
+}
+
+// MonitorExit 退出obj的监视器，当前线程没有持有它时返回false
+func (self *Thread) MonitorExit(obj *heap.Object) bool {
+	m := self.runtime.monitors[obj]
+	if m == nil || m.owner != self {
+		return false
+	}
+	m.count--
+	if m.count == 0 {
+		delete(self.runtime.monitors, obj)
+	}
+	return true
+}
+
+// HoldsLock 线程是否持有obj的监视器，对应Thread.holdsLock()
+func (self *Thread) HoldsLock(obj *heap.Object) bool {
+	m := self.runtime.monitors[obj]
+	return m != nil && m.owner == self
+}
+
+// EnteringMonitor 线程正在等待进入的监视器，包括wait()被唤醒之后要重新进入的监视器
+func (self *Thread) EnteringMonitor() *heap.Object {
+	return self.entering
+}
+
+// 锁空闲或者已经被当前线程持有时得到锁，重入次数加上count
+func (self *Thread) acquire(obj *heap.Object, count int) bool {
+	m := self.runtime.monitors[obj]
+	if m == nil {
+		self.runtime.monitors[obj] = &monitor{owner: self, count: count}
+		return true
+	}
+	if m.owner == self {
+		m.count += count
+		return true
+	}
+	return false
+}
+
+// releaseAll 完全释放obj的监视器，返回释放之前的重入次数，没有持有时返回0
+func (self *Thread) releaseAll(obj *heap.Object) int {
+	m := self.runtime.monitors[obj]
+	if m == nil || m.owner != self {
+		return 0
+	}
+	delete(self.runtime.monitors, obj)
+	return m.count
+}
+
+/*
+EnterMethod 帧执行第一条指令之前调用，同步方法在这时进入监视器，静态方法锁住类对象，实例方法锁住this
+返回false表示锁被其他线程持有，线程已经停止执行
+*/
+func (self *Frame) EnterMethod() bool {
+	if self.entered {
+		return true
+	}
+	self.entered = true
+	if !self.method.IsSynchronized() {
+		return true
+	}
+	if self.method.IsStatic() {
+		self.syncLock = self.method.Class().JClass()
+	} else {
+		self.syncLock = self.localVars.GetThis()
+	}
+	self.thread.MonitorEnter(self.syncLock)
+	return self.thread.entering == nil
+}
+
+// EnterMonitor monitorenter时进入监视器并记录下来，同一个对象可以进入多次
+func (self *Frame) EnterMonitor(obj *heap.Object) {
+	self.thread.MonitorEnter(obj)
+	self.monitors = append(self.monitors, obj)
+}
+
+// ExitMonitor monitorexit时退出监视器，去掉最近一次进入的记录，当前线程没有持有它时返回false
+func (self *Frame) ExitMonitor(obj *heap.Object) bool {
+	if !self.thread.MonitorExit(obj) {
+		return false
+	}
+	for i := len(self.monitors) - 1; i >= 0; i-- {
+		if self.monitors[i] == obj {
+			self.monitors = append(self.monitors[:i], self.monitors[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Monitors 帧通过monitorenter持有的监视器，不包括同步方法本身的锁
+func (self *Frame) Monitors() []*heap.Object {
+	return self.monitors
+}
+
+// SyncLock 同步方法持有的监视器，其他方法返回nil
+func (self *Frame) SyncLock() *heap.Object {
+	return self.syncLock
+}
+
+// exitMonitors 帧被弹出时(正常返回、异常或者清空栈)释放它还持有的所有监视器
+func (self *Frame) exitMonitors() {
+	for _, obj := range self.monitors {
+		self.thread.MonitorExit(obj)
+	}
+	self.monitors = nil
+	if self.syncLock != nil {
+		self.thread.MonitorExit(self.syncLock)
+		self.syncLock = nil
+	}
+}
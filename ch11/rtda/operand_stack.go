@@ -5,89 +5,102 @@ import (
 	"math"
 )
 
+// OperandStack 操作数栈，和局部变量表一样把整数和引用分开存放，long和double占两个位置，值放在第一个位置
 type OperandStack struct {
-	size  uint   //size记录栈顶
-	slots []Slot //栈底层用数组实现
+	size uint           //size记录栈顶
+	nums []uint64       //栈底层用数组实现
+	refs []*heap.Object //和nums一一对应
 }
 
 func newOperandStack(maxStack uint) *OperandStack {
 	if maxStack > 0 {
 		return &OperandStack{
-			slots: make([]Slot, maxStack),
+			nums: make([]uint64, maxStack),
+			refs: make([]*heap.Object, maxStack),
 		}
 	}
 	return nil
 }
 
 func (self *OperandStack) PushInt(val int32) {
-	self.slots[self.size].num = val
+	self.nums[self.size] = uint64(uint32(val))
+	self.refs[self.size] = nil //清掉之前留下的引用，否则会被当作GC根
 	self.size++
 }
 
 func (self *OperandStack) PopInt() int32 {
 	self.size--
-	return self.slots[self.size].num
+	self.refs[self.size] = nil
+	return int32(self.nums[self.size])
 }
 
 func (self *OperandStack) PushFloat(val float32) {
-	bits := math.Float32bits(val)
-	self.slots[self.size].num = int32(bits)
+	self.nums[self.size] = uint64(math.Float32bits(val))
+	self.refs[self.size] = nil
 	self.size++
 }
 
 func (self *OperandStack) PopFloat() float32 {
 	self.size--
-	bits := uint32(self.slots[self.size].num)
-	return math.Float32frombits(bits)
+	self.refs[self.size] = nil
+	return math.Float32frombits(uint32(self.nums[self.size]))
 }
 
 func (self *OperandStack) PushLong(val int64) {
-	self.slots[self.size].num = int32(val)
-	self.slots[self.size+1].num = int32(val >> 32)
+	self.nums[self.size] = uint64(val)
+	self.refs[self.size] = nil
+	self.refs[self.size+1] = nil
 	self.size += 2
 }
 
 func (self *OperandStack) PopLong() int64 {
 	self.size -= 2
-	low := uint32(self.slots[self.size].num)
-	high := uint32(self.slots[self.size+1].num)
-	return int64(high)<<32 | int64(low)
+	self.refs[self.size] = nil
+	self.refs[self.size+1] = nil
+	return int64(self.nums[self.size])
 }
 
 func (self *OperandStack) PushDouble(val float64) {
-	bits := math.Float64bits(val)
-	self.PushLong(int64(bits))
+	self.nums[self.size] = math.Float64bits(val)
+	self.refs[self.size] = nil
+	self.refs[self.size+1] = nil
+	self.size += 2
 }
 
 func (self *OperandStack) PopDouble() float64 {
-	bits := uint64(self.PopLong())
-	return math.Float64frombits(bits)
+	self.size -= 2
+	self.refs[self.size] = nil
+	self.refs[self.size+1] = nil
+	return math.Float64frombits(self.nums[self.size])
 }
 
 func (self *OperandStack) PushRef(ref *heap.Object) {
-	self.slots[self.size].ref = ref
+	self.refs[self.size] = ref
 	self.size++
 }
 
 func (self *OperandStack) PopRef() *heap.Object {
 	self.size--
-	ref := self.slots[self.size].ref
-	self.slots[self.size].ref = nil
+	ref := self.refs[self.size]
+	self.refs[self.size] = nil
 	return ref
 }
 
 func (self *OperandStack) PushSlot(slot Slot) {
-	self.slots[self.size] = slot
+	self.nums[self.size] = slot.num
+	self.refs[self.size] = slot.ref
 	self.size++
 }
 
 func (self *OperandStack) PopSlot() Slot {
 	self.size--
-	return self.slots[self.size]
+	slot := Slot{num: self.nums[self.size], ref: self.refs[self.size]}
+	self.refs[self.size] = nil
+	return slot
 }
 
 func (self *OperandStack) GetRefFromTop(n uint) *heap.Object {
-	return self.slots[self.size-1-n].ref
+	return self.refs[self.size-1-n]
 }
 
 func (self *OperandStack) PushBoolean(val bool) {
@@ -103,7 +116,35 @@ func (self *OperandStack) PopBoolean() bool {
 
 func (self *OperandStack) Clear() {
 	self.size = 0
-	for i := range self.slots {
-		self.slots[i].ref = nil
+	for i := range self.refs {
+		self.refs[i] = nil
+	}
+}
+
+// Slots 返回栈中的所有slot，栈底在前，调试器用它显示操作数栈
+func (self *OperandStack) Slots() []Slot {
+	if self == nil {
+		return nil //maxStack为0
+	}
+	slots := make([]Slot, self.size)
+	for i := range slots {
+		slots[i] = Slot{num: self.nums[i], ref: self.refs[i]}
+	}
+	return slots
+}
+
+// Top 把栈顶的n个位置当作局部变量表读取，比如方法返回之前读取返回值
+func (self *OperandStack) Top(n uint) LocalVars {
+	return LocalVars{
+		nums: self.nums[self.size-n : self.size],
+		refs: self.refs[self.size-n : self.size],
+	}
+}
+
+// liveRefs 栈中的引用，垃圾回收时作为根
+func (self *OperandStack) liveRefs() []*heap.Object {
+	if self == nil {
+		return nil
 	}
+	return self.refs[:self.size]
 }
@@ -0,0 +1,97 @@
+package rtda
+
+import (
+	"io"
+	"jvmgo/ch11/rtda/heap"
+)
+
+/*
+Runtime 保存一个虚拟机实例的运行时状态：类加载器、本地方法表、标准输出以及所有线程
+线程通过它找到所属的虚拟机，同一个进程中可以有多个互不影响的Runtime
+*/
+type Runtime struct {
+	loader     *heap.ClassLoader
+	natives    map[string]func(frame *Frame)
+	stdout     io.Writer
+	stderr     io.Writer
+	threads    []*Thread //已经启动并且还没有结束的线程，调度相关的方法见scheduler.go
+	halted     bool      //Runtime.halt()被调用后虚拟机立即停止
+	deadlocked bool      //所有线程都在无限期等待，见NextThread()
+	exitStatus int       //halt时的进程退出码
+	stackSize  uint      //新线程的栈大小，单位是字节
+	//所有线程都在等待时，调度器在空闲循环中调用idleHook，见SetIdleHook()
+	idleHook func()
+	wakeup   chan struct{} //Wakeup()通过它唤醒在NextThread()中睡眠的解释器
+	//被锁住的对象，见monitor.go
+	monitors map[*heap.Object]*monitor
+	//垃圾回收，见gc.go
+	verboseGC   bool
+	rootSources []func() []*heap.Object
+	//终结，见finalizer.go
+	finalizer           *Thread        //Finalizer线程，第一次需要终结对象时启动
+	finalizerIdle       bool           //Finalizer线程在等待新的对象
+	finalizees          []*heap.Object //等待调用finalize()的对象
+	finalizationWaiters []*Thread      //在Runtime.runFinalization()中等待的线程
+}
+
+func NewRuntime(loader *heap.ClassLoader, stdout, stderr io.Writer) *Runtime {
+	self := &Runtime{
+		loader:  loader,
+		natives: map[string]func(frame *Frame){},
+		stdout:  stdout,
+		stderr:  stderr,
+
+		stackSize: DefaultStackSize,
+		wakeup:    make(chan struct{}, 1),
+		monitors:  map[*heap.Object]*monitor{},
+	}
+	loader.Allocator().SetCollector(self.collect)
+	self.RegisterNative("~shim", "<finalizer>", "()V", runFinalizer)
+	return self
+}
+
+func (self *Runtime) NewThread() *Thread {
+	return &Thread{
+		runtime:   self,
+		stack:     newStack(self.stackSize),
+		hashState: self.loader.NewHashState(),
+	}
+}
+
+// SetStackSize 设置之后创建的线程的栈大小，对应-Xss
+func (self *Runtime) SetStackSize(bytes uint) {
+	self.stackSize = bytes
+}
+
+func (self *Runtime) Loader() *heap.ClassLoader {
+	return self.loader
+}
+
+func (self *Runtime) Stdout() io.Writer {
+	return self.stdout
+}
+
+func (self *Runtime) Stderr() io.Writer {
+	return self.stderr
+}
+
+// RegisterNative 注册本地方法，类名，方法名和方法描述符唯一性地确定一个方法
+func (self *Runtime) RegisterNative(className, methodName, methodDescriptor string, method func(frame *Frame)) {
+	key := className + "~" + methodName + "~" + methodDescriptor
+	self.natives[key] = method
+}
+
+func (self *Runtime) FindNativeMethod(className, methodName, methodDescriptor string) func(frame *Frame) {
+	key := className + "~" + methodName + "~" + methodDescriptor
+	if method, ok := self.natives[key]; ok {
+		return method
+	}
+	if methodDescriptor == "()V" && methodName == "registerNatives" {
+		return emptyNativeMethod
+	}
+	return nil
+}
+
+func emptyNativeMethod(frame *Frame) {
+	// do nothing
+}
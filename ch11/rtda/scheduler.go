@@ -0,0 +1,296 @@
+package rtda
+
+import (
+	"jvmgo/ch11/rtda/heap"
+	"time"
+)
+
+/*
+线程调度
+所有Java线程都在同一个Go协程中轮流执行，线程只会在等待(wait/join/sleep/yield)或者执行结束时让出执行权，不会被抢占
+*/
+
+// java.lang.Thread.threadStatus的取值，和HotSpot的JVMTI线程状态一致
+const (
+	THREAD_STATUS_NEW        = 0
+	THREAD_STATUS_RUNNABLE   = 0x0005 // ALIVE | RUNNABLE
+	THREAD_STATUS_TERMINATED = 0x0002
+)
+
+// Start 把线程加入调度
+func (self *Thread) Start() {
+	self.alive = true
+	self.runtime.threads = append(self.runtime.threads, self)
+}
+
+func (self *Thread) IsAlive() bool {
+	return self.alive
+}
+
+/*
+Wait 释放obj的监视器并在obj上等待，millis为0表示一直等到被notify
+被唤醒之后要重新得到监视器并恢复原来的重入次数才能继续执行，当前线程没有持有监视器时返回false
+*/
+func (self *Thread) Wait(obj *heap.Object, millis int64) bool {
+	count := self.releaseAll(obj)
+	if count == 0 {
+		return false
+	}
+	self.block(obj, millis)
+	self.entering = obj
+	self.enterCount = count
+	return true
+}
+
+func (self *Thread) Sleep(millis int64) {
+	self.block(nil, millis)
+}
+
+// Yield 让出执行权，但是线程仍然可以被调度
+func (self *Thread) Yield() {
+	self.yielded = true
+}
+
+func (self *Thread) block(obj *heap.Object, millis int64) {
+	self.blocked = true
+	self.waitingOn = obj
+	self.wakeAt = time.Time{}
+	if millis > 0 {
+		self.wakeAt = time.Now().Add(time.Duration(millis) * time.Millisecond)
+	}
+}
+
+func (self *Thread) wake() {
+	self.blocked = false
+	self.waitingOn = nil
+	self.wakeAt = time.Time{}
+}
+
+/*
+Interrupt 设置中断标志，正在wait()或者sleep()的线程被唤醒
+这时本地方法已经返回了，所以只记下来，等线程恢复执行时由解释器抛出InterruptedException，中断标志被清除
+*/
+func (self *Thread) Interrupt() {
+	if self.blocked {
+		self.interruptWait = true
+		if self.waitingOn == nil {
+			self.interruptEx = "sleep interrupted"
+		} else {
+			self.interruptEx = "" //和HotSpot一样，wait()抛出的异常没有消息
+		}
+		self.wake()
+		return
+	}
+	self.interrupted = true
+}
+
+// TakeWaitInterrupt 线程是否在wait()或者sleep()中被中断了，返回InterruptedException的消息
+func (self *Thread) TakeWaitInterrupt() (message string, ok bool) {
+	if !self.interruptWait {
+		return "", false
+	}
+	self.interruptWait = false
+	return self.interruptEx, true
+}
+
+func (self *Thread) IsInterrupted(clear bool) bool {
+	interrupted := self.interrupted
+	if clear {
+		self.interrupted = false
+	}
+	return interrupted
+}
+
+// IsWaiting 线程正在wait()、join()或者sleep()
+func (self *Thread) IsWaiting() bool {
+	return self.blocked
+}
+
+// WaitingOn wait()或者join()等待的对象，sleep()时为nil
+func (self *Thread) WaitingOn() *heap.Object {
+	return self.waitingOn
+}
+
+// HasTimeout 等待是否有超时时间
+func (self *Thread) HasTimeout() bool {
+	return !self.wakeAt.IsZero()
+}
+
+// IsBlocked 线程正在等待、等待进入监视器或者刚刚让出执行权，需要切换线程
+func (self *Thread) IsBlocked() bool {
+	return self.blocked || self.yielded || self.entering != nil
+}
+
+func (self *Thread) runnable(now time.Time) bool {
+	if self.blocked && !self.wakeAt.IsZero() && !now.Before(self.wakeAt) {
+		self.wake() //等待超时
+	}
+	if self.blocked {
+		return false
+	}
+	if self.entering != nil { //锁被释放之后才能继续执行
+		if !self.acquire(self.entering, self.enterCount) {
+			return false
+		}
+		self.entering = nil
+	}
+	return true
+}
+
+func (self *Thread) isDaemon() bool {
+	return self.daemon || self.jThread != nil && self.jThread.GetIntVar("daemon", "Z") != 0
+}
+
+// Terminate 从调度中移除已经执行完的线程，比如从Go代码调用Java方法的线程
+func (self *Thread) Terminate() {
+	if self.alive {
+		self.terminate()
+	}
+}
+
+// 线程结束，唤醒所有在join()中等待它的线程
+func (self *Thread) terminate() {
+	self.alive = false
+	threads := self.runtime.threads
+	for i, t := range threads {
+		if t == self {
+			self.runtime.threads = append(threads[:i], threads[i+1:]...)
+			break
+		}
+	}
+	if self.jThread != nil {
+		self.jThread.SetIntVar("threadStatus", "I", THREAD_STATUS_TERMINATED)
+		self.runtime.NotifyAll(self.jThread)
+	}
+}
+
+// Notify 唤醒一个在obj上等待的线程
+func (self *Runtime) Notify(obj *heap.Object) {
+	for _, t := range self.threads {
+		if t.blocked && t.waitingOn == obj {
+			t.wake()
+			return
+		}
+	}
+}
+
+// NotifyAll 唤醒所有在obj上等待的线程
+func (self *Runtime) NotifyAll(obj *heap.Object) {
+	for _, t := range self.threads {
+		if t.blocked && t.waitingOn == obj {
+			t.wake()
+		}
+	}
+}
+
+/*
+NextThread 当前线程结束或者让出执行权之后，选出下一个要执行的线程
+从当前线程之后开始轮流查找，所有线程都在等待时睡眠到最早的超时时间
+返回nil表示已经没有非守护线程需要执行，或者所有线程都在无限期等待，后一种情况Deadlocked()返回true
+*/
+func (self *Runtime) NextThread(current *Thread) *Thread {
+	current.yielded = false
+	self.deadlocked = false
+	if current.alive && current.IsStackEmpty() {
+		current.terminate()
+	}
+
+	for !self.halted && self.HasLiveThreads() {
+		now := time.Now()
+		threads := self.threads
+		start := 0
+		for i, t := range threads {
+			if t == current {
+				start = i + 1
+				break
+			}
+		}
+		for i := range threads {
+			t := threads[(start+i)%len(threads)]
+			if t.runnable(now) {
+				return t
+			}
+		}
+
+		wakeAt := self.earliestWakeAt()
+		if wakeAt.IsZero() {
+			self.deadlocked = true //没有线程能唤醒它们
+			return nil
+		}
+		self.idle(wakeAt.Sub(now))
+	}
+	return nil
+}
+
+// idle 所有线程都在等待，睡眠d或者直到被Wakeup()唤醒，睡眠之前调用idleHook
+func (self *Runtime) idle(d time.Duration) {
+	if self.idleHook != nil {
+		self.idleHook()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-self.wakeup:
+	}
+}
+
+/*
+SetIdleHook 设置所有线程都在等待时调用的函数，比如处理线程转储的请求
+这时没有执行Java代码，hook在解释器所在的goroutine中执行
+*/
+func (self *Runtime) SetIdleHook(hook func()) {
+	self.idleHook = hook
+}
+
+// Wakeup 唤醒在NextThread()中睡眠的解释器，让它再调用一次idleHook，可以在任何goroutine中调用
+func (self *Runtime) Wakeup() {
+	select {
+	case self.wakeup <- struct{}{}:
+	default: //已经有一个唤醒请求了
+	}
+}
+
+func (self *Runtime) earliestWakeAt() time.Time {
+	var wakeAt time.Time
+	for _, t := range self.threads {
+		if t.blocked && !t.wakeAt.IsZero() && (wakeAt.IsZero() || t.wakeAt.Before(wakeAt)) {
+			wakeAt = t.wakeAt
+		}
+	}
+	return wakeAt
+}
+
+// Deadlocked 上次NextThread()返回nil是不是因为所有线程都在无限期等待
+func (self *Runtime) Deadlocked() bool {
+	return self.deadlocked
+}
+
+// HasLiveThreads 是否还有没结束的非守护线程
+func (self *Runtime) HasLiveThreads() bool {
+	for _, t := range self.threads {
+		if !t.isDaemon() {
+			return true
+		}
+	}
+	return false
+}
+
+// AllThreads 返回所有存活的线程
+func (self *Runtime) AllThreads() []*Thread {
+	return self.threads
+}
+
+// Halt 对应Runtime.halt()，立即停止所有线程
+func (self *Runtime) Halt(status int) {
+	self.halted = true
+	self.exitStatus = status
+	for _, t := range self.threads {
+		t.ClearStack()
+	}
+}
+
+// Halted 返回虚拟机是否已经停止以及退出码
+func (self *Runtime) Halted() (bool, int) {
+	return self.halted, self.exitStatus
+}
@@ -3,10 +3,19 @@ package rtda
 import "jvmgo/ch11/rtda/heap"
 
 /*
-自定义数组，该数组中的元素既可以存放整数，也可以存放引用
+Slot 局部变量表或者操作数栈中的一个位置，既可以存放整数，也可以存放引用
+局部变量表和操作数栈本身把整数和引用分开存放，Slot只在不知道类型时整体复制(比如传递参数、dup指令)时使用
 */
 
 type Slot struct {
-	num int32        //num字段存放整数
+	num uint64       //num字段存放整数，long和double的值整个放在两个位置中的第一个
 	ref *heap.Object //ref字段存放引用
 }
+
+func (self Slot) Num() int32 {
+	return int32(self.num)
+}
+
+func (self Slot) Ref() *heap.Object {
+	return self.ref
+}
@@ -1,26 +1,60 @@
 package rtda
 
-import "jvmgo/ch11/rtda/heap"
+import (
+	"jvmgo/ch11/rtda/heap"
+	"time"
+)
 
 type Thread struct {
-	pc    int    //pc程序计数器
-	stack *Stack //虚拟机栈
-}
-
-func NewThread() *Thread {
-	return &Thread{
-		stack: newStack(1024), //指定要创建的栈最大可以容纳1024帧，可以修改命令行工具，添加选项来指定这个参数
-	}
+	runtime       *Runtime        //线程所属的虚拟机
+	pc            int             //pc程序计数器
+	stack         *Stack          //虚拟机栈
+	hashState     *heap.HashState //线程私有的identity hash code生成状态
+	jThread       *heap.Object    //对应的java.lang.Thread对象
+	uncaughtEx    *heap.Object    //导致线程终止的未捕获异常
+	alive         bool            //已经启动并且还没有结束，调度相关的字段见scheduler.go
+	blocked       bool            //正在wait()或者sleep()
+	yielded       bool            //刚刚调用过Thread.yield()
+	waitingOn     *heap.Object    //wait()等待的对象
+	wakeAt        time.Time       //等待超时的时间，零值表示没有超时
+	interrupted   bool            //中断标志
+	interruptEx   string          //在wait()或者sleep()中被中断时，恢复执行后要抛出的InterruptedException的消息
+	interruptWait bool            //interruptEx有效
+	entering      *heap.Object    //等待进入的监视器，见monitor.go
+	enterCount    int             //得到entering的锁之后的重入次数
+	name          string          //没有Thread对象的虚拟机内部线程(比如Finalizer)的名字
+	daemon        bool            //虚拟机内部的守护线程
 }
 
 /*
 getter
 */
 
+func (self *Thread) Runtime() *Runtime {
+	return self.runtime
+}
+
 func (self *Thread) PC() int {
 	return self.pc
 }
 
+func (self *Thread) HashState() *heap.HashState {
+	return self.hashState
+}
+
+// Name 虚拟机内部线程的名字，其他线程返回空字符串
+func (self *Thread) Name() string {
+	return self.name
+}
+
+func (self *Thread) JThread() *heap.Object {
+	return self.jThread
+}
+
+func (self *Thread) UncaughtException() *heap.Object {
+	return self.uncaughtEx
+}
+
 /*
 setter
 */
@@ -29,6 +63,14 @@ func (self *Thread) SetPC(pc int) {
 	self.pc = pc
 }
 
+func (self *Thread) SetJThread(jThread *heap.Object) {
+	self.jThread = jThread
+}
+
+func (self *Thread) SetUncaughtException(ex *heap.Object) {
+	self.uncaughtEx = ex
+}
+
 func (self *Thread) PushFrame(frame *Frame) {
 	self.stack.push(frame) //调用虚拟机栈对应的方法即可
 }
@@ -54,8 +96,14 @@ func (self *Thread) IsStackEmpty() bool {
 
 func (self *Thread) ClearStack() {
 	self.stack.clear()
+	self.entering = nil
 }
 
 func (self *Thread) GetFrames() []*Frame {
 	return self.stack.getFrames()
 }
+
+// StackDepth 虚拟机栈中的帧数
+func (self *Thread) StackDepth() uint {
+	return self.stack.size
+}
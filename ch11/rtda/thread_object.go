@@ -0,0 +1,85 @@
+package rtda
+
+import (
+	"jvmgo/ch11/rtda/heap"
+	"unicode/utf16"
+)
+
+const maxPriority = 10
+const normPriority = 5
+
+// EnsureJThread 返回线程对应的java.lang.Thread对象，主线程的对象在第一次用到时才创建
+func (self *Thread) EnsureJThread(loader *heap.ClassLoader) *heap.Object {
+	if self.jThread == nil {
+		jThread := createMainThreadObject(loader)
+		jThread.SetExtra(self)
+		self.jThread = jThread
+	}
+	return self.jThread
+}
+
+/*
+HotSpot在启动时为主线程创建 system 和 main 两个线程组以及名为main的Thread对象
+这里不执行它们的构造函数(依赖大量本地方法)，而是直接给字段赋值
+*/
+func createMainThreadObject(loader *heap.ClassLoader) *heap.Object {
+	systemGroup := newThreadGroup(loader, nil, "system")
+	mainGroup := newThreadGroup(loader, systemGroup, "main")
+
+	jThread := loader.LoadClass("java/lang/Thread").NewObject()
+	jThread.SetRefVar("group", "Ljava/lang/ThreadGroup;", mainGroup)
+	setThreadName(jThread, "main")
+	jThread.SetIntVar("priority", "I", normPriority)
+	jThread.SetIntVar("threadStatus", "I", THREAD_STATUS_RUNNABLE)
+
+	addToArray(mainGroup, "threads", "[Ljava/lang/Thread;", "nthreads", jThread)
+	return jThread
+}
+
+func newThreadGroup(loader *heap.ClassLoader, parent *heap.Object, name string) *heap.Object {
+	group := loader.LoadClass("java/lang/ThreadGroup").NewObject()
+	group.SetRefVar("name", "Ljava/lang/String;", heap.JString(loader, name))
+	group.SetIntVar("maxPriority", "I", maxPriority)
+	if parent != nil {
+		group.SetRefVar("parent", "Ljava/lang/ThreadGroup;", parent)
+		addToArray(parent, "groups", "[Ljava/lang/ThreadGroup;", "ngroups", group)
+	}
+	return group
+}
+
+// ThreadGroup用一个数组和一个计数器记录其中的线程和子线程组
+func addToArray(group *heap.Object, arrName, arrDescriptor, countName string, elem *heap.Object) {
+	loader := group.Class().Loader()
+	count := group.GetIntVar(countName, "I")
+	arr := group.GetRefVar(arrName, arrDescriptor)
+	if arr == nil || int(count) >= int(arr.ArrayLength()) {
+		newArr := loader.LoadClass(arrDescriptor).NewArray(uint(count)*2 + 4)
+		if arr != nil {
+			heap.ArrayCopy(arr, newArr, 0, 0, count)
+		}
+		arr = newArr
+		group.SetRefVar(arrName, arrDescriptor, arr)
+	}
+	arr.Refs()[count] = elem
+	group.SetIntVar(countName, "I", count+1)
+}
+
+// JDK8的Thread.name是String，更早的版本是char[]
+func setThreadName(jThread *heap.Object, name string) {
+	loader := jThread.Class().Loader()
+	jName := heap.JString(loader, name)
+	if jThread.Class().GetInstanceField("name", "Ljava/lang/String;") != nil {
+		jThread.SetRefVar("name", "Ljava/lang/String;", jName)
+	} else {
+		jThread.SetRefVar("name", "[C", jName.GetRefVar("value", "[C"))
+	}
+}
+
+// ThreadName 返回Java线程的名字
+func ThreadName(jThread *heap.Object) string {
+	if jThread.Class().GetInstanceField("name", "Ljava/lang/String;") != nil {
+		return heap.GoString(jThread.GetRefVar("name", "Ljava/lang/String;"))
+	}
+	chars := jThread.GetRefVar("name", "[C")
+	return string(utf16.Decode(chars.Chars()))
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"jvmgo/ch11/vm"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySignals 和HotSpot一样，收到SIGQUIT(kill -3 <pid>)时打印所有线程的栈，
+// 另外收到SIGUSR1(kill -USR1 <pid>)时转储堆，返回的函数停止监听
+func notifySignals(jvm *vm.VM) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGQUIT, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				if sig == syscall.SIGQUIT {
+					jvm.RequestThreadDump()
+				} else {
+					jvm.RequestHeapDump()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "jvmgo/ch11/vm"
+
+// notifySignals Windows没有SIGQUIT和SIGUSR1，不支持通过信号转储线程和堆
+func notifySignals(jvm *vm.VM) (stop func()) {
+	return func() {}
+}
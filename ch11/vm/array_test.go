@@ -0,0 +1,122 @@
+package vm_test
+
+import (
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/vm"
+)
+
+func TestArrayAccessExceptionsAreCatchable(t *testing.T) {
+	// static int load(boolean isNull, int i) {
+	//     try { int[] a = isNull ? null : new int[1]; return a[i]; }
+	//     catch (NullPointerException e) { return -1; }
+	//     catch (ArrayIndexOutOfBoundsException e) { return -2; }
+	// }
+	// store(boolean, int) 一样，只是把a[i] = 7之后返回a[0]
+	arrays := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Arrays", "java/lang/Object")
+	for _, store := range []bool{false, true} {
+		name := "load"
+		if store {
+			name = "store"
+		}
+		mb := arrays.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, name, "(ZI)I")
+		start, end, notNull, access := mb.NewLabel(), mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+		npe, aioobe := mb.NewLabel(), mb.NewLabel()
+		mb.Mark(start)
+		mb.VarInsn(asm.ILOAD, 0)
+		mb.JumpInsn(asm.IFEQ, notNull)
+		mb.Insn(asm.ACONST_NULL)
+		mb.JumpInsn(asm.GOTO, access)
+		mb.Mark(notNull)
+		mb.Insn(asm.ICONST_1)
+		mb.IntInsn(asm.NEWARRAY, asm.T_INT)
+		mb.Mark(access)
+		if store {
+			mb.Insn(asm.DUP)
+			mb.VarInsn(asm.ILOAD, 1)
+			mb.IntInsn(asm.BIPUSH, 7)
+			mb.Insn(asm.IASTORE)
+			mb.Insn(asm.ICONST_0)
+		} else {
+			mb.VarInsn(asm.ILOAD, 1)
+		}
+		mb.Insn(asm.IALOAD)
+		mb.Mark(end)
+		mb.Insn(asm.IRETURN)
+		mb.Mark(npe)
+		mb.Insn(asm.POP)
+		mb.Insn(asm.ICONST_M1)
+		mb.Insn(asm.IRETURN)
+		mb.Mark(aioobe)
+		mb.Insn(asm.POP)
+		mb.IntInsn(asm.BIPUSH, -2)
+		mb.Insn(asm.IRETURN)
+		mb.TryCatch(start, end, npe, "java/lang/NullPointerException")
+		mb.TryCatch(start, end, aioobe, "java/lang/ArrayIndexOutOfBoundsException")
+	}
+
+	jvm := asmtest.NewVM(t, vm.Options{}, arrays,
+		asmtest.Exception("java/lang/NullPointerException"),
+		asmtest.Exception("java/lang/ArrayIndexOutOfBoundsException"))
+	tests := []struct {
+		method string
+		isNull bool
+		index  int
+		want   int32
+	}{
+		{"load", false, 0, 0},
+		{"load", true, 0, -1},
+		{"load", false, 1, -2},
+		{"load", false, -1, -2},
+		{"store", false, 0, 7},
+		{"store", true, 0, -1},
+		{"store", false, 1, -2},
+	}
+	for _, tt := range tests {
+		result, err := jvm.InvokeStatic("Arrays", tt.method, "(ZI)I", tt.isNull, tt.index)
+		if err != nil {
+			t.Fatalf("%s(%v, %d): %v", tt.method, tt.isNull, tt.index, err)
+		}
+		if result != tt.want {
+			t.Errorf("%s(%v, %d) = %v, want %d", tt.method, tt.isNull, tt.index, result, tt.want)
+		}
+	}
+}
+
+func TestIntrinsicKeepsAccessFlags(t *testing.T) {
+	// Arrays.fill(int[], int)的字节码什么也不做，结果来自Go实现
+	// static int fill(int v) { int[] a = new int[3]; Arrays.fill(a, v); return a[2]; }
+	arrays := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "java/util/Arrays", "java/lang/Object")
+	arrays.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "fill", "([II)V").Insn(asm.RETURN)
+	main := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Main", "java/lang/Object")
+	mb := main.AddMethod(asm.ACC_STATIC, "fill", "(I)I")
+	mb.Insn(asm.ICONST_3)
+	mb.IntInsn(asm.NEWARRAY, asm.T_INT)
+	mb.VarInsn(asm.ASTORE, 1)
+	mb.VarInsn(asm.ALOAD, 1)
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.MethodInsn(asm.INVOKESTATIC, "java/util/Arrays", "fill", "([II)V")
+	mb.VarInsn(asm.ALOAD, 1)
+	mb.Insn(asm.ICONST_2)
+	mb.Insn(asm.IALOAD)
+	mb.Insn(asm.IRETURN)
+
+	jvm := asmtest.NewVM(t, vm.Options{}, arrays, main)
+	result, err := jvm.InvokeStatic("Main", "fill", "(I)I", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != int32(42) {
+		t.Errorf("fill(42) = %v, want 42", result)
+	}
+	class, err := jvm.LoadClass("java/util/Arrays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill := class.GetStaticMethod("fill", "([II)V")
+	if !fill.IsIntrinsic() || fill.IsNative() || fill.AccessFlags() != asm.ACC_PUBLIC|asm.ACC_STATIC {
+		t.Errorf("Arrays.fill: intrinsic=%v native=%v flags=%#x", fill.IsIntrinsic(), fill.IsNative(), fill.AccessFlags())
+	}
+}
@@ -0,0 +1,275 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"jvmgo/ch11/javap"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"strconv"
+	"strings"
+)
+
+/*
+-Xdebug 交互式的字节码调试器
+解释器执行每条指令之前调用BeforeInstruction()，命中断点或者单步执行结束时停下来读取命令
+虚拟机启动后停在第一条指令上，可以先设置断点再继续执行
+*/
+
+const debuggerHelp = `break <class>.<method>[(descriptor)]:<line>  在某一行设置断点
+break <class>.<method>[(descriptor)]@<pc>    在某条指令设置断点
+delete [n]          删除断点n，不指定n时删除所有断点
+breakpoints         列出所有断点
+step                执行一条指令，会进入被调用的方法
+next                执行一条指令，不进入被调用的方法
+out                 执行到当前方法返回
+continue            继续执行到下一个断点
+locals              打印局部变量
+stack               打印操作数栈
+where               打印虚拟机栈
+print <var>         打印局部变量的值，var可以是变量名、this或者slot编号
+fields <var>        打印局部变量引用的对象的字段(数组打印元素)
+statics <class>     打印类的静态变量
+quit                退出调试器，程序继续执行
+空行重复上一条命令，命令可以用第一个字母缩写(stack用st)
+`
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepInto          // 任何方法的下一条指令
+	stepOver          // 当前方法或者调用者的下一条指令
+	stepOut           // 当前方法返回之后的第一条指令
+)
+
+type breakpoint struct {
+	id         int
+	className  string // 用/分隔
+	methodName string
+	descriptor string // 为空时匹配所有重载的方法
+	line       int    // 为-1时按pc匹配
+	pc         int
+}
+
+type Debugger struct {
+	vm          *VM
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints []*breakpoint
+	nextId      int
+	mode        stepMode
+	stepThread  *rtda.Thread //单步执行只看开始单步时的线程
+	stepDepth   uint         //开始单步时的栈深度
+	lastCommand string
+}
+
+func newDebugger(vm *VM, in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		vm:     vm,
+		in:     bufio.NewScanner(in),
+		out:    out,
+		nextId: 1,
+		mode:   stepInto, //停在第一条指令
+	}
+}
+
+// BeforeInstruction 在执行pc处的指令之前调用
+func (self *Debugger) BeforeInstruction(frame *rtda.Frame, opcode uint8) {
+	method := frame.Method()
+	if method.IsShim() {
+		return
+	}
+	thread := frame.Thread()
+	pc := thread.PC()
+	if bp := self.findBreakpoint(method, pc); bp != nil {
+		fmt.Fprintf(self.out, "Breakpoint %d hit\n", bp.id)
+	} else if !self.stepDone(thread) {
+		return
+	}
+	self.mode = stepNone
+	fmt.Fprintf(self.out, "%s #%d %s\n", location(method, pc), pc, javap.Mnemonic(opcode))
+	self.commandLoop(frame)
+}
+
+func (self *Debugger) stepDone(thread *rtda.Thread) bool {
+	switch self.mode {
+	case stepInto:
+		return self.stepThread == nil || thread == self.stepThread
+	case stepOver:
+		return thread == self.stepThread && thread.StackDepth() <= self.stepDepth
+	case stepOut:
+		return thread == self.stepThread && thread.StackDepth() < self.stepDepth
+	}
+	return false
+}
+
+func (self *Debugger) findBreakpoint(method *heap.Method, pc int) *breakpoint {
+	for _, bp := range self.breakpoints {
+		if bp.methodName != method.Name() || bp.className != method.Class().Name() ||
+			bp.descriptor != "" && bp.descriptor != method.Descriptor() {
+			continue
+		}
+		if bp.line < 0 {
+			if bp.pc == pc {
+				return bp
+			}
+		} else if method.GetLineNumber(pc) == bp.line &&
+			(pc == 0 || method.GetLineNumber(pc-1) != bp.line) { //只在进入这一行时停下
+			return bp
+		}
+	}
+	return nil
+}
+
+// commandLoop 读取并执行命令，直到遇到继续执行的命令
+func (self *Debugger) commandLoop(frame *rtda.Frame) {
+	thread := frame.Thread()
+	for {
+		fmt.Fprint(self.out, "> ")
+		if !self.in.Scan() {
+			self.detach() //输入结束
+			return
+		}
+		line := strings.TrimSpace(self.in.Text())
+		if line == "" {
+			line = self.lastCommand
+		}
+		self.lastCommand = line
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "c", "continue":
+			return
+		case "s", "step":
+			self.startStep(stepInto, thread)
+			return
+		case "n", "next":
+			self.startStep(stepOver, thread)
+			return
+		case "o", "out":
+			self.startStep(stepOut, thread)
+			return
+		case "b", "break":
+			self.addBreakpoint(args)
+		case "d", "delete":
+			self.deleteBreakpoint(args)
+		case "breakpoints":
+			self.listBreakpoints()
+		case "l", "locals":
+			self.printLocals(frame)
+		case "st", "stack":
+			self.printOperandStack(frame)
+		case "w", "where":
+			printFrames(self.out, thread)
+		case "p", "print":
+			self.printVar(frame, args, false)
+		case "f", "fields":
+			self.printVar(frame, args, true)
+		case "statics":
+			self.printStatics(args)
+		case "h", "help":
+			fmt.Fprint(self.out, debuggerHelp)
+		case "q", "quit":
+			self.detach()
+			return
+		default:
+			fmt.Fprintf(self.out, "unknown command: %s (help for help)\n", cmd)
+		}
+	}
+}
+
+func (self *Debugger) startStep(mode stepMode, thread *rtda.Thread) {
+	self.mode = mode
+	self.stepThread = thread
+	self.stepDepth = thread.StackDepth()
+}
+
+func (self *Debugger) detach() {
+	fmt.Fprintln(self.out, "debugger detached")
+	self.vm.SetDebugHook(nil)
+}
+
+// addBreakpoint 解析Foo.bar:12、java.lang.String.length@3或者Foo.bar(I)V:12
+func (self *Debugger) addBreakpoint(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(self.out, "usage: break <class>.<method>[(descriptor)]:<line> or @<pc>")
+		return
+	}
+	spec := args[0]
+	bp := &breakpoint{line: -1}
+	var err error
+	if i := strings.LastIndexAny(spec, ":@"); i > 0 {
+		var n int
+		n, err = strconv.Atoi(spec[i+1:])
+		if spec[i] == ':' {
+			bp.line = n
+		} else {
+			bp.pc = n
+		}
+		spec = spec[:i]
+	} else {
+		err = fmt.Errorf("missing :<line> or @<pc>")
+	}
+	if i := strings.IndexByte(spec, '('); i >= 0 {
+		bp.descriptor = spec[i:]
+		spec = spec[:i]
+	}
+	dot := strings.LastIndexByte(spec, '.')
+	if err != nil || dot <= 0 || dot == len(spec)-1 {
+		fmt.Fprintf(self.out, "bad breakpoint: %s\n", args[0])
+		return
+	}
+	bp.className = strings.Replace(spec[:dot], ".", "/", -1)
+	bp.methodName = spec[dot+1:]
+	bp.id = self.nextId
+	self.nextId++
+	self.breakpoints = append(self.breakpoints, bp)
+	fmt.Fprintf(self.out, "Breakpoint %d at %s\n", bp.id, bp)
+}
+
+func (self *Debugger) deleteBreakpoint(args []string) {
+	if len(args) == 0 {
+		self.breakpoints = nil
+		return
+	}
+	id, _ := strconv.Atoi(args[0])
+	for i, bp := range self.breakpoints {
+		if bp.id == id {
+			self.breakpoints = append(self.breakpoints[:i], self.breakpoints[i+1:]...)
+			return
+		}
+	}
+	fmt.Fprintf(self.out, "no breakpoint %s\n", args[0])
+}
+
+func (self *Debugger) listBreakpoints() {
+	if len(self.breakpoints) == 0 {
+		fmt.Fprintln(self.out, "no breakpoints")
+	}
+	for _, bp := range self.breakpoints {
+		fmt.Fprintf(self.out, "%d: %s\n", bp.id, bp)
+	}
+}
+
+func (self *breakpoint) String() string {
+	s := strings.Replace(self.className, "/", ".", -1) + "." + self.methodName + self.descriptor
+	if self.line >= 0 {
+		return fmt.Sprintf("%s:%d", s, self.line)
+	}
+	return fmt.Sprintf("%s@%d", s, self.pc)
+}
+
+// location 和异常栈信息一样的格式：Foo.bar(Foo.java:12)
+func location(method *heap.Method, pc int) string {
+	class := method.Class()
+	s := class.JavaName() + "." + method.Name()
+	if line := method.GetLineNumber(pc); line >= 0 && class.SourceFile() != "" {
+		return fmt.Sprintf("%s(%s:%d)", s, class.SourceFile(), line)
+	}
+	return s + method.Descriptor()
+}
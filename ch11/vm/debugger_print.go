@@ -0,0 +1,221 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"strconv"
+	"strings"
+)
+
+// 调试器打印局部变量、操作数栈、对象的字段和类的静态变量
+
+const maxPrintedElements = 20 // 数组最多打印的元素个数
+
+// printFrames 从栈顶开始打印每一帧的方法和nextPC
+func printFrames(w io.Writer, thread *rtda.Thread) {
+	for _, frame := range thread.GetFrames() {
+		method := frame.Method()
+		className := method.Class().Name()
+		fmt.Fprintf(w, ">> pc:%4d %v.%v%v \n", frame.NextPC(), className, method.Name(), method.Descriptor())
+	}
+}
+
+// printLocals 有LocalVariableTable时按名字打印，否则按slot打印原始值
+func (self *Debugger) printLocals(frame *rtda.Frame) {
+	method := frame.Method()
+	vars := frame.LocalVars()
+	if lvs := method.LocalVariables(frame.Thread().PC()); lvs != nil {
+		for _, lv := range lvs {
+			fmt.Fprintf(self.out, "%s = %s\n", lv.Name(), formatLocal(vars, lv.Index(), lv.Descriptor()))
+		}
+		return
+	}
+	for i := uint(0); i < method.MaxLocals(); i++ {
+		fmt.Fprintf(self.out, "slot %d = %s\n", i, formatSlot(vars.GetSlot(i)))
+	}
+}
+
+func (self *Debugger) printOperandStack(frame *rtda.Frame) {
+	slots := frame.OperandStack().Slots()
+	if len(slots) == 0 {
+		fmt.Fprintln(self.out, "<empty>")
+	}
+	for i := len(slots) - 1; i >= 0; i-- { //栈顶在前
+		fmt.Fprintf(self.out, "[%d] %s\n", i, formatSlot(slots[i]))
+	}
+}
+
+// printVar 打印局部变量的值，或者它引用的对象的字段
+func (self *Debugger) printVar(frame *rtda.Frame, args []string, printFields bool) {
+	if len(args) != 1 {
+		fmt.Fprintln(self.out, "usage: print|fields <name|this|slot>")
+		return
+	}
+	method := frame.Method()
+	vars := frame.LocalVars()
+	value, ref, ok := "", (*heap.Object)(nil), false
+	if args[0] == "this" && !method.IsStatic() {
+		ref, ok = vars.GetThis(), true
+		value = formatRef(ref)
+	} else if slot, err := strconv.Atoi(args[0]); err == nil {
+		if slot >= 0 && uint(slot) < method.MaxLocals() {
+			ref, ok = vars.GetRef(uint(slot)), true
+			value = formatSlot(vars.GetSlot(uint(slot)))
+		}
+	} else {
+		for _, lv := range method.LocalVariables(frame.Thread().PC()) {
+			if lv.Name() == args[0] {
+				ref, ok = vars.GetRef(lv.Index()), true
+				value = formatLocal(vars, lv.Index(), lv.Descriptor())
+			}
+		}
+	}
+	if !ok {
+		fmt.Fprintf(self.out, "no local variable %s\n", args[0])
+	} else if !printFields {
+		fmt.Fprintln(self.out, value)
+	} else if ref == nil {
+		fmt.Fprintln(self.out, "not an object")
+	} else {
+		self.printObject(ref)
+	}
+}
+
+func (self *Debugger) printObject(obj *heap.Object) {
+	class := obj.Class()
+	fmt.Fprintln(self.out, formatRef(obj))
+	if class.IsArray() {
+		self.printElements(obj)
+		return
+	}
+	for c := class; c != nil; c = c.SuperClass() {
+		for _, field := range c.Fields() {
+			if !field.IsStatic() {
+				fmt.Fprintf(self.out, "  %s.%s = %s\n", c.JavaName(), field.Name(),
+					formatField(obj.Fields(), field.SlotId(), field.Descriptor()))
+			}
+		}
+	}
+}
+
+func (self *Debugger) printElements(arr *heap.Object) {
+	n := int(arr.ArrayLength())
+	if n > maxPrintedElements {
+		n = maxPrintedElements
+	}
+	for i := 0; i < n; i++ {
+		var s string
+		switch arr.Class().Name()[1] {
+		case 'Z':
+			s = strconv.FormatBool(arr.Bytes()[i] != 0)
+		case 'B':
+			s = strconv.Itoa(int(arr.Bytes()[i]))
+		case 'C':
+			s = strconv.QuoteRune(rune(arr.CharAt(int32(i))))
+		case 'S':
+			s = strconv.Itoa(int(arr.Shorts()[i]))
+		case 'I':
+			s = strconv.Itoa(int(arr.Ints()[i]))
+		case 'J':
+			s = strconv.FormatInt(arr.Longs()[i], 10)
+		case 'F':
+			s = fmt.Sprint(arr.Floats()[i])
+		case 'D':
+			s = fmt.Sprint(arr.Doubles()[i])
+		default:
+			s = formatRef(arr.Refs()[i])
+		}
+		fmt.Fprintf(self.out, "  [%d] = %s\n", i, s)
+	}
+	if int(arr.ArrayLength()) > n {
+		fmt.Fprintf(self.out, "  ... %d more\n", int(arr.ArrayLength())-n)
+	}
+}
+
+// printStatics 只打印已经加载的类，不会因为调试而加载或者初始化类
+func (self *Debugger) printStatics(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(self.out, "usage: statics <class>")
+		return
+	}
+	className := strings.Replace(args[0], ".", "/", -1)
+	class := self.vm.loader.FindLoadedClass(className)
+	if class == nil {
+		fmt.Fprintf(self.out, "class %s is not loaded\n", args[0])
+		return
+	}
+	if !class.InitStarted() {
+		fmt.Fprintf(self.out, "class %s is not initialized\n", args[0])
+	}
+	for _, field := range class.Fields() {
+		if field.IsStatic() {
+			fmt.Fprintf(self.out, "%s = %s\n", field.Name(),
+				formatField(class.StaticVars(), field.SlotId(), field.Descriptor()))
+		}
+	}
+}
+
+func formatLocal(vars rtda.LocalVars, index uint, descriptor string) string {
+	switch descriptor {
+	case "Z":
+		return strconv.FormatBool(vars.GetInt(index) != 0)
+	case "C":
+		return strconv.QuoteRune(rune(uint16(vars.GetInt(index))))
+	case "B", "S", "I":
+		return strconv.Itoa(int(vars.GetInt(index)))
+	case "J":
+		return strconv.FormatInt(vars.GetLong(index), 10)
+	case "F":
+		return fmt.Sprint(vars.GetFloat(index))
+	case "D":
+		return fmt.Sprint(vars.GetDouble(index))
+	}
+	return formatRef(vars.GetRef(index))
+}
+
+func formatField(slots heap.Slots, index uint, descriptor string) string {
+	switch descriptor {
+	case "Z":
+		return strconv.FormatBool(slots.GetInt(index) != 0)
+	case "C":
+		return strconv.QuoteRune(rune(uint16(slots.GetInt(index))))
+	case "B", "S", "I":
+		return strconv.Itoa(int(slots.GetInt(index)))
+	case "J":
+		return strconv.FormatInt(slots.GetLong(index), 10)
+	case "F":
+		return fmt.Sprint(slots.GetFloat(index))
+	case "D":
+		return fmt.Sprint(slots.GetDouble(index))
+	}
+	return formatRef(slots.GetRef(index))
+}
+
+// formatSlot 不知道类型时，引用打印对象，否则打印整数(long和double打印第一个位置中的低32位)
+func formatSlot(slot rtda.Slot) string {
+	if slot.Ref() != nil {
+		return formatRef(slot.Ref())
+	}
+	return strconv.Itoa(int(slot.Num()))
+}
+
+// formatRef 字符串打印内容，类对象打印类名，其他对象打印类名和地址
+func formatRef(obj *heap.Object) string {
+	if obj == nil {
+		return "null"
+	}
+	class := obj.Class()
+	switch {
+	case class.Name() == "java/lang/String" && obj.GetRefVar("value", "[C") != nil:
+		return strconv.Quote(heap.GoString(obj))
+	case class.Name() == "java/lang/Class":
+		if c, ok := obj.Extra().(*heap.Class); ok {
+			return "class " + c.JavaName()
+		}
+	case class.IsArray():
+		return fmt.Sprintf("%s[%d]@%p", class.ComponentClass().JavaName(), obj.ArrayLength(), obj)
+	}
+	return fmt.Sprintf("%s@%p", class.JavaName(), obj)
+}
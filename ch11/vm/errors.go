@@ -0,0 +1,32 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/instructions/references"
+	"jvmgo/ch11/rtda/heap"
+)
+
+// JavaException 被调用的Java方法抛出了没有捕获的异常
+type JavaException struct {
+	Object *heap.Object // 异常对象，是java.lang.Throwable的实例
+}
+
+// Error 和Throwable.toString()一样，返回异常类名和消息
+func (self *JavaException) Error() string {
+	return references.ThrowableToString(self.Object)
+}
+
+// PrintStackTrace 按照Throwable.printStackTrace()的格式打印异常
+func (self *JavaException) PrintStackTrace(w io.Writer) {
+	references.PrintStackTrace(w, self.Object)
+}
+
+// ExitError 虚拟机已经因为System.exit()或者Runtime.halt()停止
+type ExitError struct {
+	Status int
+}
+
+func (self *ExitError) Error() string {
+	return fmt.Sprintf("vm halted with status %d", self.Status)
+}
@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"fmt"
+	"jvmgo/ch11/hprof"
+	"jvmgo/ch11/rtda"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+/*
+堆转储，写出HPROF格式的文件，见hprof包
+和HotSpot一样，-XX:+HeapDumpOnOutOfMemoryError在第一次抛出OutOfMemoryError时转储
+另外可以随时调用RequestHeapDump()请求转储(比如收到SIGUSR1时)，转储在解释器执行下一条指令之前进行
+*/
+
+const athrowOpcode = 0xbf
+
+// heapDumpFile 转储文件的路径，默认是当前目录下的java_pid<pid>.hprof，HeapDumpPath是目录时放在这个目录下
+// 第n次(从0开始)转储在文件名后面加上.n，不覆盖之前的文件
+func (self *VM) heapDumpFile() string {
+	name := fmt.Sprintf("java_pid%d.hprof", os.Getpid())
+	path := self.heapDumpPath
+	if path == "" {
+		path = name
+	} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, name)
+	}
+	if self.heapDumps > 0 {
+		path = fmt.Sprintf("%s.%d", path, self.heapDumps)
+	}
+	self.heapDumps++
+	return path
+}
+
+// DumpHeap 把堆写到path中，必须在没有执行Java代码时或者在执行Java代码的goroutine中调用
+func (self *VM) DumpHeap(path string) error {
+	_, err := hprof.DumpHeap(path, self.runtime)
+	return err
+}
+
+// RequestHeapDump 请求转储堆，可以在任何goroutine中调用，解释器执行下一条指令之前写到HeapDumpPath
+func (self *VM) RequestHeapDump() {
+	atomic.StoreInt32(&self.heapDumpRequested, 1)
+}
+
+// checkHeapDump 解释器执行每条指令之前调用，处理转储请求和OutOfMemoryError
+func (self *VM) checkHeapDump(frame *rtda.Frame, opcode uint8) {
+	if atomic.CompareAndSwapInt32(&self.heapDumpRequested, 1, 0) {
+		self.writeHeapDump()
+	}
+	if opcode == athrowOpcode && self.heapDumpOnOOM && !self.oomDumped {
+		ex := frame.OperandStack().GetRefFromTop(0)
+		oomClass := self.loader.FindLoadedClass("java/lang/OutOfMemoryError")
+		if ex != nil && oomClass != nil && ex.IsInstanceOf(oomClass) {
+			self.oomDumped = true
+			self.writeHeapDump()
+		}
+	}
+}
+
+// writeHeapDump 和HotSpot一样在标准输出打印转储的文件名和大小
+func (self *VM) writeHeapDump() {
+	stdout := self.runtime.Stdout()
+	path := self.heapDumpFile()
+	fmt.Fprintf(stdout, "Dumping heap to %s ...\n", path)
+	start := time.Now()
+	size, err := hprof.DumpHeap(path, self.runtime)
+	if err != nil {
+		fmt.Fprintf(stdout, "Unable to create %s: %v\n", path, err)
+		return
+	}
+	fmt.Fprintf(stdout, "Heap dump file created [%d bytes in %.3f secs]\n", size, time.Since(start).Seconds())
+}
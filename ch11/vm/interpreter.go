@@ -0,0 +1,196 @@
+package vm
+
+import (
+	"fmt"
+	"jvmgo/ch11/instructions"
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"strings"
+)
+
+// 解释器
+
+// RunMain 执行类的main方法，等所有非守护线程结束后返回进程的退出码
+func (self *VM) RunMain(className string, args []string) int {
+	className = strings.Replace(className, ".", "/", -1)
+	mainClass := self.loader.LoadClass(className)
+	mainMethod := mainClass.GetMainMethod() //获得Main方法
+	if mainMethod == nil {
+		fmt.Fprintf(self.runtime.Stdout(), "Main method not found in class %s\n",
+			strings.Replace(className, "/", ".", -1))
+		return 1
+	}
+
+	thread := self.runtime.NewThread()
+	frame := thread.NewFrame(mainMethod)
+	thread.PushFrame(frame)
+	jArgs := self.createArgsArray(args)
+	frame.LocalVars().SetRef(0, jArgs)
+	thread.Start()
+	self.loop(thread, nil)
+	if halted, status := self.runtime.Halted(); halted {
+		return status //System.exit()或者Runtime.halt()
+	}
+	if self.runtime.Deadlocked() {
+		//HotSpot会一直等下去，这里打印所有线程的栈之后退出
+		stderr := self.runtime.Stderr()
+		fmt.Fprintln(stderr, "Deadlock: all non-daemon threads are waiting forever")
+		self.DumpThreads(stderr)
+		return 1
+	}
+	self.shutdown(thread)
+	if halted, status := self.runtime.Halted(); halted {
+		return status
+	}
+	if thread.UncaughtException() != nil {
+		return 1 //主线程因未捕获的异常终止
+	}
+	return 0
+}
+
+// 所有非守护线程结束后，如果Shutdown类已经被加载(比如注册了关闭钩子)，在主线程上执行Shutdown.shutdown()
+func (self *VM) shutdown(thread *rtda.Thread) {
+	class := self.loader.FindLoadedClass("java/lang/Shutdown")
+	if class == nil {
+		return
+	}
+	method := class.GetStaticMethod("shutdown", "()V")
+	if method == nil {
+		return
+	}
+	thread.PushFrame(thread.NewFrame(method))
+	if !class.InitStarted() {
+		base.InitClass(thread, class)
+	}
+	thread.Start()
+	self.loop(thread, nil)
+}
+
+func (self *VM) createArgsArray(args []string) *heap.Object {
+	stringClass := self.loader.LoadClass("java/lang/String")
+
+	argsArr := stringClass.ArrayClass().NewArray(uint(len(args)))
+	jArgs := argsArr.Refs()
+	for i, arg := range args {
+		jArgs[i] = heap.JString(self.loader, arg)
+	}
+	return argsArr
+}
+
+/*
+loop 轮流执行所有线程，直到没有非守护线程需要执行、虚拟机停止或者done()返回true
+没有结束的线程留在调度中，下次调用loop时继续执行
+*/
+func (self *VM) loop(thread *rtda.Thread, done func() bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if done == nil {
+				self.logFrames(thread) //从Go代码调用方法时由调用者把panic转换成error，不打印
+			}
+			panic(r)
+		}
+	}()
+
+	for !self.interpret(&thread, done) {
+	}
+}
+
+/*
+interpret 执行指令，直到loop应该结束时返回true
+分配的对象超过堆的最大大小时会panic(heap.ErrOutOfMemory)，栈溢出时会panic(rtda.ErrStackOverflow)
+处理StackOverflowError时又溢出会panic(rtda.ErrStackReserveExhausted)，这时停止虚拟机
+堆空间不够时在执行下一条指令之前的安全点回收，回收之后仍然放不下也会panic(heap.ErrOutOfMemory)
+这时在当前线程抛出OutOfMemoryError或者StackOverflowError并返回false，由loop继续执行，其他panic交给loop处理
+*/
+func (self *VM) interpret(current **rtda.Thread, done func() bool) bool {
+	defer func() {
+		if r := recover(); r != nil {
+			frame := (*current).CurrentFrame()
+			switch r {
+			case heap.ErrOutOfMemory:
+				base.ThrowException(frame, "java/lang/OutOfMemoryError", "Java heap space")
+			case rtda.ErrStackOverflow:
+				base.ThrowException(frame, "java/lang/StackOverflowError", "")
+			case rtda.ErrStackReserveExhausted:
+				//无法再抛出异常，和HotSpot的致命错误一样停止虚拟机，Halt()清空所有线程的栈
+				fmt.Fprintf(self.runtime.Stderr(), "Fatal error: %v in thread \"%s\"\n", r, threadName(*current))
+				self.runtime.Halt(1)
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	reader := &base.BytecodeReader{}
+	for {
+		thread := *current
+		if done != nil && done() {
+			return true
+		}
+		if thread.IsStackEmpty() || thread.IsBlocked() {
+			//当前线程结束或者让出执行权，切换到下一个线程
+			if *current = self.runtime.NextThread(thread); *current == nil {
+				return true
+			}
+			continue
+		}
+		if message, ok := thread.TakeWaitInterrupt(); ok {
+			//在wait()或者sleep()中被中断，恢复执行时在本地方法的帧中抛出InterruptedException
+			base.ThrowException(thread.CurrentFrame(), "java/lang/InterruptedException", message)
+			continue
+		}
+		if err := self.loader.Allocator().Safepoint(); err != nil {
+			panic(err)
+		}
+
+		frame := thread.CurrentFrame()
+		if !frame.EnterMethod() {
+			continue //同步方法的锁被其他线程持有
+		}
+		pc := frame.NextPC()
+		thread.SetPC(pc)
+
+		//decode
+		reader.Reset(frame.Method().Code(), pc)
+		opcode := reader.ReadUint8()
+		inst := instructions.NewInstruction(opcode) //根据操作码得到对应的指令
+		inst.FetchOperands(reader)                  //指令去操作数
+		frame.SetNextPC(reader.PC())
+		self.checkHeapDump(frame, opcode)
+		self.checkThreadDump()
+		if self.debugHook != nil {
+			self.debugHook.BeforeInstruction(frame, opcode)
+		}
+		if self.profiler != nil {
+			self.profiler.beforeInstruction(frame, opcode)
+		}
+		if self.tracer != nil {
+			self.tracer.beforeInstruction(frame, opcode)
+		}
+		if self.verboseInst {
+			self.logInstruction(frame, inst)
+		}
+
+		//execute
+		inst.Execute(frame)
+		if self.tracer != nil {
+			self.tracer.afterInstruction(thread)
+		}
+	}
+}
+
+// 打印虚拟机栈信息
+func (self *VM) logFrames(thread *rtda.Thread) {
+	printFrames(self.runtime.Stdout(), thread)
+	thread.ClearStack()
+}
+
+// 在方法执行的过程中打印指令信息
+func (self *VM) logInstruction(frame *rtda.Frame, inst base.Instruction) {
+	method := frame.Method()
+	className := method.Class().Name()
+	methodName := method.Name()
+	pc := frame.Thread().PC()
+	fmt.Fprintf(self.runtime.Stdout(), "%v.%v() #%2d %T %v\n", className, methodName, pc, inst, inst)
+}
@@ -0,0 +1,45 @@
+package vm_test
+
+import (
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"jvmgo/ch11/vm"
+)
+
+// 在shim帧(没有类加载器)中等待的线程被中断，InterruptedException由虚拟机的类加载器加载
+func TestInterruptThreadParkedInShimFrame(t *testing.T) {
+	// static void run() { Thread.yield(); interruptParked(); Thread.yield(); }
+	main := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Main", "java/lang/Object")
+	main.AddMethod(asm.ACC_STATIC|asm.ACC_NATIVE, "interruptParked", "()V")
+	mb := main.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "run", "()V")
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "yield", "()V")
+	mb.MethodInsn(asm.INVOKESTATIC, "Main", "interruptParked", "()V")
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "yield", "()V")
+	mb.Insn(asm.RETURN)
+
+	jvm := asmtest.NewVM(t, vm.Options{}, asmtest.Thread(), main, asmtest.Exception("java/lang/InterruptedException"))
+
+	// 和Finalizer线程一样，栈底是<finalizer>帧，没有需要终结的对象时等待
+	parked := jvm.Runtime().NewThread()
+	parked.PushFrame(parked.NewFrame(heap.ShimFinalizerMethod()))
+	parked.Start()
+	jvm.RegisterNative("Main", "interruptParked", "()V", func(frame *rtda.Frame) {
+		if !parked.IsWaiting() {
+			t.Error("thread is not parked in the <finalizer> frame")
+		}
+		parked.Interrupt()
+	})
+
+	if _, err := jvm.InvokeStatic("Main", "run", "()V"); err != nil {
+		t.Fatal(err)
+	}
+	//<finalizer>帧捕获了InterruptedException，线程又开始等待
+	if !parked.IsAlive() || !parked.IsWaiting() {
+		t.Errorf("alive = %v, waiting = %v, want the thread parked again", parked.IsAlive(), parked.IsWaiting())
+	}
+	parked.Terminate()
+}
@@ -0,0 +1,253 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"jvmgo/ch11/instructions/base"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"reflect"
+)
+
+/*
+从Go代码调用Java方法
+参数按照方法描述符从Go值转换成Java值：
+	Z        bool
+	B C S I  任意整数类型
+	J        任意整数类型
+	F        float32或者float64
+	D        float64或者float32
+	引用类型  *heap.Object、nil，或者string(转换成java.lang.String)
+返回值反过来转换：Z->bool B->int8 C->uint16 S->int16 I->int32 J->int64 F->float32 D->float64
+引用类型返回*heap.Object，void方法返回nil
+*/
+
+// InvokeStatic 调用静态方法，类还没有初始化时先执行<clinit>
+func (self *VM) InvokeStatic(className, methodName, descriptor string, args ...interface{}) (interface{}, error) {
+	class, err := self.LoadClass(className)
+	if err != nil {
+		return nil, err
+	}
+	method := class.GetStaticMethod(methodName, descriptor)
+	if method == nil {
+		return nil, fmt.Errorf("java.lang.NoSuchMethodError: %s.%s%s", class.JavaName(), methodName, descriptor)
+	}
+	return self.call(method, nil, args)
+}
+
+// Invoke 调用实例方法，和invokevirtual一样根据obj的实际类型查找方法
+func (self *VM) Invoke(obj *heap.Object, methodName, descriptor string, args ...interface{}) (interface{}, error) {
+	if obj == nil {
+		return nil, errors.New("java.lang.NullPointerException")
+	}
+	method := heap.LookupMethodInClass(obj.Class(), methodName, descriptor)
+	if method == nil || method.IsStatic() {
+		return nil, fmt.Errorf("java.lang.NoSuchMethodError: %s.%s%s", obj.Class().JavaName(), methodName, descriptor)
+	}
+	if method.IsAbstract() {
+		return nil, fmt.Errorf("java.lang.AbstractMethodError: %s.%s%s", obj.Class().JavaName(), methodName, descriptor)
+	}
+	return self.call(method, obj, args)
+}
+
+// NewObject 创建对象并执行构造函数，ctorDescriptor是构造函数的描述符，比如"()V"
+func (self *VM) NewObject(className, ctorDescriptor string, args ...interface{}) (*heap.Object, error) {
+	class, err := self.LoadClass(className)
+	if err != nil {
+		return nil, err
+	}
+	if class.IsInterface() || class.IsAbstract() {
+		return nil, fmt.Errorf("java.lang.InstantiationError: %s", class.JavaName())
+	}
+	constructor := class.GetConstructor(ctorDescriptor)
+	if constructor == nil {
+		return nil, fmt.Errorf("java.lang.NoSuchMethodError: %s.<init>%s", class.JavaName(), ctorDescriptor)
+	}
+	obj := class.NewObject()
+	if _, err := self.call(constructor, obj, args); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+/*
+call 在新线程上执行方法，直到方法返回或者抛出异常
+栈底是shim方法<call>的帧，方法返回后返回值留在它的操作数栈上，方法抛出的异常被它捕获
+执行期间其他Java线程也会被调度，方法返回时还没有结束的线程留到下次调用时继续执行
+*/
+func (self *VM) call(method *heap.Method, this *heap.Object, args []interface{}) (result interface{}, err error) {
+	if halted, status := self.runtime.Halted(); halted {
+		return nil, &ExitError{Status: status}
+	}
+	paramTypes := method.ParameterTypes()
+	if len(args) != len(paramTypes) {
+		return nil, fmt.Errorf("%s.%s%s: want %d arguments, got %d",
+			method.Class().JavaName(), method.Name(), method.Descriptor(), len(paramTypes), len(args))
+	}
+
+	thread := self.runtime.NewThread()
+	callFrame := thread.NewFrame(heap.ShimCallMethod())
+	callFrame.SetNextPC(1)
+	thread.PushFrame(callFrame)
+
+	frame := thread.NewFrame(method)
+	vars := frame.LocalVars()
+	slot := uint(0)
+	if !method.IsStatic() {
+		vars.SetRef(0, this)
+		slot++
+	}
+	for i, paramType := range paramTypes {
+		if err := self.setArg(vars, slot, paramType, args[i]); err != nil {
+			return nil, fmt.Errorf("%s.%s%s: argument %d: %v",
+				method.Class().JavaName(), method.Name(), method.Descriptor(), i, err)
+		}
+		if paramType == "J" || paramType == "D" {
+			slot += 2
+		} else {
+			slot++
+		}
+	}
+	thread.PushFrame(frame)
+	if class := method.Class(); !class.InitStarted() {
+		base.InitClass(thread, class)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			thread.ClearStack()
+			result = nil
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+		thread.Terminate()
+	}()
+	thread.Start()
+	self.loop(thread, func() bool {
+		return thread.IsStackEmpty() || thread.TopFrame() == callFrame
+	})
+
+	if halted, status := self.runtime.Halted(); halted {
+		return nil, &ExitError{Status: status}
+	}
+	if thread.IsStackEmpty() || thread.TopFrame() != callFrame {
+		return nil, errors.New("deadlock: all threads are waiting")
+	}
+	thread.PopFrame()
+	stack := callFrame.OperandStack()
+	if callFrame.NextPC() == 2 {
+		return nil, &JavaException{Object: stack.PopRef()}
+	}
+	return popResult(stack, method.ReturnType()), nil
+}
+
+func (self *VM) setArg(vars rtda.LocalVars, index uint, paramType string, arg interface{}) error {
+	switch paramType {
+	case "Z":
+		if b, ok := arg.(bool); ok {
+			if b {
+				vars.SetInt(index, 1)
+			} else {
+				vars.SetInt(index, 0)
+			}
+			return nil
+		}
+	case "B":
+		if i, ok := toInt64(arg); ok {
+			vars.SetInt(index, int32(int8(i)))
+			return nil
+		}
+	case "C":
+		if i, ok := toInt64(arg); ok {
+			vars.SetInt(index, int32(uint16(i)))
+			return nil
+		}
+	case "S":
+		if i, ok := toInt64(arg); ok {
+			vars.SetInt(index, int32(int16(i)))
+			return nil
+		}
+	case "I":
+		if i, ok := toInt64(arg); ok {
+			vars.SetInt(index, int32(i))
+			return nil
+		}
+	case "J":
+		if i, ok := toInt64(arg); ok {
+			vars.SetLong(index, i)
+			return nil
+		}
+	case "F":
+		if f, ok := toFloat64(arg); ok {
+			vars.SetFloat(index, float32(f))
+			return nil
+		}
+	case "D":
+		if f, ok := toFloat64(arg); ok {
+			vars.SetDouble(index, f)
+			return nil
+		}
+	default: // 引用类型
+		switch x := arg.(type) {
+		case nil:
+			vars.SetRef(index, nil)
+			return nil
+		case *heap.Object:
+			vars.SetRef(index, x)
+			return nil
+		case string:
+			vars.SetRef(index, heap.JString(self.loader, x))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use %T as %s", arg, paramType)
+}
+
+func toInt64(arg interface{}) (int64, bool) {
+	val := reflect.ValueOf(arg)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(val.Uint()), true
+	}
+	return 0, false
+}
+
+func toFloat64(arg interface{}) (float64, bool) {
+	switch x := arg.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+func popResult(stack *rtda.OperandStack, returnType string) interface{} {
+	switch returnType {
+	case "V":
+		return nil
+	case "Z":
+		return stack.PopInt() != 0
+	case "B":
+		return int8(stack.PopInt())
+	case "C":
+		return uint16(stack.PopInt())
+	case "S":
+		return int16(stack.PopInt())
+	case "I":
+		return stack.PopInt()
+	case "J":
+		return stack.PopLong()
+	case "F":
+		return stack.PopFloat()
+	case "D":
+		return stack.PopDouble()
+	default:
+		return stack.PopRef()
+	}
+}
@@ -0,0 +1,280 @@
+package vm_test
+
+import (
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/vm"
+)
+
+// monitorClasses 生成的类相当于：
+//
+//	class Counter {
+//	    static int count, finished; static Object lock = new Object();
+//	    static synchronized void addSync() { int c = count; Thread.yield(); count = c + 1; }
+//	    static void addBlock() { synchronized (lock) { int c = count; Thread.yield(); count = c + 1; } }
+//	    synchronized void boom() { throw new RuntimeException(); }
+//	}
+//	class SyncWorker extends Thread { public void run() { for (int i = 0; i < 20; i++) Counter.addSync(); Counter.finished++; } }
+//	class BlockWorker extends Thread { ... Counter.addBlock() ... }
+//	class Notifier extends Thread { public void run() { synchronized (Counter.lock) { Counter.finished = 1; Counter.lock.notify(); } } }
+func monitorClasses() []*asm.ClassBuilder {
+	counter := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Counter", "java/lang/Object")
+	counter.AddField(asm.ACC_STATIC, "count", "I")
+	counter.AddField(asm.ACC_STATIC, "finished", "I")
+	counter.AddField(asm.ACC_STATIC, "lock", "Ljava/lang/Object;")
+	clinit := counter.AddMethod(asm.ACC_STATIC, "<clinit>", "()V")
+	clinit.TypeInsn(asm.NEW, "java/lang/Object")
+	clinit.Insn(asm.DUP)
+	clinit.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	clinit.FieldInsn(asm.PUTSTATIC, "Counter", "lock", "Ljava/lang/Object;")
+	clinit.Insn(asm.RETURN)
+	init := counter.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	init.Insn(asm.RETURN)
+
+	// 读出count之后让出执行权，没有锁时其他线程的修改会被覆盖
+	increment := func(mb *asm.MethodBuilder) {
+		mb.FieldInsn(asm.GETSTATIC, "Counter", "count", "I")
+		mb.VarInsn(asm.ISTORE, 0)
+		mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "yield", "()V")
+		mb.VarInsn(asm.ILOAD, 0)
+		mb.Insn(asm.ICONST_1)
+		mb.Insn(asm.IADD)
+		mb.FieldInsn(asm.PUTSTATIC, "Counter", "count", "I")
+	}
+	addSync := counter.AddMethod(asm.ACC_STATIC|asm.ACC_SYNCHRONIZED, "addSync", "()V")
+	increment(addSync)
+	addSync.Insn(asm.RETURN)
+
+	addBlock := counter.AddMethod(asm.ACC_STATIC, "addBlock", "()V")
+	start, end, handler := addBlock.NewLabel(), addBlock.NewLabel(), addBlock.NewLabel()
+	addBlock.FieldInsn(asm.GETSTATIC, "Counter", "lock", "Ljava/lang/Object;")
+	addBlock.Insn(asm.DUP)
+	addBlock.VarInsn(asm.ASTORE, 1)
+	addBlock.Insn(asm.MONITORENTER)
+	addBlock.Mark(start)
+	increment(addBlock)
+	addBlock.VarInsn(asm.ALOAD, 1)
+	addBlock.Insn(asm.MONITOREXIT)
+	addBlock.Mark(end)
+	addBlock.Insn(asm.RETURN)
+	addBlock.Mark(handler)
+	addBlock.VarInsn(asm.ALOAD, 1)
+	addBlock.Insn(asm.MONITOREXIT)
+	addBlock.Insn(asm.ATHROW)
+	addBlock.TryCatch(start, end, handler, "")
+
+	boom := counter.AddMethod(asm.ACC_SYNCHRONIZED, "boom", "()V")
+	boom.TypeInsn(asm.NEW, "java/lang/RuntimeException")
+	boom.Insn(asm.DUP)
+	boom.MethodInsn(asm.INVOKESPECIAL, "java/lang/RuntimeException", "<init>", "()V")
+	boom.Insn(asm.ATHROW)
+
+	worker := func(name, method string) *asm.ClassBuilder {
+		cb := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, name, "java/lang/Thread")
+		init := cb.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+		init.Insn(asm.ALOAD_0)
+		init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Thread", "<init>", "()V")
+		init.Insn(asm.RETURN)
+		run := cb.AddMethod(asm.ACC_PUBLIC, "run", "()V")
+		loop, done := run.NewLabel(), run.NewLabel()
+		run.Insn(asm.ICONST_0)
+		run.VarInsn(asm.ISTORE, 1)
+		run.Mark(loop)
+		run.VarInsn(asm.ILOAD, 1)
+		run.IntInsn(asm.BIPUSH, 20)
+		run.JumpInsn(asm.IF_ICMPGE, done)
+		run.MethodInsn(asm.INVOKESTATIC, "Counter", method, "()V")
+		run.IincInsn(1, 1)
+		run.JumpInsn(asm.GOTO, loop)
+		run.Mark(done)
+		run.FieldInsn(asm.GETSTATIC, "Counter", "finished", "I")
+		run.Insn(asm.ICONST_1)
+		run.Insn(asm.IADD)
+		run.FieldInsn(asm.PUTSTATIC, "Counter", "finished", "I")
+		run.Insn(asm.RETURN)
+		return cb
+	}
+
+	notifier := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Notifier", "java/lang/Thread")
+	init = notifier.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.Insn(asm.ALOAD_0)
+	init.MethodInsn(asm.INVOKESPECIAL, "java/lang/Thread", "<init>", "()V")
+	init.Insn(asm.RETURN)
+	run := notifier.AddMethod(asm.ACC_PUBLIC, "run", "()V")
+	run.FieldInsn(asm.GETSTATIC, "Counter", "lock", "Ljava/lang/Object;")
+	run.Insn(asm.DUP)
+	run.VarInsn(asm.ASTORE, 1)
+	run.Insn(asm.MONITORENTER)
+	run.Insn(asm.ICONST_1)
+	run.FieldInsn(asm.PUTSTATIC, "Counter", "finished", "I")
+	run.FieldInsn(asm.GETSTATIC, "Counter", "lock", "Ljava/lang/Object;")
+	run.MethodInsn(asm.INVOKEVIRTUAL, "java/lang/Object", "notify", "()V")
+	run.VarInsn(asm.ALOAD, 1)
+	run.Insn(asm.MONITOREXIT)
+	run.Insn(asm.RETURN)
+
+	return []*asm.ClassBuilder{counter, worker("SyncWorker", "addSync"), worker("BlockWorker", "addBlock"), notifier,
+		asmtest.Thread(),
+		asmtest.Exception("java/lang/RuntimeException"),
+		asmtest.Exception("java/lang/NullPointerException"),
+		asmtest.Exception("java/lang/IllegalMonitorStateException")}
+}
+
+// raceMethod 生成静态方法name：启动两个workerClass线程，等它们都结束后返回Counter.count
+func raceMethod(cb *asm.ClassBuilder, name, workerClass string) {
+	mb := cb.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, name, "()I")
+	for i := 0; i < 2; i++ {
+		mb.TypeInsn(asm.NEW, workerClass)
+		mb.Insn(asm.DUP)
+		mb.MethodInsn(asm.INVOKESPECIAL, workerClass, "<init>", "()V")
+		mb.MethodInsn(asm.INVOKEVIRTUAL, workerClass, "start", "()V")
+	}
+	loop, done := mb.NewLabel(), mb.NewLabel()
+	mb.Mark(loop)
+	mb.FieldInsn(asm.GETSTATIC, "Counter", "finished", "I")
+	mb.Insn(asm.ICONST_2)
+	mb.JumpInsn(asm.IF_ICMPGE, done)
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "yield", "()V")
+	mb.JumpInsn(asm.GOTO, loop)
+	mb.Mark(done)
+	mb.FieldInsn(asm.GETSTATIC, "Counter", "count", "I")
+	mb.Insn(asm.IRETURN)
+}
+
+func TestSynchronizedExcludesOtherThreads(t *testing.T) {
+	for _, tt := range []struct{ method, worker string }{
+		{"raceSync", "SyncWorker"},   // ACC_SYNCHRONIZED方法
+		{"raceBlock", "BlockWorker"}, // monitorenter/monitorexit
+	} {
+		main := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Main", "java/lang/Object")
+		raceMethod(main, tt.method, tt.worker)
+		jvm := asmtest.NewVM(t, vm.Options{}, append(monitorClasses(), main)...)
+		result, err := jvm.InvokeStatic("Main", tt.method, "()I")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.method, err)
+		}
+		if result != int32(40) {
+			t.Errorf("%s = %v, want 40: increments were lost inside the critical section", tt.method, result)
+		}
+	}
+}
+
+// wait()释放监视器，Notifier才能进入同一个监视器调用notify()，wait()返回之前重新得到监视器
+func TestWaitReleasesMonitor(t *testing.T) {
+	// static boolean handshake() {
+	//     synchronized (Counter.lock) { new Notifier().start(); while (Counter.finished == 0) Counter.lock.wait(0); }
+	//     ... 返回之前仍然持有锁：Thread.holdsLock(Counter.lock)
+	// }
+	main := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Main", "java/lang/Object")
+	mb := main.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "handshake", "()Z")
+	loop, done := mb.NewLabel(), mb.NewLabel()
+	mb.FieldInsn(asm.GETSTATIC, "Counter", "lock", "Ljava/lang/Object;")
+	mb.Insn(asm.DUP)
+	mb.VarInsn(asm.ASTORE, 0)
+	mb.Insn(asm.MONITORENTER)
+	mb.TypeInsn(asm.NEW, "Notifier")
+	mb.Insn(asm.DUP)
+	mb.MethodInsn(asm.INVOKESPECIAL, "Notifier", "<init>", "()V")
+	mb.MethodInsn(asm.INVOKEVIRTUAL, "Notifier", "start", "()V")
+	mb.Mark(loop)
+	mb.FieldInsn(asm.GETSTATIC, "Counter", "finished", "I")
+	mb.JumpInsn(asm.IFNE, done)
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.Insn(asm.LCONST_0)
+	mb.MethodInsn(asm.INVOKEVIRTUAL, "java/lang/Object", "wait", "(J)V")
+	mb.JumpInsn(asm.GOTO, loop)
+	mb.Mark(done)
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "holdsLock", "(Ljava/lang/Object;)Z")
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.Insn(asm.MONITOREXIT)
+	mb.Insn(asm.IRETURN)
+
+	jvm := asmtest.NewVM(t, vm.Options{}, append(monitorClasses(), main)...)
+	result, err := jvm.InvokeStatic("Main", "handshake", "()Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != true {
+		t.Error("the monitor was not re-acquired when wait() returned")
+	}
+}
+
+func TestMonitorOwnership(t *testing.T) {
+	main := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Main", "java/lang/Object")
+
+	// static int notOwner(int op) { Object o = new Object(); try { o.notify()/o.wait(0)/monitorexit(o); return 0; } catch (IllegalMonitorStateException e) { return 1; } }
+	mb := main.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "notOwner", "(I)I")
+	start, end, handler := mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+	notify, wait, exit := mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+	mb.TypeInsn(asm.NEW, "java/lang/Object")
+	mb.Insn(asm.DUP)
+	mb.MethodInsn(asm.INVOKESPECIAL, "java/lang/Object", "<init>", "()V")
+	mb.VarInsn(asm.ASTORE, 1)
+	mb.Mark(start)
+	mb.VarInsn(asm.ALOAD, 1)
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.TableSwitchInsn(0, 2, exit, notify, wait, exit)
+	mb.Mark(notify)
+	mb.MethodInsn(asm.INVOKEVIRTUAL, "java/lang/Object", "notify", "()V")
+	mb.Insn(asm.ICONST_0)
+	mb.Insn(asm.IRETURN)
+	mb.Mark(wait)
+	mb.Insn(asm.LCONST_0)
+	mb.MethodInsn(asm.INVOKEVIRTUAL, "java/lang/Object", "wait", "(J)V")
+	mb.Insn(asm.ICONST_0)
+	mb.Insn(asm.IRETURN)
+	mb.Mark(exit)
+	mb.Insn(asm.MONITOREXIT)
+	mb.Insn(asm.ICONST_0)
+	mb.Mark(end)
+	mb.Insn(asm.IRETURN)
+	mb.Mark(handler)
+	mb.Insn(asm.POP)
+	mb.Insn(asm.ICONST_1)
+	mb.Insn(asm.IRETURN)
+	mb.TryCatch(start, end, handler, "java/lang/IllegalMonitorStateException")
+
+	// static boolean unwind() { Counter c = new Counter(); try { c.boom(); } catch (RuntimeException e) {} return Thread.holdsLock(c); }
+	mb = main.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "unwind", "()Z")
+	start, end, handler = mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+	after := mb.NewLabel()
+	mb.TypeInsn(asm.NEW, "Counter")
+	mb.Insn(asm.DUP)
+	mb.MethodInsn(asm.INVOKESPECIAL, "Counter", "<init>", "()V")
+	mb.VarInsn(asm.ASTORE, 0)
+	mb.Mark(start)
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.MethodInsn(asm.INVOKEVIRTUAL, "Counter", "boom", "()V")
+	mb.Mark(end)
+	mb.JumpInsn(asm.GOTO, after)
+	mb.Mark(handler)
+	mb.Insn(asm.POP)
+	mb.Mark(after)
+	mb.VarInsn(asm.ALOAD, 0)
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "holdsLock", "(Ljava/lang/Object;)Z")
+	mb.Insn(asm.IRETURN)
+	mb.TryCatch(start, end, handler, "java/lang/RuntimeException")
+
+	jvm := asmtest.NewVM(t, vm.Options{}, append(monitorClasses(), main)...)
+	for op, name := range []string{"notify", "wait", "monitorexit"} {
+		result, err := jvm.InvokeStatic("Main", "notOwner", "(I)I", op)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result != int32(1) {
+			t.Errorf("%s without owning the monitor did not throw IllegalMonitorStateException", name)
+		}
+	}
+	result, err := jvm.InvokeStatic("Main", "unwind", "()Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != false {
+		t.Error("synchronized method still holds its monitor after an exception unwound it")
+	}
+}
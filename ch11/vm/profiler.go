@@ -0,0 +1,273 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/javap"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+-Xprof 性能分析器，有两种模式：
+采样：后台goroutine每隔interval设置一次标志，解释器执行下一条指令之前看到标志就记录一次当前线程的Java栈
+精确(-Xprof:exact)：记录每一条指令和每一次方法调用，结果不受采样误差影响，但是程序会慢很多
+
+两种模式都把栈记录在一棵调用树中，程序结束后输出：
+折叠栈(collapsed stacks)，每行是"调用者;...;被调用者 次数"，可以交给flamegraph.pl或者speedscope画火焰图
+每个方法的self/total，以及每种指令的执行次数
+*/
+
+const defaultProfInterval = time.Millisecond
+
+type Profiler struct {
+	exact    bool
+	interval time.Duration
+	tick     int32         //采样标志，后台goroutine设置为1，解释器记录之后清0
+	stop     chan struct{} //关闭之后后台goroutine退出
+	stopped  bool
+	root     *callNode
+	opcodes  [256]int64
+	calls    map[*heap.Method]int64 //精确模式下每个方法被调用的次数
+	samples  int64                  //采样次数或者执行的指令数
+	start    time.Time
+	elapsed  time.Duration
+	//上一次记录的帧和它在调用树中的节点，帧不变时不需要重新遍历Java栈
+	lastFrame *rtda.Frame
+	lastNode  *callNode
+}
+
+// callNode 调用树的节点，从根节点到这个节点的路径就是一个Java栈
+type callNode struct {
+	method   *heap.Method
+	children map[*heap.Method]*callNode
+	self     int64 //栈顶是这个节点时的采样次数或者指令数
+}
+
+func newProfiler(exact bool, interval time.Duration) *Profiler {
+	if interval <= 0 {
+		interval = defaultProfInterval
+	}
+	self := &Profiler{
+		exact:    exact,
+		interval: interval,
+		stop:     make(chan struct{}),
+		root:     &callNode{},
+		calls:    map[*heap.Method]int64{},
+		start:    time.Now(),
+	}
+	if !exact {
+		go self.ticker()
+	}
+	return self
+}
+
+func (self *Profiler) ticker() {
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			atomic.StoreInt32(&self.tick, 1)
+		case <-self.stop:
+			return
+		}
+	}
+}
+
+// Stop 停止采样，之后可以输出结果
+func (self *Profiler) Stop() {
+	if !self.stopped {
+		self.stopped = true
+		self.elapsed = time.Since(self.start)
+		close(self.stop)
+	}
+}
+
+// beforeInstruction 解释器执行每条指令之前调用，这时thread.PC()是这条指令的pc
+func (self *Profiler) beforeInstruction(frame *rtda.Frame, opcode uint8) {
+	if self.stopped {
+		return
+	}
+	if !self.exact {
+		if atomic.LoadInt32(&self.tick) == 0 {
+			return
+		}
+		atomic.StoreInt32(&self.tick, 0)
+	}
+	if frame != self.lastFrame {
+		if self.exact && frame.Thread().PC() == 0 {
+			self.calls[frame.Method()]++ //新的帧从pc 0开始执行，是一次方法调用
+		}
+		self.lastFrame = frame
+		self.lastNode = self.nodeOf(frame.Thread())
+	}
+	self.lastNode.self++
+	self.opcodes[opcode]++
+	self.samples++
+}
+
+// nodeOf 从栈底到栈顶遍历线程的Java栈，找到调用树中对应的节点，从Go代码调用Java方法时使用的帧不计入
+func (self *Profiler) nodeOf(thread *rtda.Thread) *callNode {
+	node := self.root
+	frames := thread.GetFrames()
+	for i := len(frames) - 1; i >= 0; i-- {
+		method := frames[i].Method()
+		if method.IsShim() {
+			continue
+		}
+		child := node.children[method]
+		if child == nil {
+			child = &callNode{method: method}
+			if node.children == nil {
+				node.children = map[*heap.Method]*callNode{}
+			}
+			node.children[method] = child
+		}
+		node = child
+	}
+	return node
+}
+
+// WriteCollapsed 输出折叠栈，每行一个栈，按字母顺序排序
+func (self *Profiler) WriteCollapsed(w io.Writer) error {
+	var lines []string
+	var path []string
+	var walk func(node *callNode)
+	walk = func(node *callNode) {
+		if node.method != nil {
+			path = append(path, flameName(node.method))
+			defer func() { path = path[:len(path)-1] }()
+		}
+		if node.self > 0 && len(path) > 0 {
+			lines = append(lines, fmt.Sprintf("%s %d", strings.Join(path, ";"), node.self))
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(self.root)
+	sort.Strings(lines)
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type methodProfile struct {
+	method *heap.Method
+	self   int64
+	total  int64 //栈中包含这个方法的次数，递归调用只算一次
+}
+
+// methodProfiles 汇总调用树，按self从大到小排序
+func (self *Profiler) methodProfiles() []*methodProfile {
+	profiles := map[*heap.Method]*methodProfile{}
+	onStack := map[*heap.Method]int{}
+	var walk func(node *callNode)
+	walk = func(node *callNode) {
+		if node.method != nil {
+			onStack[node.method]++
+			defer func() {
+				if onStack[node.method]--; onStack[node.method] == 0 {
+					delete(onStack, node.method)
+				}
+			}()
+			profile := profiles[node.method]
+			if profile == nil {
+				profile = &methodProfile{method: node.method}
+				profiles[node.method] = profile
+			}
+			profile.self += node.self
+		}
+		if node.self > 0 {
+			for method := range onStack {
+				profiles[method].total += node.self
+			}
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(self.root)
+
+	result := make([]*methodProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		result = append(result, profile)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.self != b.self {
+			return a.self > b.self
+		}
+		if a.total != b.total {
+			return a.total > b.total
+		}
+		return flameName(a.method) < flameName(b.method)
+	})
+	return result
+}
+
+/*
+WriteReport 输出每个方法的self/total和指令直方图
+采样模式下的时间是按采样次数分配的运行时间，精确模式下用执行的指令数代替时间
+*/
+func (self *Profiler) WriteReport(w io.Writer) {
+	if self.exact {
+		fmt.Fprintf(w, "Exact profile: %d instructions in %v\n\n", self.samples, self.elapsed.Round(time.Millisecond))
+		fmt.Fprintf(w, "%12s %7s %12s %7s %10s  %s\n", "Self", "Self%", "Total", "Total%", "Calls", "Method")
+	} else {
+		fmt.Fprintf(w, "Sampled profile: %d samples every %v in %v\n\n", self.samples, self.interval, self.elapsed.Round(time.Millisecond))
+		fmt.Fprintf(w, "%12s %7s %12s %7s  %s\n", "Self(ms)", "Self%", "Total(ms)", "Total%", "Method")
+	}
+	for _, profile := range self.methodProfiles() {
+		if self.exact {
+			fmt.Fprintf(w, "%12d %6.2f%% %12d %6.2f%% %10d  %s\n",
+				profile.self, self.percent(profile.self), profile.total, self.percent(profile.total),
+				self.calls[profile.method], flameName(profile.method))
+		} else {
+			fmt.Fprintf(w, "%12.1f %6.2f%% %12.1f %6.2f%%  %s\n",
+				self.millis(profile.self), self.percent(profile.self),
+				self.millis(profile.total), self.percent(profile.total), flameName(profile.method))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%12s %7s  %s\n", "Count", "%", "Opcode")
+	opcodes := make([]int, 0, len(self.opcodes))
+	for op, count := range self.opcodes {
+		if count > 0 {
+			opcodes = append(opcodes, op)
+		}
+	}
+	sort.Slice(opcodes, func(i, j int) bool {
+		a, b := self.opcodes[opcodes[i]], self.opcodes[opcodes[j]]
+		return a > b || a == b && opcodes[i] < opcodes[j]
+	})
+	for _, op := range opcodes {
+		count := self.opcodes[op]
+		fmt.Fprintf(w, "%12d %6.2f%%  %s\n", count, self.percent(count), javap.Mnemonic(byte(op)))
+	}
+}
+
+func (self *Profiler) percent(n int64) float64 {
+	if self.samples == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(self.samples)
+}
+
+// millis 按采样次数的比例分配运行时间，后台goroutine不一定能按时设置标志，不能直接用采样次数乘以间隔
+func (self *Profiler) millis(samples int64) float64 {
+	return self.percent(samples) / 100 * float64(self.elapsed) / float64(time.Millisecond)
+}
+
+// flameName 火焰图中的方法名，比如java.lang.String.length
+func flameName(method *heap.Method) string {
+	return method.Class().JavaName() + "." + method.Name()
+}
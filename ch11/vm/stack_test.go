@@ -0,0 +1,69 @@
+package vm_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/vm"
+)
+
+// deepClass 生成 static int depth(int n) { try { return depth(n + 1); } catch (StackOverflowError e) { return n; } }
+func deepClass() *asm.ClassBuilder {
+	deep := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Deep", "java/lang/Object")
+	mb := deep.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "depth", "(I)I")
+	start, end, handler := mb.NewLabel(), mb.NewLabel(), mb.NewLabel()
+	mb.Mark(start)
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.Insn(asm.ICONST_1)
+	mb.Insn(asm.IADD)
+	mb.MethodInsn(asm.INVOKESTATIC, "Deep", "depth", "(I)I")
+	mb.Mark(end)
+	mb.Insn(asm.IRETURN)
+	mb.Mark(handler)
+	mb.Insn(asm.POP)
+	mb.VarInsn(asm.ILOAD, 0)
+	mb.Insn(asm.IRETURN)
+	mb.TryCatch(start, end, handler, "java/lang/StackOverflowError")
+	return deep
+}
+
+func TestStackOverflowErrorIsCatchableRepeatedly(t *testing.T) {
+	soe := asmtest.Exception("java/lang/StackOverflowError")
+	jvm := asmtest.NewVM(t, vm.Options{StackSize: 128 << 10}, soe, deepClass())
+	// 第一次溢出之后预留空间必须被释放，否则第二次溢出会变成致命错误
+	for i := 0; i < 3; i++ {
+		result, err := jvm.InvokeStatic("Deep", "depth", "(I)I", 0)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if depth := result.(int32); depth < 100 {
+			t.Errorf("call %d: caught StackOverflowError at depth %d", i, depth)
+		}
+	}
+}
+
+func TestStackOverflowWhileHandlingIsFatal(t *testing.T) {
+	// StackOverflowError的构造函数无限递归，在预留空间中又溢出
+	soe := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "java/lang/StackOverflowError", "java/lang/Object")
+	init := soe.AddMethod(asm.ACC_PUBLIC, "<init>", "()V")
+	init.MethodInsn(asm.INVOKESTATIC, "java/lang/StackOverflowError", "recurse", "()V")
+	init.Insn(asm.RETURN)
+	recurse := soe.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "recurse", "()V")
+	recurse.MethodInsn(asm.INVOKESTATIC, "java/lang/StackOverflowError", "recurse", "()V")
+	recurse.Insn(asm.RETURN)
+
+	stderr := &bytes.Buffer{}
+	jvm := asmtest.NewVM(t, vm.Options{Stderr: stderr, StackSize: 128 << 10}, soe, deepClass())
+	_, err := jvm.InvokeStatic("Deep", "depth", "(I)I", 0)
+	var exitErr *vm.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Status != 1 {
+		t.Fatalf("err = %v, want the VM to halt with status 1", err)
+	}
+	if !strings.Contains(stderr.String(), "Fatal error: stack overflow while handling java.lang.StackOverflowError") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
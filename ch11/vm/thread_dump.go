@@ -0,0 +1,150 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"os"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+/*
+线程转储，格式和HotSpot收到SIGQUIT(kill -3)或者jstack打印的一样：
+
+	"main" #1 prio=5 in Object.wait()
+	   java.lang.Thread.State: WAITING (on object monitor)
+		at java.lang.Object.wait(Native Method)
+		- waiting on <0x000000c000123450> (a java.lang.Object)
+		at Foo.main(Foo.java:10)
+		- locked <0x000000c000123450> (a java.lang.Object)
+
+和堆转储一样，RequestThreadDump()只是设置标志，转储在解释器执行下一条指令之前进行
+所有线程都在sleep()、wait()或者join()中等待时，解释器不执行指令，转储由调度器的空闲循环进行
+*/
+
+// DumpThreads 把所有线程的栈写到w中，必须在没有执行Java代码时或者在执行Java代码的goroutine中调用
+func (self *VM) DumpThreads(w io.Writer) {
+	fmt.Fprintln(w, time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintln(w, "Full thread dump jvmgo:")
+	fmt.Fprintln(w)
+	for _, thread := range self.runtime.AllThreads() {
+		dumpThread(w, thread)
+		fmt.Fprintln(w)
+	}
+}
+
+// RequestThreadDump 请求转储线程，可以在任何goroutine中调用
+func (self *VM) RequestThreadDump() {
+	atomic.StoreInt32(&self.threadDumpRequested, 1)
+	self.runtime.Wakeup()
+}
+
+// checkThreadDump 解释器执行每条指令之前以及调度器空闲时调用，ThreadDumpPath为空时打印到标准错误，否则追加到文件中
+func (self *VM) checkThreadDump() {
+	if !atomic.CompareAndSwapInt32(&self.threadDumpRequested, 1, 0) {
+		return
+	}
+	if self.threadDumpPath == "" {
+		self.DumpThreads(self.runtime.Stderr())
+		return
+	}
+	f, err := os.OpenFile(self.threadDumpPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintf(self.runtime.Stderr(), "Unable to open %s: %v\n", self.threadDumpPath, err)
+		return
+	}
+	self.DumpThreads(f)
+	f.Close()
+}
+
+func dumpThread(w io.Writer, thread *rtda.Thread) {
+	header, state := "runnable", "RUNNABLE"
+	waitingOn := thread.WaitingOn()
+	if thread.IsWaiting() {
+		if waitingOn == nil && thread.HasTimeout() {
+			header, state = "waiting on condition", "TIMED_WAITING (sleeping)"
+		} else if waitingOn == nil {
+			header, state = "waiting on condition", "WAITING (parking)" //比如空闲的Finalizer线程
+		} else if thread.HasTimeout() {
+			header, state = "in Object.wait()", "TIMED_WAITING (on object monitor)"
+		} else {
+			header, state = "in Object.wait()", "WAITING (on object monitor)"
+		}
+	}
+	blockedOn := thread.EnteringMonitor() //wait()被唤醒之后也要重新进入监视器
+	if thread.IsWaiting() {
+		blockedOn = nil
+	} else if blockedOn != nil {
+		header, state = "waiting for monitor entry", "BLOCKED (on object monitor)"
+	}
+
+	if jThread := thread.JThread(); jThread != nil {
+		fmt.Fprintf(w, "\"%s\"", rtda.ThreadName(jThread))
+		if tid := jThread.Class().GetInstanceField("tid", "J"); tid != nil {
+			fmt.Fprintf(w, " #%d", jThread.Fields().GetLong(tid.SlotId()))
+		}
+		if jThread.GetIntVar("daemon", "Z") != 0 {
+			fmt.Fprint(w, " daemon")
+		}
+		fmt.Fprintf(w, " prio=%d %s\n", jThread.GetIntVar("priority", "I"), header)
+	} else if name := thread.Name(); name != "" {
+		fmt.Fprintf(w, "\"%s\" daemon %s\n", name, header) //虚拟机内部线程
+	} else {
+		fmt.Fprintf(w, "\"main\" %s\n", header) //主线程的Thread对象还没有创建
+	}
+	fmt.Fprintf(w, "   java.lang.Thread.State: %s\n", state)
+
+	top := true
+	for _, frame := range thread.GetFrames() {
+		method := frame.Method()
+		if method.IsShim() {
+			continue
+		}
+		fmt.Fprintf(w, "\tat %s.%s(%s)\n", method.Class().JavaName(), method.Name(),
+			frameLocation(method, frame.NextPC()-1))
+		if top && waitingOn != nil {
+			fmt.Fprintf(w, "\t- waiting on %s\n", monitorString(waitingOn))
+		}
+		if top && blockedOn != nil {
+			fmt.Fprintf(w, "\t- waiting to lock %s\n", monitorString(blockedOn))
+		}
+		monitors := frame.Monitors()
+		for i := len(monitors) - 1; i >= 0; i-- {
+			if !top || monitors[i] != blockedOn {
+				fmt.Fprintf(w, "\t- locked %s\n", monitorString(monitors[i]))
+			}
+		}
+		if lock := frame.SyncLock(); lock != nil && (!top || lock != blockedOn) {
+			fmt.Fprintf(w, "\t- locked %s\n", monitorString(lock))
+		}
+		top = false
+	}
+}
+
+// frameLocation 和StackTraceElement.toString()括号中的部分一样
+func frameLocation(method *heap.Method, pc int) string {
+	file := method.Class().SourceFile()
+	line := method.GetLineNumber(pc)
+	switch {
+	case line == -2:
+		return "Native Method"
+	case file != "" && line >= 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	case file != "":
+		return file
+	default:
+		return "Unknown Source"
+	}
+}
+
+// monitorString 对象的地址和类名，例如<0x000000c000123450> (a java.lang.Object)
+func monitorString(obj *heap.Object) string {
+	s := fmt.Sprintf("<0x%016x> (a %s", uintptr(unsafe.Pointer(obj)), obj.Class().JavaName())
+	if class, ok := obj.Extra().(*heap.Class); ok {
+		s += " for " + class.JavaName()
+	}
+	return s + ")"
+}
@@ -0,0 +1,49 @@
+package vm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"jvmgo/ch11/asm"
+	"jvmgo/ch11/asm/asmtest"
+	"jvmgo/ch11/vm"
+)
+
+// 所有线程都在sleep()时，解释器不执行指令，线程转储由调度器的空闲循环完成
+func TestThreadDumpWhileAllThreadsSleep(t *testing.T) {
+	sleeper := asm.NewClass(asm.ACC_PUBLIC|asm.ACC_SUPER, "Sleeper", "java/lang/Object")
+	sleeper.SetSourceFile("Sleeper.java")
+	mb := sleeper.AddMethod(asm.ACC_PUBLIC|asm.ACC_STATIC, "nap", "()V")
+	mb.Ldc(int64(500))
+	mb.MethodInsn(asm.INVOKESTATIC, "java/lang/Thread", "sleep", "(J)V")
+	mb.Insn(asm.RETURN)
+
+	stderr := &bytes.Buffer{}
+	jvm := asmtest.NewVM(t, vm.Options{Stderr: stderr}, asmtest.Thread(), sleeper)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		jvm.RequestThreadDump()
+	}()
+	start := time.Now()
+	if _, err := jvm.InvokeStatic("Sleeper", "nap", "()V"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("Thread.sleep(500) returned after %v", elapsed)
+	}
+
+	dump := stderr.String()
+	for _, want := range []string{
+		"Full thread dump jvmgo:",
+		"java.lang.Thread.State: TIMED_WAITING (sleeping)",
+		"at java.lang.Thread.sleep(Native Method)",
+		"at Sleeper.nap(Sleeper.java)",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("thread dump does not contain %q:\n%s", want, dump)
+		}
+	}
+}
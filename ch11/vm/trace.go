@@ -0,0 +1,457 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"jvmgo/ch11/instructions/references"
+	"jvmgo/ch11/javap"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"strings"
+)
+
+/*
+-Xtrace 结构化的执行跟踪
+
+	-Xtrace                                   跟踪所有方法的调用、返回和异常
+	-Xtrace:method=com/acme/*,events=call,throw
+	-Xtrace:class=Foo,java/util/*,events=all,format=json,file=trace.log
+
+选项：
+class   类名模式(内部名，比如java/util/*)，*匹配任意字符串
+method  方法名模式，格式是类名.方法名，比如com/acme/*或者*.toString
+events  call return throw catch load init inst，或者all，默认是call,return,throw,catch
+format  text或者json(每行一个JSON对象)
+file    输出文件，默认是标准错误
+
+call/return/inst事件按方法过滤，throw/catch按抛出和捕获异常的方法过滤，load/init只按类名过滤
+init事件在<clinit>开始执行时产生，没有<clinit>的类没有init事件
+*/
+
+const (
+	TRACE_CALL   = "call"
+	TRACE_RETURN = "return"
+	TRACE_THROW  = "throw"
+	TRACE_CATCH  = "catch"
+	TRACE_LOAD   = "load"
+	TRACE_INIT   = "init"
+	TRACE_INST   = "inst"
+)
+
+var allTraceEvents = []string{TRACE_CALL, TRACE_RETURN, TRACE_THROW, TRACE_CATCH, TRACE_LOAD, TRACE_INIT, TRACE_INST}
+var defaultTraceEvents = []string{TRACE_CALL, TRACE_RETURN, TRACE_THROW, TRACE_CATCH}
+
+type TraceOptions struct {
+	Classes []string  // 类名模式，为空表示不过滤
+	Methods []string  // 方法名模式，为空表示不过滤
+	Events  []string  // 为空时使用默认事件
+	JSON    bool      // 输出JSON而不是文本
+	File    string    // 由调用者打开文件并设置Output
+	Output  io.Writer // 为nil时使用虚拟机的标准错误
+}
+
+// ParseTraceOptions 解析-Xtrace:后面的选项，逗号分隔，不带=的部分属于前一个选项
+func ParseTraceOptions(s string) (*TraceOptions, error) {
+	options := &TraceOptions{}
+	var list *[]string
+	for _, opt := range strings.Split(s, ",") {
+		if opt == "" {
+			continue
+		}
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) == 1 {
+			if list == nil {
+				return nil, fmt.Errorf("trace: bad option: %s", opt)
+			}
+			*list = append(*list, opt)
+			continue
+		}
+		list = nil
+		switch key, val := kv[0], kv[1]; key {
+		case "class":
+			list = &options.Classes
+		case "method":
+			list = &options.Methods
+		case "events":
+			list = &options.Events
+		case "format":
+			if val != "text" && val != "json" {
+				return nil, fmt.Errorf("trace: unsupported format: %s", val)
+			}
+			options.JSON = val == "json"
+		case "file":
+			options.File = val
+		default:
+			return nil, fmt.Errorf("trace: unsupported option: %s", key)
+		}
+		if list != nil {
+			*list = append(*list, kv[1])
+		}
+	}
+	for _, event := range options.Events {
+		if event != "all" && indexOf(allTraceEvents, event) < 0 {
+			return nil, fmt.Errorf("trace: unknown event: %s", event)
+		}
+	}
+	return options, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, x := range list {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// traceEvent 一行跟踪输出，JSON格式时省略空字段
+type traceEvent struct {
+	Event      string   `json:"event"`
+	Thread     string   `json:"thread,omitempty"`
+	Depth      int      `json:"depth,omitempty"`
+	Class      string   `json:"class,omitempty"`
+	Method     string   `json:"method,omitempty"`
+	Descriptor string   `json:"descriptor,omitempty"`
+	PC         *int     `json:"pc,omitempty"`
+	Line       int      `json:"line,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	Value      string   `json:"value,omitempty"`     //返回值
+	Exception  string   `json:"exception,omitempty"` //抛出、捕获的异常，或者导致方法返回的异常
+	Opcode     string   `json:"opcode,omitempty"`
+	Uncaught   bool     `json:"uncaught,omitempty"`
+}
+
+type tracer struct {
+	options   *TraceOptions
+	events    map[string]bool
+	out       io.Writer
+	lastFrame *rtda.Frame //上一条指令所在的帧，帧改变并且pc是0时是一次方法调用
+	throwing  *heap.Object
+	unwinding []*rtda.Frame //athrow执行前线程的栈，执行后和新栈比较就知道哪些帧被弹出了
+}
+
+func newTracer(options *TraceOptions, loader *heap.ClassLoader, stderr io.Writer) *tracer {
+	self := &tracer{options: options, events: map[string]bool{}, out: options.Output}
+	if self.out == nil {
+		self.out = stderr
+	}
+	events := options.Events
+	if len(events) == 0 {
+		events = defaultTraceEvents
+	}
+	for _, event := range events {
+		if event == "all" {
+			events = allTraceEvents
+			break
+		}
+	}
+	for _, event := range events {
+		self.events[event] = true
+	}
+	if self.events[TRACE_LOAD] {
+		loader.AddLoadHook(self.classLoaded)
+	}
+	return self
+}
+
+// beforeInstruction 解释器执行每条指令之前调用，这时thread.PC()是这条指令的pc
+func (self *tracer) beforeInstruction(frame *rtda.Frame, opcode uint8) {
+	method := frame.Method()
+	if method.IsShim() {
+		if opcode == 0xbf { // 本地方法通过ThrowException抛出的异常
+			self.beforeThrow(frame)
+		}
+		return
+	}
+	thread := frame.Thread()
+	pc := thread.PC()
+	if frame != self.lastFrame {
+		self.lastFrame = frame
+		if pc == 0 {
+			self.methodEntered(frame)
+		}
+	}
+	if self.events[TRACE_INST] && self.matchMethod(method) {
+		event := self.newEvent(TRACE_INST, frame, method.Class())
+		event.PC = &pc
+		event.Opcode = javap.Mnemonic(opcode)
+		self.emit(event)
+	}
+	switch {
+	case opcode >= 0xac && opcode <= 0xb1: // ireturn ... return
+		if self.events[TRACE_RETURN] && self.matchMethod(method) {
+			event := self.newEvent(TRACE_RETURN, frame, method.Class())
+			_, returnType := heap.ParseMethodDescriptor(method.Descriptor())
+			if returnType != "V" {
+				size := uint(1)
+				if returnType == "J" || returnType == "D" {
+					size = 2
+				}
+				event.Value = formatLocal(frame.OperandStack().Top(size), 0, returnType)
+			}
+			self.emit(event)
+		}
+	case opcode == 0xbf: // athrow
+		self.beforeThrow(frame)
+	}
+}
+
+func (self *tracer) methodEntered(frame *rtda.Frame) {
+	method := frame.Method()
+	if self.events[TRACE_INIT] && method.Name() == "<clinit>" && self.matchClass(method.Class()) {
+		self.emit(self.newEvent(TRACE_INIT, frame, method.Class()))
+	}
+	if !self.events[TRACE_CALL] || !self.matchMethod(method) {
+		return
+	}
+	event := self.newEvent(TRACE_CALL, frame, method.Class())
+	vars := frame.LocalVars()
+	index := uint(0)
+	if !method.IsStatic() {
+		event.Args = append(event.Args, formatRef(vars.GetRef(0)))
+		index++
+	}
+	parameterTypes, _ := heap.ParseMethodDescriptor(method.Descriptor())
+	for _, paramType := range parameterTypes {
+		event.Args = append(event.Args, formatLocal(vars, index, paramType))
+		index++
+		if paramType == "J" || paramType == "D" {
+			index++
+		}
+	}
+	self.emit(event)
+}
+
+// beforeThrow 记录异常和线程的栈，异常处理完之后由afterInstruction产生事件
+func (self *tracer) beforeThrow(frame *rtda.Frame) {
+	ex := frame.OperandStack().GetRefFromTop(0)
+	if ex == nil {
+		return //athrow会抛出NullPointerException
+	}
+	self.throwing = ex
+	self.unwinding = frame.Thread().GetFrames()
+	if self.events[TRACE_THROW] {
+		where := self.callerOf(self.unwinding)
+		if where != nil && self.matchMethod(where.Method()) {
+			event := self.newEvent(TRACE_THROW, where, where.Method().Class())
+			event.Exception = references.ThrowableToString(ex)
+			self.emit(event)
+		}
+	}
+}
+
+// afterInstruction 解释器执行完每条指令之后调用，只处理athrow
+func (self *tracer) afterInstruction(thread *rtda.Thread) {
+	if self.throwing == nil {
+		return
+	}
+	ex, frames := self.throwing, self.unwinding
+	self.throwing, self.unwinding = nil, nil
+
+	//异常被捕获时，当前帧是原来的栈中的一帧，它上面的帧都被弹出了
+	var catcher *rtda.Frame
+	if !thread.IsStackEmpty() {
+		current := thread.CurrentFrame()
+		for _, frame := range frames {
+			if frame == current {
+				catcher = frame
+				break
+			}
+		}
+	}
+	depth := len(frames) - shimCount(frames)
+	for _, frame := range frames {
+		if frame == catcher {
+			break
+		}
+		method := frame.Method()
+		if method.IsShim() {
+			continue
+		}
+		if self.events[TRACE_RETURN] && self.matchMethod(method) {
+			event := self.newEvent(TRACE_RETURN, frame, method.Class())
+			event.Depth = depth //帧已经被弹出了
+			event.Exception = ex.Class().JavaName()
+			self.emit(event)
+		}
+		depth--
+	}
+	self.lastFrame = catcher
+	if !self.events[TRACE_CATCH] {
+		return
+	}
+	if catcher == nil || catcher.Method().IsShim() { //从Go代码调用的方法抛出的异常交给调用者
+		event := self.newEvent(TRACE_CATCH, nil, ex.Class())
+		event.Thread = threadName(thread)
+		event.Class = ""
+		event.Exception = references.ThrowableToString(ex)
+		event.Uncaught = true
+		self.emit(event)
+	} else if self.matchMethod(catcher.Method()) {
+		event := self.newEvent(TRACE_CATCH, catcher, catcher.Method().Class())
+		pc := catcher.NextPC()
+		event.PC = &pc
+		event.Line = catcher.Method().GetLineNumber(pc)
+		event.Exception = references.ThrowableToString(ex)
+		self.emit(event)
+	}
+}
+
+// callerOf 跳过shim帧，找到抛出异常的方法
+func (self *tracer) callerOf(frames []*rtda.Frame) *rtda.Frame {
+	for _, frame := range frames {
+		if !frame.Method().IsShim() {
+			return frame
+		}
+	}
+	return nil
+}
+
+func (self *tracer) classLoaded(class *heap.Class) {
+	if self.matchClass(class) {
+		self.emit(&traceEvent{Event: TRACE_LOAD, Class: class.Name()})
+	}
+}
+
+func (self *tracer) newEvent(kind string, frame *rtda.Frame, class *heap.Class) *traceEvent {
+	event := &traceEvent{Event: kind, Class: class.Name()}
+	if frame != nil {
+		method := frame.Method()
+		event.Thread = threadName(frame.Thread())
+		event.Depth = javaDepth(frame.Thread())
+		event.Method = method.Name()
+		event.Descriptor = method.Descriptor()
+		if kind == TRACE_THROW {
+			pc := frame.Thread().PC()
+			if frame != frame.Thread().CurrentFrame() {
+				pc = frame.NextPC() - 1 //本地方法通过shim帧抛出异常
+			}
+			event.PC = &pc
+			event.Line = method.GetLineNumber(pc)
+		}
+	}
+	return event
+}
+
+func (self *tracer) emit(event *traceEvent) {
+	if self.options.JSON {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(self.out, "%s\n", data)
+		return
+	}
+	fmt.Fprintln(self.out, formatTraceEvent(event))
+}
+
+// formatTraceEvent 文本格式，按调用深度缩进
+func formatTraceEvent(event *traceEvent) string {
+	var sb strings.Builder
+	if event.Thread != "" {
+		fmt.Fprintf(&sb, "[%s] ", event.Thread)
+	}
+	if event.Depth > 1 {
+		sb.WriteString(strings.Repeat("  ", event.Depth-1))
+	}
+	name := strings.Replace(event.Class, "/", ".", -1)
+	if event.Method != "" {
+		name += "." + event.Method
+	}
+	switch event.Event {
+	case TRACE_CALL:
+		fmt.Fprintf(&sb, "-> %s(%s)", name, strings.Join(event.Args, ", "))
+	case TRACE_RETURN:
+		fmt.Fprintf(&sb, "<- %s", name)
+		if event.Exception != "" {
+			fmt.Fprintf(&sb, " threw %s", event.Exception)
+		} else if event.Value != "" {
+			fmt.Fprintf(&sb, " = %s", event.Value)
+		}
+	case TRACE_INST:
+		fmt.Fprintf(&sb, "%s #%d %s", name, *event.PC, event.Opcode)
+	case TRACE_CATCH:
+		if event.Uncaught {
+			fmt.Fprintf(&sb, "uncaught %s", event.Exception)
+			break
+		}
+		fallthrough
+	case TRACE_THROW:
+		fmt.Fprintf(&sb, "%s %s at %s #%d", event.Event, event.Exception, name, *event.PC)
+		if event.Line > 0 {
+			fmt.Fprintf(&sb, " (line %d)", event.Line)
+		}
+	default: // load init
+		fmt.Fprintf(&sb, "%s %s", event.Event, name)
+	}
+	return sb.String()
+}
+
+func (self *tracer) matchClass(class *heap.Class) bool {
+	return matchAny(self.options.Classes, class.Name())
+}
+
+func (self *tracer) matchMethod(method *heap.Method) bool {
+	return self.matchClass(method.Class()) &&
+		matchAny(self.options.Methods, method.Class().Name()+"."+method.Name())
+}
+
+// matchAny 没有模式时匹配所有名字
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if globMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch *匹配任意字符串(包括/)，?匹配一个字符
+func globMatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(name); i >= 0; i-- {
+				if globMatch(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+		default:
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func threadName(thread *rtda.Thread) string {
+	if thread.JThread() == nil {
+		return "main" //主线程的Thread对象还没有创建
+	}
+	return rtda.ThreadName(thread.JThread())
+}
+
+// javaDepth 栈中不是shim的帧数
+func javaDepth(thread *rtda.Thread) int {
+	frames := thread.GetFrames()
+	return len(frames) - shimCount(frames)
+}
+
+func shimCount(frames []*rtda.Frame) int {
+	n := 0
+	for _, frame := range frames {
+		if frame.Method().IsShim() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,242 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"jvmgo/ch11/classpath"
+	"jvmgo/ch11/native"
+	"jvmgo/ch11/rtda"
+	"jvmgo/ch11/rtda/heap"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+vm 把解释器包装成可以嵌入到Go程序中的虚拟机
+
+	jvm, err := vm.New(vm.Options{CpOption: "classes"})
+	result, err := jvm.InvokeStatic("Foo", "add", "(II)I", 1, 2)
+
+每个VM有自己的类加载器、字符串池、本地方法表和线程，同一个进程中的多个VM互不影响
+VM不是并发安全的，同一时刻只能有一个goroutine使用它
+*/
+
+type Options struct {
+	Classpath    *classpath.Classpath // 为nil时根据JreOption和CpOption解析
+	JreOption    string
+	CpOption     string
+	Properties   map[string]string // 系统属性，System.getProperty()可以读到
+	Stdout       io.Writer         // 为nil时使用os.Stdout
+	Stderr       io.Writer         // 为nil时使用os.Stderr
+	VerboseClass bool              // 打印类加载信息
+	VerboseInst  bool              // 打印执行的每一条指令
+	Debug        bool              // 启动交互式调试器，见debugger.go
+	DebugInput   io.Reader         // 调试器读取命令的输入，为nil时使用os.Stdin
+	Prof         bool              // 启动性能分析器，见profiler.go
+	ProfExact    bool              // 统计每一条指令和每一次方法调用，而不是采样
+	ProfInterval time.Duration     // 采样间隔，为0时是1ms
+	Trace        *TraceOptions     // 为nil表示不跟踪，见trace.go
+	InitialHeap  int64             // 堆的初始大小(-Xms)，为0时是16MB
+	MaxHeap      int64             // 堆的最大大小(-Xmx)，为0表示不限制，超过时抛出OutOfMemoryError
+	StackSize    int64             // 每个线程的栈大小(-Xss)，为0时是1MB，栈溢出时抛出StackOverflowError
+	// 第一次抛出OutOfMemoryError时转储堆，见heap_dump.go
+	HeapDumpOnOutOfMemoryError bool
+	HeapDumpPath               string // 转储文件或者目录，为空时是java_pid<pid>.hprof
+	ThreadDumpPath             string // 线程转储追加到这个文件，为空时打印到标准错误，见thread_dump.go
+	// 对象分配在虚拟机自己的堆中并由虚拟机回收(-XX:+UseJvmgoGC)，而不是交给Go的垃圾回收器
+	// 这时Go代码持有的对象(比如NewObject()的返回值)如果没有被Java程序引用，会在执行下一条指令时被回收
+	UseJvmgoGC bool
+	VerboseGC  bool // 每次回收时打印日志(-verbose:gc)
+}
+
+type VM struct {
+	loader      *heap.ClassLoader
+	runtime     *rtda.Runtime
+	verboseInst bool
+	debugHook   DebugHook //为nil表示没有启用调试器
+	profiler    *Profiler //为nil表示没有启用性能分析器
+	tracer      *tracer   //为nil表示没有启用-Xtrace
+	//堆转储
+	heapDumpOnOOM     bool
+	heapDumpPath      string
+	heapDumps         int   //已经转储的次数
+	oomDumped         bool  //OutOfMemoryError只转储一次
+	heapDumpRequested int32 //RequestHeapDump()设置为1
+	//线程转储
+	threadDumpPath      string
+	threadDumpRequested int32 //RequestThreadDump()设置为1
+}
+
+// DebugHook 解释器执行每条指令之前调用BeforeInstruction，这时frame.Thread().PC()是这条指令的pc
+// -Xdebug的控制台调试器和JDWP代理都通过它控制程序的执行
+type DebugHook interface {
+	BeforeInstruction(frame *rtda.Frame, opcode uint8)
+}
+
+// New 创建虚拟机，找不到JRE或者基本的类时返回错误
+func New(options Options) (jvm *VM, err error) {
+	defer recoverError(&err)
+
+	cp := options.Classpath
+	if cp == nil {
+		cp = classpath.Parse(options.JreOption, options.CpOption)
+	}
+	stdout := options.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	stderr := options.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if options.StackSize != 0 && options.StackSize < rtda.MinStackSize {
+		return nil, fmt.Errorf("The stack size specified is too small, Specify at least %dk", rtda.MinStackSize>>10)
+	}
+	loader := heap.NewClassLoader(cp, options.VerboseClass)
+	loader.Allocator().SetLimits(options.InitialHeap, options.MaxHeap)
+	runtime := rtda.NewRuntime(loader, stdout, stderr)
+	native.Install(runtime)
+	if options.UseJvmgoGC {
+		runtime.EnableJvmgoGC()
+	}
+	runtime.SetVerboseGC(options.VerboseGC)
+	if options.StackSize > 0 {
+		runtime.SetStackSize(uint(options.StackSize))
+	}
+	jvm = &VM{
+		loader:      loader,
+		runtime:     runtime,
+		verboseInst: options.VerboseInst,
+
+		heapDumpOnOOM: options.HeapDumpOnOutOfMemoryError,
+		heapDumpPath:  options.HeapDumpPath,
+
+		threadDumpPath: options.ThreadDumpPath,
+	}
+	runtime.SetIdleHook(jvm.checkThreadDump)
+	if len(options.Properties) > 0 {
+		if err := jvm.initProperties(options.Properties); err != nil {
+			return nil, err
+		}
+	}
+	if options.Debug { //启动过程中执行的Java代码不需要调试
+		debugInput := options.DebugInput
+		if debugInput == nil {
+			debugInput = os.Stdin
+		}
+		jvm.debugHook = newDebugger(jvm, debugInput, stdout)
+	}
+	if options.Trace != nil {
+		jvm.tracer = newTracer(options.Trace, loader, stderr)
+	}
+	if options.Prof {
+		jvm.profiler = newProfiler(options.ProfExact, options.ProfInterval)
+	}
+	return jvm, nil
+}
+
+// 把panic(比如找不到类时的"java.lang.ClassNotFoundException: Foo")转换成error
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("%v", r)
+		}
+	}
+}
+
+/*
+HotSpot在System.initializeSystemClass()中创建System.props，这个方法依赖大量本地方法，这里不执行
+而是直接创建Properties对象并赋值给System.props，System的<clinit>不会修改这个字段
+*/
+func (self *VM) initProperties(properties map[string]string) error {
+	props, err := self.NewObject("java/util/Properties", "()V")
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, err := self.Invoke(props, "setProperty",
+			"(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;", key, properties[key])
+		if err != nil {
+			return err
+		}
+	}
+	systemClass, err := self.LoadClass("java/lang/System")
+	if err != nil {
+		return err
+	}
+	systemClass.SetRefVar("props", "Ljava/util/Properties;", props)
+	return nil
+}
+
+// Loader 返回虚拟机的类加载器
+func (self *VM) Loader() *heap.ClassLoader {
+	return self.loader
+}
+
+// Profiler 返回性能分析器，没有启用时返回nil
+func (self *VM) Profiler() *Profiler {
+	return self.profiler
+}
+
+// Runtime 返回虚拟机的运行时状态，可以拿到所有线程
+func (self *VM) Runtime() *rtda.Runtime {
+	return self.runtime
+}
+
+// SetDebugHook 设置调试器，hook为nil表示取消
+func (self *VM) SetDebugHook(hook DebugHook) {
+	self.debugHook = hook
+}
+
+// SetHashCodeMode 设置identity hash code的生成策略，和HotSpot的-XX:hashCode=N一样
+func (self *VM) SetHashCodeMode(mode int) {
+	self.loader.SetHashCodeMode(mode)
+}
+
+// RegisterNative 给这个虚拟机注册本地方法，可以覆盖内置的本地方法
+func (self *VM) RegisterNative(className, methodName, methodDescriptor string, method func(frame *rtda.Frame)) {
+	self.runtime.RegisterNative(className, methodName, methodDescriptor, method)
+}
+
+// LoadClass 加载类(不初始化)，类名中的.和/都可以
+func (self *VM) LoadClass(name string) (class *heap.Class, err error) {
+	defer recoverError(&err)
+	name = strings.Replace(name, ".", "/", -1)
+	if class = self.loader.FindClass(name); class == nil {
+		return nil, fmt.Errorf("java.lang.ClassNotFoundException: %s", name)
+	}
+	return class, nil
+}
+
+// NewString 返回Go字符串对应的Java字符串
+func (self *VM) NewString(s string) *heap.Object {
+	return heap.JString(self.loader, s)
+}
+
+// GoString 返回Java字符串对应的Go字符串，jStr为nil时返回空字符串
+func (self *VM) GoString(jStr *heap.Object) string {
+	if jStr == nil {
+		return ""
+	}
+	return heap.GoString(jStr)
+}
+
+// WriteStringTableStatistics 打印字符串池的统计信息，对应-XX:+PrintStringTableStatistics
+func (self *VM) WriteStringTableStatistics(w io.Writer) {
+	self.loader.StringTable().WriteStatistics(w)
+}
+
+// Halted 虚拟机是否已经因为System.exit()或者Runtime.halt()停止，以及退出码
+func (self *VM) Halted() (bool, int) {
+	return self.runtime.Halted()
+}